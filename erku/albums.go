@@ -0,0 +1,99 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Album - ալբոմի մոդելը GORM-ի համար
+type Album struct {
+	ID        uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	Name      string    `json:"name" gorm:"type:varchar(100);uniqueIndex;not null"`
+	CreatedAt time.Time `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+}
+
+// AlbumImage - Ալբոմի և նկարի կապը (many-to-many)
+type AlbumImage struct {
+	AlbumID   uint      `json:"albumId" gorm:"primaryKey"`
+	ImageID   string    `json:"imageId" gorm:"type:varchar(36);primaryKey;index"`
+	CreatedAt time.Time `json:"createdAt" gorm:"autoCreateTime"`
+}
+
+// getAllAlbumsHandler - Բոլոր ալբոմների ցանկի ստացում
+func getAllAlbumsHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	if !dbAvailable() {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Տվյալների բազան հասանելի չէ"})
+		return
+	}
+
+	var albums []Album
+	if err := db.WithContext(ctx).Find(&albums).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Չհաջողվեց ստանալ ալբոմների ցանկը"})
+		return
+	}
+
+	c.JSON(http.StatusOK, albums)
+}
+
+// getAlbumImagesHandler - Ալբոմի նկարների ստացում
+func getAlbumImagesHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	if !dbAvailable() {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Տվյալների բազան հասանելի չէ"})
+		return
+	}
+
+	var album Album
+	if err := db.WithContext(ctx).Where("name = ?", c.Param("name")).First(&album).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ալբոմը չի գտնվել"})
+		return
+	}
+
+	var images []Image
+	err := db.WithContext(ctx).Scopes(activeImages).
+		Where("id IN (?)", db.Model(&AlbumImage{}).Select("image_id").Where("album_id = ?", album.ID)).
+		Find(&images).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Չհաջողվեց ստանալ ալբոմի նկարները"})
+		return
+	}
+
+	result := []ImageResponse{}
+	for _, image := range images {
+		response, err := createImageResponse(ctx, image)
+		if err != nil {
+			continue
+		}
+		result = append(result, response)
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// findOrCreateAlbum - Ստանալ ալբոմը անունով կամ ստեղծել նորը
+func findOrCreateAlbum(tx *gorm.DB, name string) (Album, error) {
+	var album Album
+	name = strings.TrimSpace(name)
+	err := tx.Where("name = ?", name).FirstOrCreate(&album, Album{Name: name}).Error
+	return album, err
+}
+
+// addImagesToAlbum - Ավելացնել նկարները ալբոմին, արդեն ավելացվածները անտեսվում են
+func addImagesToAlbum(tx *gorm.DB, albumID uint, imageIDs []string) error {
+	if len(imageIDs) == 0 {
+		return nil
+	}
+
+	links := make([]AlbumImage, 0, len(imageIDs))
+	for _, imageID := range imageIDs {
+		links = append(links, AlbumImage{AlbumID: albumID, ImageID: imageID})
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&links).Error
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	attributeTypeString = "string"
+	attributeTypeNumber = "number"
+	attributeTypeDate   = "date"
+	attributeTypeBool   = "bool"
+
+	// maxAttributeFilters - մեկ հարցման մեջ ատրիբուտների ֆիլտրերի առավելագույն քանակը
+	maxAttributeFilters = 10
+)
+
+// attributeKeyPattern - թույլատրելի ատրիբուտի բանալի (օր.՝ "license", "camera.model")
+var attributeKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+
+// attributeFilterPattern - ֆիլտր ցանկի հարցման մեջ (օր.՝ "attr.rating>=4")
+var attributeFilterPattern = regexp.MustCompile(`^attr\.([A-Za-z0-9_.-]{1,64}?)(>=|<=|!=|=|>|<)(.*)$`)
+
+// errTooManyAttributes - նկարի ատրիբուտների քանակը գերազանցում է maxImageAttributes-ը
+var errTooManyAttributes = errors.New("չափազանց շատ ատրիբուտներ")
+
+// ImageAttribute - Նկարի տիպավորված բանալի/արժեք ատրիբուտ
+type ImageAttribute struct {
+	ImageID string `json:"imageId" gorm:"type:varchar(36);primaryKey"`
+	Key     string `json:"key" gorm:"type:varchar(64);primaryKey;index:idx_attribute_number,priority:1;index:idx_attribute_date,priority:1"`
+	Type    string `json:"type" gorm:"type:varchar(10);not null;default:string"`
+	// Value - արժեքի կանոնական տեքստային տեսքը, օգտագործվում է հավասարության ֆիլտրերում
+	Value       string     `json:"value" gorm:"type:varchar(1000);not null"`
+	NumberValue *float64   `json:"-" gorm:"index:idx_attribute_number,priority:2"`
+	DateValue   *time.Time `json:"-" gorm:"type:datetime;index:idx_attribute_date,priority:2"`
+	CreatedAt   time.Time  `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time  `json:"updatedAt" gorm:"autoUpdateTime"`
+}
+
+// attributeInput - Ատրիբուտի արժեքը հարցման մեջ. JSON տողը, թիվը և bool-ը տիպավորվում են ինքնաշխատ,
+// իսկ ամսաթվի համար օգտագործվում է {"type": "date", "value": "2024-05-01"}
+type attributeInput struct {
+	Type  string
+	Value string
+}
+
+// UnmarshalJSON - Որոշել արժեքի տիպը JSON-ից
+func (a *attributeInput) UnmarshalJSON(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var raw interface{}
+	if err := decoder.Decode(&raw); err != nil {
+		return err
+	}
+
+	switch value := raw.(type) {
+	case string:
+		a.Type, a.Value = attributeTypeString, value
+	case json.Number:
+		a.Type, a.Value = attributeTypeNumber, value.String()
+	case bool:
+		a.Type, a.Value = attributeTypeBool, strconv.FormatBool(value)
+	case map[string]interface{}:
+		if value["value"] == nil {
+			return errors.New("ատրիբուտի արժեքը բացակայում է")
+		}
+		typ, _ := value["type"].(string)
+		if typ == "" {
+			typ = attributeTypeString
+		}
+		a.Type, a.Value = typ, fmt.Sprint(value["value"])
+	default:
+		return errors.New("ատրիբուտի արժեքը պետք է լինի տող, թիվ, bool կամ {type, value}")
+	}
+	return nil
+}
+
+// attribute - Ստուգել արժեքը ըստ տիպի և կառուցել պահվող ատրիբուտը
+func (a attributeInput) attribute(imageID, key string) (ImageAttribute, error) {
+	attribute := ImageAttribute{ImageID: imageID, Key: key, Type: a.Type, Value: a.Value}
+
+	switch a.Type {
+	case attributeTypeString:
+	case attributeTypeNumber:
+		number, err := strconv.ParseFloat(a.Value, 64)
+		if err != nil {
+			return ImageAttribute{}, errors.New("սպասվում է թիվ")
+		}
+		attribute.NumberValue = &number
+		attribute.Value = strconv.FormatFloat(number, 'f', -1, 64)
+	case attributeTypeDate:
+		date, err := parseAttributeDate(a.Value)
+		if err != nil {
+			return ImageAttribute{}, errors.New("սպասվում է ամսաթիվ (2006-01-02 կամ RFC3339)")
+		}
+		attribute.DateValue = &date
+		attribute.Value = date.Format(time.RFC3339)
+	case attributeTypeBool:
+		value, err := strconv.ParseBool(a.Value)
+		if err != nil {
+			return ImageAttribute{}, errors.New("սպասվում է true կամ false")
+		}
+		attribute.Value = strconv.FormatBool(value)
+	default:
+		return ImageAttribute{}, errors.New("անհայտ տիպ " + a.Type)
+	}
+
+	return attribute, nil
+}
+
+// parseAttributeDate - Ամսաթիվ 2006-01-02 կամ RFC3339 ձևաչափով (UTC)
+func parseAttributeDate(value string) (time.Time, error) {
+	if date, err := time.Parse("2006-01-02", value); err == nil {
+		return date, nil
+	}
+	date, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return date.UTC(), nil
+}
+
+// setImageAttributes - Ավելացնել, փոխել կամ ջնջել (nil արժեքով) նկարի ատրիբուտները
+func setImageAttributes(tx *gorm.DB, imageID string, attributes map[string]*attributeInput) error {
+	for key, value := range attributes {
+		if value == nil {
+			if err := tx.Where("image_id = ? AND `key` = ?", imageID, key).Delete(&ImageAttribute{}).Error; err != nil {
+				return err
+			}
+			continue
+		}
+
+		attribute, err := value.attribute(imageID, key)
+		if err != nil {
+			return err
+		}
+		err = tx.Clauses(clause.OnConflict{
+			DoUpdates: clause.AssignmentColumns([]string{"type", "value", "number_value", "date_value", "updated_at"}),
+		}).Create(&attribute).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	var count int64
+	if err := tx.Model(&ImageAttribute{}).Where("image_id = ?", imageID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > maxImageAttributes {
+		return errTooManyAttributes
+	}
+	return nil
+}
+
+// getImageAttributes - Նկարի ատրիբուտները որպես map՝ իրենց տիպով
+func getImageAttributes(ctx context.Context, imageID string) map[string]interface{} {
+	var attributes []ImageAttribute
+	db.WithContext(ctx).Where("image_id = ?", imageID).Find(&attributes)
+	if len(attributes) == 0 {
+		return nil
+	}
+
+	result := make(map[string]interface{}, len(attributes))
+	for _, attribute := range attributes {
+		switch {
+		case attribute.Type == attributeTypeNumber && attribute.NumberValue != nil:
+			result[attribute.Key] = *attribute.NumberValue
+		case attribute.Type == attributeTypeDate && attribute.DateValue != nil:
+			result[attribute.Key] = attribute.DateValue.UTC()
+		case attribute.Type == attributeTypeBool:
+			result[attribute.Key] = attribute.Value == "true"
+		default:
+			result[attribute.Key] = attribute.Value
+		}
+	}
+	return result
+}
+
+// attributeFilter - Ցանկի հարցման մեկ ատրիբուտային ֆիլտր
+type attributeFilter struct {
+	key      string
+	operator string
+	value    string
+}
+
+// parseAttributeFilters - Կարդալ attr.* ֆիլտրերը հարցման տողից. «>=» և «<=» օպերատորները
+// url.Values-ում կդառնային բանալու մաս, ուստի հարցումը վերլուծվում է ձեռքով:
+// Մնացած պարամետրերը (page, cache-buster և այլն) անտեսվում են
+func parseAttributeFilters(rawQuery string) ([]attributeFilter, error) {
+	var filters []attributeFilter
+	for _, pair := range strings.Split(rawQuery, "&") {
+		if !isAttributeFilterPair(pair) {
+			continue
+		}
+
+		decoded, err := url.QueryUnescape(pair)
+		if err != nil {
+			return nil, fmt.Errorf("անվավեր ֆիլտր %q", pair)
+		}
+		match := attributeFilterPattern.FindStringSubmatch(decoded)
+		if match == nil {
+			return nil, fmt.Errorf("անվավեր ֆիլտր %q", decoded)
+		}
+
+		filters = append(filters, attributeFilter{key: match[1], operator: match[2], value: match[3]})
+	}
+
+	if len(filters) > maxAttributeFilters {
+		return nil, errors.New("չափազանց շատ ֆիլտրեր")
+	}
+	return filters, nil
+}
+
+// isAttributeFilterPair - Հարցման զույգը attr.* ֆիլտր է
+func isAttributeFilterPair(pair string) bool {
+	return strings.HasPrefix(pair, "attr.") || strings.HasPrefix(pair, "attr%2E") || strings.HasPrefix(pair, "attr%2e")
+}
+
+// attributeFilters - Scope, որը թողնում է միայն բոլոր ֆիլտրերին համապատասխանող նկարները
+func attributeFilters(filters []attributeFilter) func(*gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		for _, filter := range filters {
+			sql, vars := filter.condition()
+			matching := tx.Session(&gorm.Session{NewDB: true}).
+				Model(&ImageAttribute{}).
+				Select("image_id").
+				Where("`key` = ?", filter.key).
+				Where(sql, vars...)
+			tx = tx.Where("id IN (?)", matching)
+		}
+		return tx
+	}
+}
+
+// condition - Ֆիլտրի SQL պայմանը. արժեքը համեմատվում է որպես թիվ, ամսաթիվ կամ տեքստ՝ ըստ իր տեսքի
+func (f attributeFilter) condition() (string, []interface{}) {
+	operator := f.operator
+	negate := operator == "!="
+	if negate {
+		operator = "="
+	}
+
+	var (
+		sql  string
+		vars []interface{}
+	)
+	if number, err := strconv.ParseFloat(f.value, 64); err == nil {
+		sql, vars = typedCondition(attributeTypeNumber, "number_value", operator, number, f.value)
+	} else if date, err := parseAttributeDate(f.value); err == nil {
+		sql, vars = typedCondition(attributeTypeDate, "date_value", operator, date, f.value)
+	} else {
+		sql, vars = "value "+operator+" ?", []interface{}{f.value}
+	}
+
+	if negate {
+		return "NOT (" + sql + ")", vars
+	}
+	return sql, vars
+}
+
+// typedCondition - Համեմատություն տիպավորված սյունակով, հավասարության դեպքում նաև տեքստային արժեքով
+func typedCondition(typ, column, operator string, typed interface{}, text string) (string, []interface{}) {
+	sql := "(type = ? AND " + column + " " + operator + " ?)"
+	if operator != "=" {
+		return sql, []interface{}{typ, typed}
+	}
+	// «project=2024»-ը պետք է համընկնի նաև տեքստային ատրիբուտի հետ
+	return "(" + sql + " OR (type <> ? AND value = ?))", []interface{}{typ, typed, typ, text}
+}
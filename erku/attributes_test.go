@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+// setAttributes - Նշանակել նկարի ատրիբուտները JSON-ից, ինչպես PATCH-ի մարմնում
+func setAttributes(t *testing.T, imageID, attributes string) {
+	t.Helper()
+	var inputs map[string]*attributeInput
+	if err := json.Unmarshal([]byte(attributes), &inputs); err != nil {
+		t.Fatalf("ատրիբուտներ: %v", err)
+	}
+	if err := setImageAttributes(db, imageID, inputs); err != nil {
+		t.Fatalf("setImageAttributes: %v", err)
+	}
+}
+
+// listImageIDs - Կատարել GET /api/images և վերադարձնել նկարների ID-ները
+func listImageIDs(t *testing.T, query string) (int, []string) {
+	t.Helper()
+	response := serve(http.MethodGet, "/api/images", "/api/images"+query, getAllImagesHandler, nil, "")
+	var images []ImageResponse
+	json.Unmarshal(response.Body.Bytes(), &images)
+	ids := make([]string, 0, len(images))
+	for _, image := range images {
+		ids = append(ids, image.ID)
+	}
+	slices.Sort(ids)
+	return response.Code, ids
+}
+
+func TestListingIgnoresNonAttributeParams(t *testing.T) {
+	fake := setupTest(t)
+	insertImage(t, fake, "img-1")
+	insertImage(t, fake, "img-2")
+	setAttributes(t, "img-1", `{"license": "cc-by"}`)
+
+	for query, want := range map[string][]string{
+		"?page=2":                            {"img-1", "img-2"},
+		"?_=1700000000":                      {"img-1", "img-2"},
+		"?page=2&attr.license=cc-by&_=17000": {"img-1"},
+	} {
+		code, ids := listImageIDs(t, query)
+		if code != http.StatusOK || !slices.Equal(ids, want) {
+			t.Errorf("%s: կոդ %d, նկարներ %v, սպասվում էր 200 և %v", query, code, ids, want)
+		}
+	}
+}
+
+func TestListingAttributeFilters(t *testing.T) {
+	fake := setupTest(t)
+	for _, id := range []string{"img-1", "img-2", "img-3", "img-4"} {
+		insertImage(t, fake, id)
+	}
+	setAttributes(t, "img-1", `{"rating": 5, "shot": {"type": "date", "value": "2024-05-01"}, "license": "cc-by", "project": "2024"}`)
+	setAttributes(t, "img-2", `{"rating": 3, "shot": {"type": "date", "value": "2023-01-10T12:00:00Z"}, "license": "mit", "project": 2024}`)
+	setAttributes(t, "img-3", `{"rating": 4.5, "license": "cc-by", "hdr": true}`)
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"?attr.rating>=4", []string{"img-1", "img-3"}},
+		{"?attr.rating%3E%3D4", []string{"img-1", "img-3"}},
+		{"?attr.rating>4.5", []string{"img-1"}},
+		{"?attr.rating<4", []string{"img-2"}},
+		{"?attr.rating<=4.5", []string{"img-2", "img-3"}},
+		{"?attr.rating=3", []string{"img-2"}},
+		{"?attr.rating!=3", []string{"img-1", "img-3"}},
+		{"?attr.shot>=2024-01-01", []string{"img-1"}},
+		{"?attr.shot<2024-01-01T00:00:00Z", []string{"img-2"}},
+		{"?attr.shot=2023-01-10T12:00:00Z", []string{"img-2"}},
+		{"?attr.license=cc-by", []string{"img-1", "img-3"}},
+		{"?attr.license!=cc-by", []string{"img-2"}},
+		// Հավասարությունը համընկնում է և՛ թվային, և՛ տեքստային ատրիբուտի հետ
+		{"?attr.project=2024", []string{"img-1", "img-2"}},
+		{"?attr.hdr=true", []string{"img-3"}},
+		{"?attr.rating>=4&attr.license=cc-by&attr.shot>=2024-01-01", []string{"img-1"}},
+		{"?attr.missing=1", []string{}},
+	}
+	for _, tt := range tests {
+		code, ids := listImageIDs(t, tt.query)
+		if code != http.StatusOK || !slices.Equal(ids, tt.want) {
+			t.Errorf("%s: կոդ %d, նկարներ %v, սպասվում էր 200 և %v", tt.query, code, ids, tt.want)
+		}
+	}
+
+	tooMany := ""
+	for i := 0; i <= maxAttributeFilters; i++ {
+		tooMany += "&attr.rating>=1"
+	}
+	for _, query := range []string{"?attr.rating", "?attr.rating~4", "?attr.%ZZ=1", "?" + tooMany[1:]} {
+		if code, _ := listImageIDs(t, query); code != http.StatusBadRequest {
+			t.Errorf("%s: կոդ %d, սպասվում էր 400", query, code)
+		}
+	}
+}
@@ -0,0 +1,408 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	// batchMaxFileSize - խմբաքանակի մեկ ֆայլի առավելագույն չափը
+	batchMaxFileSize = 50 << 20
+	// defaultBatchConcurrency - միաժամանակ վերբեռնվող ֆայլերի լռելյայն քանակը
+	defaultBatchConcurrency = 4
+)
+
+const (
+	batchStatusSuccess   = "success"
+	batchStatusDuplicate = "duplicate"
+	batchStatusRejected  = "rejected"
+)
+
+// batchFile - Խմբաքանակից կարդացված մեկ ֆայլ
+type batchFile struct {
+	index int
+	name  string
+	data  []byte
+	err   error
+}
+
+// batchResult - Խմբաքանակի մեկ ֆայլի արդյունքը
+type batchResult struct {
+	index       int
+	File        string `json:"file"`
+	Status      string `json:"status"`
+	ID          string `json:"id,omitempty"`
+	DuplicateOf string `json:"duplicateOf,omitempty"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+// batchHashes - Նույն խմբաքանակում արդեն հանդիպած հեշերը
+type batchHashes struct {
+	mu   sync.Mutex
+	seen map[string]*batchClaim
+}
+
+// batchClaim - Հեշով ֆայլի վերբեռնումը, done-ը փակվում է, երբ հայտնի է դրա արդյունքը
+type batchClaim struct {
+	imageID string
+	stored  bool
+	done    chan struct{}
+}
+
+// claim - Գրանցել հեշը, կամ վերադարձնել այն ID-ն, որին այն արդեն պատկանում է: Եթե նույն հեշով ֆայլը
+// դեռ վերբեռնվում է, սպասել դրա արդյունքին, որպեսզի կրկնօրինակը չհղվի ձախողված վերբեռնման ID-ին
+func (bh *batchHashes) claim(hash, imageID string) (string, bool) {
+	for {
+		bh.mu.Lock()
+		existing, ok := bh.seen[hash]
+		if !ok {
+			bh.seen[hash] = &batchClaim{imageID: imageID, done: make(chan struct{})}
+			bh.mu.Unlock()
+			return "", true
+		}
+		bh.mu.Unlock()
+
+		<-existing.done
+		if existing.stored {
+			return existing.imageID, false
+		}
+	}
+}
+
+// confirm - Նշել, որ հեշով նկարը պահված է
+func (bh *batchHashes) confirm(hash string) {
+	bh.mu.Lock()
+	claim := bh.seen[hash]
+	bh.mu.Unlock()
+
+	claim.stored = true
+	close(claim.done)
+}
+
+// release - Ազատել հեշը, եթե վերբեռնումը ձախողվել է
+func (bh *batchHashes) release(hash string) {
+	bh.mu.Lock()
+	claim := bh.seen[hash]
+	delete(bh.seen, hash)
+	bh.mu.Unlock()
+
+	close(claim.done)
+}
+
+// batchUploadHandler - Բազմաթիվ ֆայլերի կամ ZIP/TAR արխիվների վերբեռնում մեկ հարցումով
+func batchUploadHandler(c *gin.Context) {
+	form, err := c.MultipartForm()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Չհաջողվեց կարդալ ձևը"})
+		return
+	}
+
+	var headers []*multipart.FileHeader
+	headers = append(headers, form.File["images"]...)
+	headers = append(headers, form.File["image"]...)
+	if len(headers) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Ֆայլեր չեն տրվել"})
+		return
+	}
+
+	tagNames := parseTagList(c.PostForm("tags"))
+	concurrency := cfg.Uploads.BatchConcurrency
+
+	files := make(chan batchFile, concurrency)
+	go func() {
+		defer close(files)
+		readBatchFiles(headers, files)
+	}()
+
+	hashes := &batchHashes{seen: make(map[string]*batchClaim)}
+	ctx := c.Request.Context()
+
+	var (
+		mu      sync.Mutex
+		results []batchResult
+		wg      sync.WaitGroup
+	)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for file := range files {
+				result := storeBatchFile(ctx, file, tagNames, hashes)
+				mu.Lock()
+				results = append(results, result)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool { return results[i].index < results[j].index })
+
+	summary := map[string]int{batchStatusSuccess: 0, batchStatusDuplicate: 0, batchStatusRejected: 0}
+	for _, result := range results {
+		summary[result.Status]++
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"results": results,
+		"summary": summary,
+	})
+}
+
+// readBatchFiles - Կարդալ ձևի ֆայլերը՝ բացելով արխիվները, և ուղարկել դրանք ալիքով
+func readBatchFiles(headers []*multipart.FileHeader, files chan<- batchFile) {
+	index := 0
+	emit := func(name string, r io.Reader) {
+		data, err := readLimited(r)
+		files <- batchFile{index: index, name: name, data: data, err: err}
+		index++
+	}
+
+	for _, header := range headers {
+		file, err := header.Open()
+		if err != nil {
+			files <- batchFile{index: index, name: header.Filename, err: err}
+			index++
+			continue
+		}
+
+		lower := strings.ToLower(header.Filename)
+		switch {
+		case strings.HasSuffix(lower, ".zip"):
+			if err := readZipEntries(file, header.Size, emit); err != nil {
+				files <- batchFile{index: index, name: header.Filename, err: err}
+				index++
+			}
+		case strings.HasSuffix(lower, ".tar"), strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+			if err := readTarEntries(file, !strings.HasSuffix(lower, ".tar"), emit); err != nil {
+				files <- batchFile{index: index, name: header.Filename, err: err}
+				index++
+			}
+		default:
+			emit(header.Filename, file)
+		}
+
+		file.Close()
+	}
+}
+
+// readZipEntries - ZIP արխիվի ֆայլերի ընթերցում
+func readZipEntries(file multipart.File, size int64, emit func(string, io.Reader)) error {
+	archive, err := zip.NewReader(file, size)
+	if err != nil {
+		return fmt.Errorf("անվավեր ZIP արխիվ: %w", err)
+	}
+
+	for _, entry := range archive.File {
+		if entry.FileInfo().IsDir() || skipArchiveEntry(entry.Name) {
+			continue
+		}
+
+		r, err := entry.Open()
+		if err != nil {
+			emit(entry.Name, errReader{err})
+			continue
+		}
+		emit(entry.Name, r)
+		r.Close()
+	}
+	return nil
+}
+
+// readTarEntries - TAR (կամ tar.gz) արխիվի ֆայլերի ընթերցում
+func readTarEntries(file multipart.File, gzipped bool, emit func(string, io.Reader)) error {
+	var r io.Reader = file
+	if gzipped {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return fmt.Errorf("անվավեր gzip արխիվ: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	archive := tar.NewReader(r)
+	for {
+		header, err := archive.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("անվավեր TAR արխիվ: %w", err)
+		}
+
+		if header.Typeflag != tar.TypeReg || skipArchiveEntry(header.Name) {
+			continue
+		}
+		emit(header.Name, archive)
+	}
+}
+
+// skipArchiveEntry - Բաց թողնել համակարգային և թաքնված ֆայլերը (օր.՝ __MACOSX, .DS_Store)
+func skipArchiveEntry(name string) bool {
+	if strings.HasPrefix(name, "__MACOSX/") {
+		return true
+	}
+	return strings.HasPrefix(path.Base(name), ".")
+}
+
+// errReader - Ընթերցող, որը միշտ վերադարձնում է տրված սխալը
+type errReader struct{ err error }
+
+func (r errReader) Read([]byte) (int, error) { return 0, r.err }
+
+// errFileTooLarge - ֆայլը գերազանցում է batchMaxFileSize-ը
+var errFileTooLarge = errors.New("ֆայլը չափազանց մեծ է")
+
+// readLimited - Կարդալ ֆայլը հիշողության մեջ՝ batchMaxFileSize սահմանափակմամբ
+func readLimited(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, batchMaxFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > batchMaxFileSize {
+		return nil, errFileTooLarge
+	}
+	return data, nil
+}
+
+// storeBatchFile - Ստուգել, վերբեռնել և գրանցել խմբաքանակի մեկ ֆայլը
+func storeBatchFile(ctx context.Context, file batchFile, tagNames []string, hashes *batchHashes) batchResult {
+	started := time.Now()
+	result := batchResult{index: file.index, File: file.name}
+	reject := func(reason string) batchResult {
+		result.Status = batchStatusRejected
+		result.Reason = reason
+		return result
+	}
+
+	if errors.Is(file.err, errFileTooLarge) {
+		return reject("Ֆայլը չափազանց մեծ է")
+	}
+	if file.err != nil {
+		slog.WarnContext(ctx, "Սխալ խմբաքանակի ֆայլը կարդալիս", "file", file.name, "error", file.err)
+		return reject("Չհաջողվեց կարդալ ֆայլը")
+	}
+	if len(file.data) == 0 {
+		return reject("Ֆայլը դատարկ է")
+	}
+
+	filename := path.Base(filepath.ToSlash(file.name))
+	extension := filepath.Ext(filename)
+	contentType := getContentType(extension)
+	if !isImageContent(contentType, file.data) {
+		return reject("Ֆայլը նկար չէ")
+	}
+
+	sum := sha256.Sum256(file.data)
+	hash := hex.EncodeToString(sum[:])
+	width, height := imageDimensions(bytes.NewReader(file.data))
+	imageID := newImageID()
+
+	// Կրկնօրինակ նույն խմբաքանակում
+	if existing, ok := hashes.claim(hash, imageID); !ok {
+		result.Status = batchStatusDuplicate
+		result.DuplicateOf = existing
+		return result
+	}
+
+	// Կրկնօրինակ արդեն պահված նկարների մեջ
+	if dbAvailable() {
+		var existing Image
+		err := db.WithContext(ctx).Scopes(activeImages).Select("id").Where("hash = ?", hash).First(&existing).Error
+		if err == nil {
+			hashes.release(hash)
+			result.Status = batchStatusDuplicate
+			result.DuplicateOf = existing.ID
+			return result
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			hashes.release(hash)
+			slog.ErrorContext(ctx, "Սխալ կրկնօրինակը ստուգելիս", "file", file.name, "error", err)
+			return reject("Չհաջողվեց ստուգել կրկնօրինակը")
+		}
+	}
+
+	uploadDir := cfg.Storage.UploadDir
+	objectKey := filepath.Join(uploadDir, imageID+extension)
+
+	store := newSaga("batch upload")
+	_, err := s3Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(cfg.Storage.Bucket),
+		Key:         aws.String(objectKey),
+		Body:        bytes.NewReader(file.data),
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		hashes.release(hash)
+		slog.ErrorContext(ctx, "Սխալ MinIO վերբեռնելիս", "file", file.name, "image_id", imageID, "error", err)
+		return reject("Չհաջողվեց վերբեռնել նկարը")
+	}
+	store.onFailure(deleteObjectCompensation(objectKey))
+
+	if dbAvailable() {
+		image := Image{
+			ID:          imageID,
+			ObjectKey:   objectKey,
+			Name:        filename,
+			Size:        int64(len(file.data)),
+			ContentType: contentType,
+			Hash:        hash,
+			Width:       width,
+			Height:      height,
+			UploadedAt:  time.Now(),
+		}
+		if err := createImageRecord(ctx, &image, tagNames); err != nil {
+			slog.ErrorContext(ctx, "Սխալ նկարը բազայում պահելիս", "file", file.name, "image_id", imageID, "error", err)
+			store.compensate(ctx)
+			hashes.release(hash)
+			return reject("Չհաջողվեց պահել նկարի մասին տվյալները")
+		}
+	}
+
+	hashes.confirm(hash)
+	observeUpload(uploadMethodBatch, int64(len(file.data)), started)
+	result.Status = batchStatusSuccess
+	result.ID = imageID
+	return result
+}
+
+// isImageContent - Ստուգել, որ ֆայլի սկիզբը իսկապես նկար է
+func isImageContent(contentType string, data []byte) bool {
+	if !strings.HasPrefix(contentType, "image/") {
+		return false
+	}
+
+	if len(data) > sniffSize {
+		data = data[:sniffSize]
+	}
+	detected := http.DetectContentType(data)
+
+	// DetectContentType-ը չի ճանաչում SVG, ուստի այն ընդունվում է որպես տեքստ
+	if contentType == "image/svg+xml" {
+		return strings.HasPrefix(detected, "text/")
+	}
+	return strings.HasPrefix(detected, "image/")
+}
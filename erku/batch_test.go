@@ -0,0 +1,207 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+// pngVariant - PNG նկար, որի հեշը տարբերվում է testPNG-ից
+func pngVariant(suffix string) []byte {
+	return append(append([]byte(nil), testPNG...), suffix...)
+}
+
+// zipArchive - ZIP արխիվ տրված ֆայլերով
+func zipArchive(t *testing.T, files map[string][]byte) []byte {
+	t.Helper()
+	var buffer bytes.Buffer
+	archive := zip.NewWriter(&buffer)
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		w, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(files[name])
+	}
+	archive.Close()
+	return buffer.Bytes()
+}
+
+// tarGzArchive - tar.gz արխիվ մեկ ֆայլով
+func tarGzArchive(t *testing.T, name string, data []byte) []byte {
+	t.Helper()
+	var buffer bytes.Buffer
+	gz := gzip.NewWriter(&buffer)
+	archive := tar.NewWriter(gz)
+	if err := archive.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(data)), Typeflag: tar.TypeReg}); err != nil {
+		t.Fatal(err)
+	}
+	archive.Write(data)
+	archive.Close()
+	gz.Close()
+	return buffer.Bytes()
+}
+
+// batchFormFile - Ձևի մեկ ֆայլ
+type batchFormFile struct {
+	name string
+	data []byte
+}
+
+// batchUpload - POST /api/images/batch «images» դաշտերով և թեգերով
+func batchUpload(t *testing.T, tags string, files ...batchFormFile) (int, []batchResult, map[string]int) {
+	t.Helper()
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for _, file := range files {
+		part, err := writer.CreateFormFile("images", file.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write(file.data)
+	}
+	if tags != "" {
+		writer.WriteField("tags", tags)
+	}
+	writer.Close()
+
+	response := serve(http.MethodPost, "/api/images/batch", "/api/images/batch", batchUploadHandler, body, writer.FormDataContentType())
+	var result struct {
+		Results []batchResult  `json:"results"`
+		Summary map[string]int `json:"summary"`
+	}
+	json.Unmarshal(response.Body.Bytes(), &result)
+	return response.Code, result.Results, result.Summary
+}
+
+func TestBatchUpload(t *testing.T) {
+	fake := setupTest(t)
+	stored := insertImage(t, fake, "stored")
+	sum := sha256.Sum256(pngVariant("stored"))
+	db.Model(&stored).Update("hash", hex.EncodeToString(sum[:]))
+
+	code, results, summary := batchUpload(t, "cats, dogs",
+		batchFormFile{"a.png", testPNG},
+		batchFormFile{"b.png", testPNG},
+		batchFormFile{"notes.txt", []byte("hello")},
+		batchFormFile{"fake.png", []byte("not an image")},
+		batchFormFile{"empty.png", nil},
+		batchFormFile{"photos.zip", zipArchive(t, map[string][]byte{
+			"dir/c.png":         pngVariant("c"),
+			"__MACOSX/._c.png":  []byte("resource fork"),
+			".DS_Store":         []byte("finder"),
+			"dir/again.png":     pngVariant("stored"),
+			"dir/nested/d.jpeg": pngVariant("d"),
+		})},
+		batchFormFile{"more.tar.gz", tarGzArchive(t, "e.png", pngVariant("e"))},
+		batchFormFile{"broken.zip", []byte("not a zip")},
+	)
+	if code != http.StatusOK {
+		t.Fatalf("կոդ %d", code)
+	}
+
+	// a.png-ն և b.png-ն մշակվում են զուգահեռ, ուստի դրանցից որը կպահվի, հայտնի չէ
+	first, second := results[0], results[1]
+	if first.Status == batchStatusDuplicate {
+		first, second = second, first
+	}
+	if first.Status != batchStatusSuccess || second.Status != batchStatusDuplicate || second.DuplicateOf != first.ID {
+		t.Errorf("նույն բովանդակությամբ ֆայլեր: %+v, %+v", results[0], results[1])
+	}
+
+	want := []struct{ file, status string }{
+		{"a.png", ""},
+		{"b.png", ""},
+		{"notes.txt", batchStatusRejected},
+		{"fake.png", batchStatusRejected},
+		{"empty.png", batchStatusRejected},
+		{"dir/again.png", batchStatusDuplicate},
+		{"dir/c.png", batchStatusSuccess},
+		// Ընդլայնումը որոշում է տեսակը, իսկ բովանդակությունը՝ PNG է, ուստի այն նկար է
+		{"dir/nested/d.jpeg", batchStatusSuccess},
+		{"e.png", batchStatusSuccess},
+		{"broken.zip", batchStatusRejected},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("%d արդյունք, սպասվում էր %d: %+v", len(results), len(want), results)
+	}
+	for i, w := range want {
+		if results[i].File != w.file || (w.status != "" && results[i].Status != w.status) {
+			t.Errorf("%d: %s %s, սպասվում էր %s %s (%s)", i, results[i].File, results[i].Status, w.file, w.status, results[i].Reason)
+		}
+		if w.status == batchStatusRejected && results[i].Reason == "" {
+			t.Errorf("%s: մերժման պատճառը բացակայում է", w.file)
+		}
+	}
+	if results[5].DuplicateOf != stored.ID {
+		t.Errorf("dir/again.png: կրկնօրինակ %q, սպասվում էր %q", results[5].DuplicateOf, stored.ID)
+	}
+	if summary[batchStatusSuccess] != 4 || summary[batchStatusDuplicate] != 2 || summary[batchStatusRejected] != 4 {
+		t.Errorf("ամփոփում %v", summary)
+	}
+
+	for _, result := range results {
+		if result.Status != batchStatusSuccess {
+			continue
+		}
+		var image Image
+		if err := db.First(&image, "id = ?", result.ID).Error; err != nil || !fake.has(image.ObjectKey) {
+			t.Errorf("%s: գրառում %v, օբյեկտ %v", result.File, err, fake.has(image.ObjectKey))
+			continue
+		}
+		if tags, _ := imageTagNames(db, image.ID); !slices.Equal(tags, []string{"cats", "dogs"}) {
+			t.Errorf("%s: թեգեր %v", result.File, tags)
+		}
+	}
+}
+
+func TestBatchUploadRollsBackFailedFiles(t *testing.T) {
+	t.Run("S3", func(t *testing.T) {
+		fake := setupTest(t)
+		fake.failOn("PUT")
+		_, results, _ := batchUpload(t, "", batchFormFile{"a.png", testPNG})
+		if len(results) != 1 || results[0].Status != batchStatusRejected {
+			t.Fatalf("արդյունք %+v", results)
+		}
+		var count int64
+		db.Model(&Image{}).Count(&count)
+		if count != 0 {
+			t.Errorf("%d գրառում", count)
+		}
+	})
+
+	t.Run("բազա", func(t *testing.T) {
+		fake := setupTest(t)
+		failDBWrites(t, "create", "images")
+		_, results, _ := batchUpload(t, "", batchFormFile{"a.png", testPNG}, batchFormFile{"b.png", testPNG})
+		// Կրկնօրինակը չի հղվում ձախողված վերբեռնմանը, այլ փորձվում է ինքնուրույն և նույնպես մերժվում
+		for _, result := range results {
+			if result.Status != batchStatusRejected {
+				t.Errorf("%s: %s", result.File, result.Status)
+			}
+		}
+		if keys := fake.keys(); len(keys) != 0 {
+			t.Errorf("օբյեկտները չեն ջնջվել: %v", keys)
+		}
+	})
+}
+
+func TestBatchUploadRequiresFiles(t *testing.T) {
+	setupTest(t)
+	if code, _, _ := batchUpload(t, "cats"); code != http.StatusBadRequest {
+		t.Errorf("կոդ %d, սպասվում էր 400", code)
+	}
+}
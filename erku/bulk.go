@@ -0,0 +1,543 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	bulkOperationAddTags    = "add_tags"
+	bulkOperationRemoveTags = "remove_tags"
+	bulkOperationAddToAlbum = "add_to_album"
+	bulkOperationDelete     = "delete"
+)
+
+const (
+	// bulkBatchSize - մեկ անցումում մշակվող նկարների քանակը
+	bulkBatchSize = 100
+	// bulkSyncLimit - սրանից շատ նկարների դեպքում գործողությունը կատարվում է ֆոնում
+	bulkSyncLimit = 200
+	// bulkMaxItems - մեկ հարցման նկարների առավելագույն քանակը
+	bulkMaxItems = 10000
+	// bulkJobRetention - ավարտված ֆոնային աշխատանքի արդյունքը պահվում է այսքան ժամանակ
+	bulkJobRetention = 1 * time.Hour
+)
+
+// jobTypeBulkOperation - ֆոնային զանգվածային գործողություն, Payload-ը BulkOperation-ի ID-ն է
+const jobTypeBulkOperation = "bulk_operation"
+
+const (
+	bulkStatusPending   = "pending"
+	bulkStatusRunning   = "running"
+	bulkStatusCompleted = "completed"
+	// bulkStatusFailed - հերթի աշխատանքի բոլոր փորձերը ձախողվել են, մշակված խմբաքանակների արդյունքները պահպանված են
+	bulkStatusFailed = "failed"
+)
+
+const (
+	bulkItemSuccess  = "success"
+	bulkItemFailed   = "failed"
+	bulkItemNotFound = "not_found"
+)
+
+// bulkRequest - Զանգվածային գործողության հարցումը
+type bulkRequest struct {
+	Operation string     `json:"operation"`
+	IDs       []string   `json:"ids"`
+	Query     *bulkQuery `json:"query"`
+	Tags      []string   `json:"tags"`
+	Album     string     `json:"album"`
+	// Permanent - ջնջել ընդմիշտ՝ շրջանցելով աղբամանը
+	Permanent bool `json:"permanent"`
+}
+
+// bulkQuery - Նկարների ընտրություն ID-ների փոխարեն
+type bulkQuery struct {
+	Tag string `json:"tag"`
+	// Attributes - ֆիլտրեր ցանկի հարցման ձևաչափով, օր.՝ "attr.license=cc-by&attr.rating>=4"
+	Attributes string `json:"attributes"`
+
+	// filters - Attributes-ի վերլուծված ֆիլտրերը, լրացվում է validateBulkRequest-ում
+	filters []attributeFilter
+}
+
+// bulkItemResult - Մեկ նկարի արդյունքը
+type bulkItemResult struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// BulkOperation - Ֆոնային զանգվածային գործողությունը, որը կատարվում է հերթի աշխատանքով և
+// շարունակվում է վերագործարկումից հետո վերջին ավարտված խմբաքանակից
+type BulkOperation struct {
+	ID        string `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	Operation string `json:"operation" gorm:"type:varchar(20);not null"`
+	// Request - bulkRequest-ը JSON տեսքով՝ արդեն ընտրված ID-ներով
+	Request    string     `json:"-" gorm:"type:mediumtext;not null"`
+	Status     string     `json:"status" gorm:"type:varchar(20);not null"`
+	Total      int        `json:"total" gorm:"not null"`
+	Processed  int        `json:"processed" gorm:"not null;default:0"`
+	CreatedAt  time.Time  `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt  time.Time  `json:"updatedAt" gorm:"autoUpdateTime"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty" gorm:"type:datetime"`
+}
+
+// BulkOperationResult - Ֆոնային գործողության մեկ նկարի արդյունքը
+type BulkOperationResult struct {
+	ID              uint   `gorm:"primaryKey;autoIncrement"`
+	BulkOperationID string `gorm:"type:varchar(36);index;not null"`
+	ImageID         string `gorm:"type:varchar(36);not null"`
+	Status          string `gorm:"type:varchar(20);not null"`
+	Error           string `gorm:"type:varchar(255)"`
+}
+
+// bulkImagesHandler - Թեգավորում, թեգերի հեռացում, ալբոմին ավելացում կամ ջնջում բազմաթիվ նկարների համար
+func bulkImagesHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	if !dbAvailable() {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Տվյալների բազան հասանելի չէ"})
+		return
+	}
+
+	var request bulkRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Անվավեր հարցում"})
+		return
+	}
+	if message := validateBulkRequest(&request); message != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": message})
+		return
+	}
+
+	ids, err := resolveBulkIDs(ctx, request)
+	if err != nil {
+		slog.ErrorContext(ctx, "Սխալ զանգվածային գործողության նկարներն ընտրելիս", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Չհաջողվեց ընտրել նկարները"})
+		return
+	}
+	if len(ids) > bulkMaxItems {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Չափազանց շատ նկարներ մեկ հարցման համար"})
+		return
+	}
+
+	// Մեծ գործողությունները կատարվում են ֆոնում, հաճախորդը հետևում է վիճակին
+	if len(ids) > bulkSyncLimit {
+		operation, err := enqueueBulkOperation(ctx, request, ids)
+		if err != nil {
+			slog.ErrorContext(ctx, "Սխալ զանգվածային գործողությունը հերթագրելիս", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Չհաջողվեց գործարկել գործողությունը"})
+			return
+		}
+		c.Header("Location", "/api/images/bulk/"+operation.ID)
+		c.JSON(http.StatusAccepted, gin.H{"jobId": operation.ID, "status": operation.Status, "total": operation.Total})
+		return
+	}
+
+	results := runBulkOperation(ctx, request, ids, nil)
+	c.JSON(http.StatusOK, gin.H{
+		"results": results,
+		"summary": summarizeBulkResults(results),
+	})
+}
+
+// getBulkJobHandler - Ֆոնային զանգվածային գործողության վիճակի ստացում
+func getBulkJobHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	if !dbAvailable() {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Տվյալների բազան հասանելի չէ"})
+		return
+	}
+
+	var operation BulkOperation
+	if err := db.WithContext(ctx).First(&operation, "id = ?", c.Param("jobId")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Աշխատանքը չի գտնվել"})
+		return
+	}
+
+	var rows []BulkOperationResult
+	if err := db.WithContext(ctx).Where("bulk_operation_id = ?", operation.ID).Order("id").Find(&rows).Error; err != nil {
+		slog.ErrorContext(ctx, "Սխալ գործողության արդյունքները ստանալիս", "bulk_job_id", operation.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Չհաջողվեց ստանալ արդյունքները"})
+		return
+	}
+	results := make([]bulkItemResult, 0, len(rows))
+	for _, row := range rows {
+		results = append(results, bulkItemResult{ID: row.ImageID, Status: row.Status, Error: row.Error})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":         operation.ID,
+		"operation":  operation.Operation,
+		"status":     operation.Status,
+		"total":      operation.Total,
+		"processed":  operation.Processed,
+		"results":    results,
+		"summary":    summarizeBulkResults(results),
+		"createdAt":  operation.CreatedAt,
+		"finishedAt": operation.FinishedAt,
+	})
+}
+
+// validateBulkRequest - Ստուգել գործողությունը և դրա պարամետրերը
+func validateBulkRequest(request *bulkRequest) string {
+	request.Tags = parseTagList(strings.Join(request.Tags, ","))
+
+	switch request.Operation {
+	case bulkOperationAddTags, bulkOperationRemoveTags:
+		if len(request.Tags) == 0 {
+			return "Թեգերի ցանկը դատարկ է"
+		}
+	case bulkOperationAddToAlbum:
+		if strings.TrimSpace(request.Album) == "" {
+			return "Ալբոմի անունը բացակայում է"
+		}
+	case bulkOperationDelete:
+	default:
+		return "Անհայտ գործողություն"
+	}
+
+	if len(request.IDs) == 0 && request.Query == nil {
+		return "Անհրաժեշտ է ids կամ query"
+	}
+	if len(request.IDs) > 0 && request.Query != nil {
+		return "ids-ը և query-ն չեն կարող օգտագործվել միասին"
+	}
+	if request.Query != nil {
+		// Ընտրիչում անծանոթ պարամետրը սխալ է, որպեսզի տառասխալը չվերածվի ֆիլտրի բացակայության
+		// և գործողությունը չտարածվի ավելի շատ նկարների վրա
+		for _, pair := range strings.Split(request.Query.Attributes, "&") {
+			if pair != "" && !isAttributeFilterPair(pair) {
+				return fmt.Sprintf("անհայտ ֆիլտր %q, սպասվում էր attr.<բանալի><օպերատոր><արժեք>", pair)
+			}
+		}
+		filters, err := parseAttributeFilters(request.Query.Attributes)
+		if err != nil {
+			return err.Error()
+		}
+		// Դատարկ query-ն (կամ միայն «&»-երից բաղկացածը) կընտրեր բոլոր նկարները
+		if len(filters) == 0 && request.Query.Tag == "" {
+			return "query-ն պետք է պարունակի tag կամ attributes"
+		}
+		request.Query.filters = filters
+	}
+	return ""
+}
+
+// resolveBulkIDs - Ստանալ գործողության ենթակա նկարների ID-ները
+func resolveBulkIDs(ctx context.Context, request bulkRequest) ([]string, error) {
+	if len(request.IDs) > 0 {
+		seen := make(map[string]bool, len(request.IDs))
+		var ids []string
+		for _, id := range request.IDs {
+			if id != "" && !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+		return ids, nil
+	}
+
+	query := db.WithContext(ctx).Model(&Image{}).Scopes(activeImages, attributeFilters(request.Query.filters))
+	if request.Query.Tag != "" {
+		tagged := db.Model(&ImageTag{}).
+			Select("image_tags.image_id").
+			Joins("JOIN tags ON tags.id = image_tags.tag_id").
+			Where("tags.name = ?", request.Query.Tag)
+		query = query.Where("id IN (?)", tagged)
+	}
+
+	var ids []string
+	// Մեկով ավելին, որպեսզի հնարավոր լինի հայտնաբերել սահմանի գերազանցումը
+	err := query.Order("id").Limit(bulkMaxItems+1).Pluck("id", &ids).Error
+	return ids, err
+}
+
+// enqueueBulkOperation - Պահել գործողությունը և հերթագրել դրա կատարումը մեկ տրանզակցիայում
+func enqueueBulkOperation(ctx context.Context, request bulkRequest, ids []string) (*BulkOperation, error) {
+	request.IDs = ids
+	request.Query = nil
+	encoded, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	operation := &BulkOperation{
+		ID:        newImageID(),
+		Operation: request.Operation,
+		Request:   string(encoded),
+		Status:    bulkStatusPending,
+		Total:     len(ids),
+	}
+	err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := deleteExpiredBulkOperations(tx); err != nil {
+			return err
+		}
+		if err := tx.Create(operation).Error; err != nil {
+			return err
+		}
+		return tx.Create(&Job{
+			Type:        jobTypeBulkOperation,
+			Payload:     operation.ID,
+			Status:      jobStatusPending,
+			MaxAttempts: cfg.Jobs.MaxAttempts,
+			RunAt:       time.Now(),
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return operation, nil
+}
+
+// deleteExpiredBulkOperations - Ջնջել bulkJobRetention-ից ավելի վաղ ավարտված գործողությունները
+func deleteExpiredBulkOperations(tx *gorm.DB) error {
+	expired := tx.Session(&gorm.Session{NewDB: true}).Model(&BulkOperation{}).
+		Select("id").
+		Where("finished_at < ?", time.Now().Add(-bulkJobRetention))
+	if err := tx.Where("bulk_operation_id IN (?)", expired).Delete(&BulkOperationResult{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("finished_at < ?", time.Now().Add(-bulkJobRetention)).Delete(&BulkOperation{}).Error
+}
+
+// bulkOperationJob - Կատարել ֆոնային գործողությունը՝ սկսած առաջին չմշակված խմբաքանակից.
+// Յուրաքանչյուր խմբաքանակի արդյունքները և առաջընթացը պահվում են միասին
+func bulkOperationJob(ctx context.Context, job *Job) error {
+	var operation BulkOperation
+	err := db.WithContext(ctx).First(&operation, "id = ?", job.Payload).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if operation.Status == bulkStatusCompleted || operation.Status == bulkStatusFailed {
+		return nil
+	}
+
+	var request bulkRequest
+	if err := json.Unmarshal([]byte(operation.Request), &request); err != nil {
+		return err
+	}
+	ids := request.IDs[min(operation.Processed, len(request.IDs)):]
+
+	if err := db.WithContext(ctx).Model(&operation).Update("status", bulkStatusRunning).Error; err != nil {
+		return err
+	}
+
+	var saveErr error
+	runBulkOperation(ctx, request, ids, func(results []bulkItemResult) bool {
+		rows := make([]BulkOperationResult, 0, len(results))
+		for _, result := range results {
+			rows = append(rows, BulkOperationResult{BulkOperationID: operation.ID, ImageID: result.ID, Status: result.Status, Error: result.Error})
+		}
+		saveErr = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&rows).Error; err != nil {
+				return err
+			}
+			return tx.Model(&operation).Update("processed", gorm.Expr("processed + ?", len(results))).Error
+		})
+		return saveErr == nil
+	})
+	if saveErr != nil {
+		if job.Attempts >= job.MaxAttempts {
+			db.WithContext(ctx).Model(&operation).Updates(map[string]interface{}{"status": bulkStatusFailed, "finished_at": time.Now()})
+		}
+		return saveErr
+	}
+
+	now := time.Now()
+	if err := db.WithContext(ctx).Model(&operation).Updates(map[string]interface{}{"status": bulkStatusCompleted, "finished_at": &now}).Error; err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "Զանգվածային գործողությունն ավարտվեց", "bulk_job_id", operation.ID, "operation", operation.Operation, "total", operation.Total)
+	return nil
+}
+
+// runBulkOperation - Կատարել գործողությունը խմբաքանակներով, progress-ը կանչվում է յուրաքանչյուր խմբաքանակից հետո
+// և կարող է դադարեցնել մշակումը՝ վերադարձնելով false
+func runBulkOperation(ctx context.Context, request bulkRequest, ids []string, progress func([]bulkItemResult) bool) []bulkItemResult {
+	var album Album
+	if request.Operation == bulkOperationAddToAlbum {
+		var err error
+		if album, err = findOrCreateAlbum(db.WithContext(ctx), request.Album); err != nil {
+			slog.ErrorContext(ctx, "Սխալ ալբոմը ստեղծելիս", "album", request.Album, "error", err)
+			results := failBulkItems(ids, "Չհաջողվեց ստեղծել ալբոմը")
+			if progress != nil {
+				progress(results)
+			}
+			return results
+		}
+	}
+
+	var all []bulkItemResult
+	for start := 0; start < len(ids); start += bulkBatchSize {
+		end := min(start+bulkBatchSize, len(ids))
+		results := runBulkBatch(ctx, request, album, ids[start:end])
+		all = append(all, results...)
+		if progress != nil && !progress(results) {
+			break
+		}
+	}
+	return all
+}
+
+// runBulkBatch - Մեկ խմբաքանակի մշակում
+func runBulkBatch(ctx context.Context, request bulkRequest, album Album, ids []string) []bulkItemResult {
+	var images []Image
+	if err := db.WithContext(ctx).Scopes(activeImages).Where("id IN ?", ids).Find(&images).Error; err != nil {
+		slog.ErrorContext(ctx, "Սխալ նկարները ստանալիս", "error", err)
+		return failBulkItems(ids, "Չհաջողվեց ստանալ նկարները")
+	}
+
+	found := make(map[string]bool, len(images))
+	foundIDs := make([]string, 0, len(images))
+	for _, image := range images {
+		found[image.ID] = true
+		foundIDs = append(foundIDs, image.ID)
+	}
+
+	// Միայն գործողության ձախողումները, ID-ով
+	errs := make(map[string]string)
+	if len(images) > 0 {
+		errs = applyBulkOperation(ctx, request, album, images, foundIDs)
+	}
+
+	results := make([]bulkItemResult, 0, len(ids))
+	for _, id := range ids {
+		switch {
+		case !found[id]:
+			results = append(results, bulkItemResult{ID: id, Status: bulkItemNotFound})
+		case errs[id] != "":
+			results = append(results, bulkItemResult{ID: id, Status: bulkItemFailed, Error: errs[id]})
+		default:
+			results = append(results, bulkItemResult{ID: id, Status: bulkItemSuccess})
+		}
+	}
+	return results
+}
+
+// detachTags - Հեռացնել նկարից տրված թեգերը և վերադարձնել միայն այն անունները, որոնք նկարն իրոք ուներ
+func detachTags(tx *gorm.DB, imageID string, tagIDs []uint) ([]string, error) {
+	var removed []string
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&Tag{}).
+			Joins("JOIN image_tags ON image_tags.tag_id = tags.id").
+			Where("image_tags.image_id = ? AND tags.id IN ?", imageID, tagIDs).
+			Order("tags.name").
+			Pluck("tags.name", &removed).Error
+		if err != nil || len(removed) == 0 {
+			return err
+		}
+		return tx.Where("image_id = ? AND tag_id IN ?", imageID, tagIDs).Delete(&ImageTag{}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return removed, nil
+}
+
+// applyBulkOperation - Կատարել գործողությունը գտնված նկարների վրա և վերադարձնել ձախողումները ըստ ID-ի
+func applyBulkOperation(ctx context.Context, request bulkRequest, album Album, images []Image, foundIDs []string) map[string]string {
+	errs := make(map[string]string)
+	failAll := func(message string) {
+		for _, id := range foundIDs {
+			errs[id] = message
+		}
+	}
+
+	switch request.Operation {
+	case bulkOperationAddTags:
+		for _, image := range images {
+			addedTags, err := attachTags(db.WithContext(ctx), image.ID, request.Tags)
+			if err != nil {
+				slog.ErrorContext(ctx, "Սխալ նկարին թեգ ավելացնելիս", "image_id", image.ID, "error", err)
+				errs[image.ID] = "Չհաջողվեց ավելացնել թեգերը"
+				continue
+			}
+			if len(addedTags) > 0 {
+				emitEvent(ctx, eventImageTagged, newImageEventData(image, addedTags))
+			}
+		}
+	case bulkOperationRemoveTags:
+		var tagIDs []uint
+		if err := db.WithContext(ctx).Model(&Tag{}).Where("name IN ?", request.Tags).Pluck("id", &tagIDs).Error; err != nil {
+			slog.ErrorContext(ctx, "Սխալ թեգերը ստանալիս", "error", err)
+			failAll("Չհաջողվեց հեռացնել թեգերը")
+			break
+		}
+		if len(tagIDs) == 0 {
+			break
+		}
+		for _, image := range images {
+			removed, err := detachTags(db.WithContext(ctx), image.ID, tagIDs)
+			if err != nil {
+				slog.ErrorContext(ctx, "Սխալ նկարի թեգերը հեռացնելիս", "image_id", image.ID, "error", err)
+				errs[image.ID] = "Չհաջողվեց հեռացնել թեգերը"
+				continue
+			}
+			// Նկարը, որը չուներ այդ թեգերից ոչ մեկը, չի փոխվել
+			if len(removed) > 0 {
+				emitEvent(ctx, eventImageUntagged, newImageEventData(image, removed))
+			}
+		}
+	case bulkOperationAddToAlbum:
+		if err := addImagesToAlbum(db.WithContext(ctx), album.ID, foundIDs); err != nil {
+			slog.ErrorContext(ctx, "Սխալ նկարները ալբոմին ավելացնելիս", "album", request.Album, "error", err)
+			failAll("Չհաջողվեց ավելացնել ալբոմին")
+		}
+	case bulkOperationDelete:
+		if request.Permanent {
+			for id, err := range purgeImages(ctx, images) {
+				slog.ErrorContext(ctx, "Սխալ նկարը ընդմիշտ ջնջելիս", "image_id", id, "error", err)
+				errs[id] = "Չհաջողվեց ջնջել նկարը"
+			}
+			for _, image := range images {
+				if errs[image.ID] == "" {
+					emitEvent(ctx, eventImageDeleted, newImageEventData(image, nil))
+				}
+			}
+			break
+		}
+		// Ինչպես deleteImageHandler-ում, նկարները տեղափոխվում են աղբաման
+		if err := db.WithContext(ctx).Where("id IN ?", foundIDs).Delete(&Image{}).Error; err != nil {
+			slog.ErrorContext(ctx, "Սխալ նկարները աղբաման տեղափոխելիս", "error", err)
+			failAll("Չհաջողվեց ջնջել նկարը")
+			break
+		}
+		for _, image := range images {
+			invalidatePresignedURL(image.ObjectKey)
+			emitEvent(ctx, eventImageDeleted, newImageEventData(image, nil))
+		}
+	}
+
+	return errs
+}
+
+// failBulkItems - Նշել խմբաքանակի բոլոր նկարները որպես ձախողված
+func failBulkItems(ids []string, message string) []bulkItemResult {
+	results := make([]bulkItemResult, 0, len(ids))
+	for _, id := range ids {
+		results = append(results, bulkItemResult{ID: id, Status: bulkItemFailed, Error: message})
+	}
+	return results
+}
+
+// summarizeBulkResults - Արդյունքների քանակն ըստ կարգավիճակի
+func summarizeBulkResults(results []bulkItemResult) map[string]int {
+	summary := map[string]int{bulkItemSuccess: 0, bulkItemFailed: 0, bulkItemNotFound: 0}
+	for _, result := range results {
+		summary[result.Status]++
+	}
+	return summary
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseAttributeFiltersSkipsOtherPairs(t *testing.T) {
+	filters, err := parseAttributeFilters("page=2&attr.license=cc-by&&attr.rating%3E%3D4&_=1")
+	if err != nil {
+		t.Fatalf("parseAttributeFilters: %v", err)
+	}
+	if len(filters) != 2 || filters[1] != (attributeFilter{key: "rating", operator: ">=", value: "4"}) {
+		t.Fatalf("ֆիլտրեր %+v", filters)
+	}
+}
+
+func TestBulkRequestRejectsQueryWithoutFilters(t *testing.T) {
+	setupTest(t)
+	for _, body := range []string{
+		`{"operation":"delete","query":{}}`,
+		`{"operation":"delete","query":{"attributes":"&&"}}`,
+		`{"operation":"delete","query":{"attributes":"license=cc-by"}}`,
+		`{"operation":"delete","query":{"attributes":"attr.license=cc-by&tag=x"}}`,
+		`{"operation":"delete","query":{"attributes":"atr.license=cc-by"}}`,
+		`{"operation":"delete","query":{"attributes":"attr.license"}}`,
+	} {
+		response := serve(http.MethodPost, "/api/images/bulk", "/api/images/bulk", bulkImagesHandler, strings.NewReader(body), "application/json")
+		if response.Code != http.StatusBadRequest {
+			t.Errorf("%s: կոդ %d, սպասվում էր 400", body, response.Code)
+		}
+	}
+}
+
+func TestBulkJobIsPersistedAndResumesFromProcessed(t *testing.T) {
+	fake := setupTest(t)
+	insertImage(t, fake, "img-000")
+	insertImage(t, fake, "img-150")
+
+	ids := make([]string, 0, bulkSyncLimit+50)
+	for i := 0; i < bulkSyncLimit+50; i++ {
+		ids = append(ids, fmt.Sprintf("img-%03d", i))
+	}
+	body, _ := json.Marshal(bulkRequest{Operation: bulkOperationAddTags, IDs: ids, Tags: []string{"cat"}})
+	response := serve(http.MethodPost, "/api/images/bulk", "/api/images/bulk", bulkImagesHandler, bytes.NewReader(body), "application/json")
+	if response.Code != http.StatusAccepted {
+		t.Fatalf("կոդ %d, սպասվում էր 202: %s", response.Code, response.Body)
+	}
+	var accepted struct {
+		JobID string `json:"jobId"`
+	}
+	json.Unmarshal(response.Body.Bytes(), &accepted)
+
+	var job Job
+	if err := db.First(&job, "type = ? AND payload = ?", jobTypeBulkOperation, accepted.JobID).Error; err != nil {
+		t.Fatalf("հերթում աշխատանք չկա: %v", err)
+	}
+
+	// Առաջին խմբաքանակը մշակվել է մինչև վերագործարկումը
+	db.Model(&BulkOperation{}).Where("id = ?", accepted.JobID).Update("processed", bulkBatchSize)
+	if err := bulkOperationJob(context.Background(), &job); err != nil {
+		t.Fatalf("bulkOperationJob: %v", err)
+	}
+
+	var operation BulkOperation
+	db.First(&operation, "id = ?", accepted.JobID)
+	if operation.Status != bulkStatusCompleted || operation.Processed != len(ids) || operation.FinishedAt == nil {
+		t.Fatalf("գործողություն %+v", operation)
+	}
+	var tagged int64
+	db.Model(&ImageTag{}).Count(&tagged)
+	if tagged != 1 {
+		t.Fatalf("թեգավորված է %d նկար, սպասվում էր միայն img-150-ը", tagged)
+	}
+
+	response = serve(http.MethodGet, "/api/images/bulk/:jobId", "/api/images/bulk/"+accepted.JobID, getBulkJobHandler, nil, "")
+	var status struct {
+		Status  string           `json:"status"`
+		Results []bulkItemResult `json:"results"`
+	}
+	json.Unmarshal(response.Body.Bytes(), &status)
+	if response.Code != http.StatusOK || status.Status != bulkStatusCompleted || len(status.Results) != len(ids)-bulkBatchSize {
+		t.Fatalf("կոդ %d, վիճակ %q, %d արդյունք", response.Code, status.Status, len(status.Results))
+	}
+
+	response = serve(http.MethodGet, "/api/images/bulk/:jobId", "/api/images/bulk/missing", getBulkJobHandler, nil, "")
+	if response.Code != http.StatusNotFound {
+		t.Fatalf("կոդ %d, սպասվում էր 404", response.Code)
+	}
+}
+
+func TestBulkRemoveTagsEmitsUntaggedOnlyForRemovedTags(t *testing.T) {
+	fake := setupTest(t)
+	insertImage(t, fake, "img-1")
+	insertImage(t, fake, "img-2")
+	attachTags(db, "img-1", []string{"cat", "dog"})
+	attachTags(db, "img-2", []string{"dog"})
+	attachTags(db, "img-3", []string{"bird"})
+	events := recordEventLog(t)
+
+	body := `{"operation":"remove_tags","ids":["img-1","img-2"],"tags":["cat","bird"]}`
+	response := serve(http.MethodPost, "/api/images/bulk", "/api/images/bulk", bulkImagesHandler, strings.NewReader(body), "application/json")
+	if response.Code != http.StatusOK {
+		t.Fatalf("կոդ %d: %s", response.Code, response.Body)
+	}
+
+	got := events()
+	if len(got) != 1 || got[0].Type != eventImageUntagged {
+		t.Fatalf("իրադարձություններ %+v, սպասվում էր միայն մեկ %s", got, eventImageUntagged)
+	}
+	// img-1-ը «bird» թեգ չուներ, այն չպետք է հայտնվի հեռացվածների մեջ
+	data := got[0].Data.(imageEventData)
+	if data.ImageID != "img-1" || !slices.Equal(data.Tags, []string{"cat"}) {
+		t.Fatalf("տվյալներ %+v, սպասվում էր img-1 [cat]", data)
+	}
+	names, _ := imageTagNames(db, "img-1")
+	if !slices.Equal(names, []string{"dog"}) {
+		t.Fatalf("img-1-ի թեգեր %v", names)
+	}
+}
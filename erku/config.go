@@ -0,0 +1,464 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// cfg - Ընթացիկ կարգավորումները, բեռնվում են մեկ անգամ գործարկման ժամանակ
+var cfg = defaultConfig()
+
+// Config - erku-ի բոլոր կարգավորումները: Աղբյուրների գերակայությունը՝
+// լռելյայն արժեքներ < ֆայլ (-config կամ CONFIG_FILE) < միջավայրի փոփոխականներ < հրամանի տողի դրոշներ
+type Config struct {
+	HTTP     HTTPConfig     `config:"http"`
+	Log      LogConfig      `config:"log"`
+	Database DatabaseConfig `config:"database"`
+	Storage  StorageConfig  `config:"storage"`
+	Auth     AuthConfig     `config:"auth"`
+	Jobs     JobsConfig     `config:"jobs"`
+	Trash    TrashConfig    `config:"trash"`
+	Scanner  ScannerConfig  `config:"scanner"`
+	Uploads  UploadsConfig  `config:"uploads"`
+	Health   HealthConfig   `config:"health"`
+}
+
+// HTTPConfig - HTTP սերվերի հասցեն և ժամանակային սահմանները
+type HTTPConfig struct {
+	Addr              string        `config:"addr" env:"HTTP_ADDR" help:"HTTP սերվերի հասցեն"`
+	ReadHeaderTimeout time.Duration `config:"read_header_timeout" env:"HTTP_READ_HEADER_TIMEOUT" help:"վերնագրերի ընթերցման ժամանակը"`
+	ReadTimeout       time.Duration `config:"read_timeout" env:"HTTP_READ_TIMEOUT" help:"հարցման ընթերցման ժամանակը"`
+	WriteTimeout      time.Duration `config:"write_timeout" env:"HTTP_WRITE_TIMEOUT" help:"պատասխանի գրման ժամանակը"`
+	IdleTimeout       time.Duration `config:"idle_timeout" env:"HTTP_IDLE_TIMEOUT" help:"keep-alive կապի անգործության ժամանակը"`
+	MaxHeaderBytes    int           `config:"max_header_bytes" env:"HTTP_MAX_HEADER_BYTES" help:"վերնագրերի առավելագույն չափը"`
+	ShutdownTimeout   time.Duration `config:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" help:"graceful shutdown-ի առավելագույն տևողությունը"`
+}
+
+// LogConfig - Լոգերի կարգավորումներ
+type LogConfig struct {
+	Level string `config:"level" env:"LOG_LEVEL" help:"լոգի մակարդակը (debug, info, warn, error)"`
+}
+
+// DatabaseConfig - MySQL կապակցում
+type DatabaseConfig struct {
+	Host                 string        `config:"host" env:"DB_HOST" help:"MySQL-ի հասցեն"`
+	User                 string        `config:"user" env:"DB_USER" help:"MySQL օգտատեր"`
+	Password             string        `config:"password" env:"DB_PASSWORD" secret:"true" help:"MySQL գաղտնաբառ"`
+	Name                 string        `config:"name" env:"DB_NAME" help:"տվյալների բազայի անունը"`
+	ReconnectInterval    time.Duration `config:"reconnect_interval" env:"DB_RECONNECT_INTERVAL" help:"վերակապակցման առաջին ընդմիջումը"`
+	ReconnectMaxInterval time.Duration `config:"reconnect_max_interval" env:"DB_RECONNECT_MAX_INTERVAL" help:"վերակապակցման առավելագույն ընդմիջումը"`
+}
+
+// StorageConfig - AWS S3 կամ S3-համատեղելի պահեստ (MinIO)
+type StorageConfig struct {
+	Endpoint         string `config:"endpoint" env:"MINIO_ENDPOINT" help:"S3-համատեղելի պահեստի URL-ը (օր.՝ MinIO), դատարկ՝ AWS S3"`
+	Region           string `config:"region" env:"AWS_REGION" help:"S3 ռեգիոնը, դատարկ՝ AWS պրոֆիլից"`
+	AddressingStyle  string `config:"addressing_style" env:"S3_ADDRESSING_STYLE" help:"bucket-ի հասցեավորումը (auto, path, virtual)"`
+	Bucket           string `config:"bucket" env:"BUCKET_NAME" help:"bucket-ի անունը"`
+	UploadDir        string `config:"upload_dir" env:"UPLOAD_DIR" help:"նկարների նախածանցը bucket-ում"`
+	AccessKey        string `config:"access_key" env:"AWS_ACCESS_KEY_ID" help:"access key, դատարկ՝ AWS-ի լռելյայն credential chain"`
+	SecretKey        string `config:"secret_key" env:"AWS_SECRET_ACCESS_KEY" secret:"true" help:"secret key"`
+	SessionToken     string `config:"session_token" env:"AWS_SESSION_TOKEN" secret:"true" help:"ժամանակավոր credential-ների session token"`
+	Versioning       bool   `config:"versioning" env:"BUCKET_VERSIONING" help:"միացնել bucket-ի տարբերակավորումը"`
+	QuarantinePrefix string `config:"quarantine_prefix" env:"QUARANTINE_PREFIX" help:"մեկուսացված նկարների նախածանցը"`
+}
+
+// AuthConfig - API և MinIO ծանուցումների թոքեններ
+type AuthConfig struct {
+	APIToken          string `config:"api_token" env:"API_TOKEN" secret:"true" help:"պաշտպանված մարշրուտների թոքենը"`
+	MinIOWebhookToken string `config:"minio_webhook_token" env:"MINIO_WEBHOOK_TOKEN" secret:"true" help:"MinIO ծանուցումների թոքենը"`
+}
+
+// JobsConfig - Ֆոնային աշխատանքների հերթ
+type JobsConfig struct {
+	Concurrency        int           `config:"concurrency" env:"JOB_CONCURRENCY" help:"հերթի աշխատողների քանակը"`
+	PollInterval       time.Duration `config:"poll_interval" env:"JOB_POLL_INTERVAL" help:"դատարկ հերթի ստուգման ընդմիջումը"`
+	MaxAttempts        int           `config:"max_attempts" env:"JOB_MAX_ATTEMPTS" help:"աշխատանքի փորձերի առավելագույն քանակը"`
+	WebhookMaxAttempts int           `config:"webhook_max_attempts" env:"WEBHOOK_MAX_ATTEMPTS" help:"webhook առաքման փորձերի առավելագույն քանակը"`
+}
+
+// TrashConfig - Աղբաման
+type TrashConfig struct {
+	Retention     time.Duration `config:"retention" env:"TRASH_RETENTION" help:"ջնջված նկարների պահպանման ժամկետը"`
+	PurgeInterval time.Duration `config:"purge_interval" env:"TRASH_PURGE_INTERVAL" help:"աղբամանի մաքրման հաճախականությունը"`
+}
+
+// ScannerConfig - clamd սկաներ, անջատված է, եթե հասցեն դատարկ է
+type ScannerConfig struct {
+	ClamdAddress string        `config:"clamd_address" env:"CLAMD_ADDRESS" help:"clamd-ի հասցեն (օր.՝ localhost:3310)"`
+	ClamdTimeout time.Duration `config:"clamd_timeout" env:"CLAMD_TIMEOUT" help:"մեկ ստուգման առավելագույն տևողությունը"`
+}
+
+// UploadsConfig - Վերբեռնումներ և bucket ծանուցումներ
+type UploadsConfig struct {
+	BatchConcurrency int           `config:"batch_concurrency" env:"BATCH_UPLOAD_CONCURRENCY" help:"խմբաքանակի զուգահեռ վերբեռնումները"`
+	BucketEventDelay time.Duration `config:"bucket_event_delay" env:"BUCKET_EVENT_DELAY" help:"MinIO ծանուցման մշակման հետաձգումը"`
+	Expiry           time.Duration `config:"expiry" env:"UPLOAD_EXPIRY" help:"անգործուն tus վերբեռնման ժամկետը"`
+	SweepInterval    time.Duration `config:"sweep_interval" env:"UPLOAD_SWEEP_INTERVAL" help:"լքված վերբեռնումների մաքրման հաճախականությունը"`
+}
+
+// HealthConfig - Readiness ստուգումներ
+type HealthConfig struct {
+	CheckTimeout time.Duration `config:"check_timeout" env:"HEALTH_CHECK_TIMEOUT" help:"կախվածության ստուգման առավելագույն տևողությունը"`
+}
+
+// defaultConfig - Լռելյայն կարգավորումները
+func defaultConfig() Config {
+	return Config{
+		HTTP: HTTPConfig{
+			Addr:              ":8080",
+			ReadHeaderTimeout: defaultReadHeaderTimeout,
+			ReadTimeout:       defaultReadTimeout,
+			WriteTimeout:      defaultWriteTimeout,
+			IdleTimeout:       defaultIdleTimeout,
+			MaxHeaderBytes:    defaultMaxHeaderBytes,
+			ShutdownTimeout:   defaultShutdownTimeout,
+		},
+		Log: LogConfig{Level: "info"},
+		Database: DatabaseConfig{
+			Host:                 "localhost:3306",
+			User:                 "root",
+			Password:             "java",
+			Name:                 "minio_gallery",
+			ReconnectInterval:    defaultDBReconnectInterval,
+			ReconnectMaxInterval: defaultDBReconnectMaxInterval,
+		},
+		Storage: StorageConfig{
+			AddressingStyle:  addressingStyleAuto,
+			Bucket:           "images",
+			UploadDir:        defaultUploadDir,
+			QuarantinePrefix: defaultQuarantinePrefix,
+		},
+		Jobs: JobsConfig{
+			Concurrency:        defaultJobConcurrency,
+			PollInterval:       defaultJobPollInterval,
+			MaxAttempts:        defaultJobMaxAttempts,
+			WebhookMaxAttempts: defaultJobMaxAttempts,
+		},
+		Trash: TrashConfig{
+			Retention:     defaultTrashRetention,
+			PurgeInterval: defaultTrashPurgeInterval,
+		},
+		Scanner: ScannerConfig{ClamdTimeout: defaultClamdTimeout},
+		Uploads: UploadsConfig{
+			BatchConcurrency: defaultBatchConcurrency,
+			BucketEventDelay: defaultBucketEventDelay,
+			Expiry:           defaultUploadExpiry,
+			SweepInterval:    defaultUploadSweepInterval,
+		},
+		Health: HealthConfig{CheckTimeout: defaultHealthCheckTimeout},
+	}
+}
+
+// configField - Մեկ կարգավորում՝ իր ֆայլի բանալով, միջավայրի փոփոխականով և դրոշով
+type configField struct {
+	key    string // "database.host"
+	env    string
+	help   string
+	secret bool
+	value  reflect.Value
+}
+
+// flagName - Դրոշի անունը ֆայլի բանալուց. "database.host" -> "database-host"
+func (f configField) flagName() string {
+	return strings.NewReplacer(".", "-", "_", "-").Replace(f.key)
+}
+
+// configFields - Config-ի բոլոր դաշտերը ֆայլի բանալիների կարգով
+func configFields(c *Config) []configField {
+	var fields []configField
+	sections := reflect.ValueOf(c).Elem()
+	for i := 0; i < sections.NumField(); i++ {
+		section := sections.Type().Field(i).Tag.Get("config")
+		values := sections.Field(i)
+		for j := 0; j < values.NumField(); j++ {
+			tag := values.Type().Field(j).Tag
+			fields = append(fields, configField{
+				key:    section + "." + tag.Get("config"),
+				env:    tag.Get("env"),
+				help:   tag.Get("help"),
+				secret: tag.Get("secret") == "true",
+				value:  values.Field(j),
+			})
+		}
+	}
+	return fields
+}
+
+// set - Վերլուծել տեքստային արժեքը դաշտի տիպով
+func (f configField) set(raw string) error {
+	switch f.value.Interface().(type) {
+	case time.Duration:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("սպասվում է տևողություն (օր.՝ 30s, 5m), ստացվել է %q", raw)
+		}
+		f.value.SetInt(int64(d))
+	case int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("սպասվում է ամբողջ թիվ, ստացվել է %q", raw)
+		}
+		f.value.SetInt(int64(n))
+	case bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("սպասվում է true կամ false, ստացվել է %q", raw)
+		}
+		f.value.SetBool(b)
+	default:
+		f.value.SetString(raw)
+	}
+	return nil
+}
+
+// display - Արժեքը տպելու համար, գաղտնիքները թաքցված
+func (f configField) display() interface{} {
+	if f.secret && f.value.String() != "" {
+		return "REDACTED"
+	}
+	if d, ok := f.value.Interface().(time.Duration); ok {
+		return d.String()
+	}
+	return f.value.Interface()
+}
+
+// loadConfig - Բեռնել կարգավորումները բոլոր աղբյուրներից: Վերադարձնում է դրոշներից հետո մնացած արգումենտները
+// (ենթահրամանը): Բոլոր սխալները հավաքվում են միասին, որպեսզի դրանք ուղղվեն մեկ անգամից
+func loadConfig(args []string) (Config, []string, error) {
+	c := defaultConfig()
+	fields := configFields(&c)
+
+	flags := flag.NewFlagSet("erku", flag.ContinueOnError)
+	configPath := flags.String("config", os.Getenv("CONFIG_FILE"), "YAML կամ TOML կարգավորումների ֆայլ")
+	flagValues := make(map[string]string)
+	for _, field := range fields {
+		usage := field.help
+		if field.env != "" {
+			usage += " (" + field.env + ")"
+		}
+		flags.Func(field.flagName(), usage, func(value string) error {
+			flagValues[field.key] = value
+			return nil
+		})
+	}
+	if err := flags.Parse(args); err != nil {
+		return c, nil, err
+	}
+
+	var errs []error
+	if *configPath != "" {
+		values, err := readConfigFile(*configPath)
+		if err != nil {
+			return c, nil, err
+		}
+		known := make(map[string]bool, len(fields))
+		for _, field := range fields {
+			known[field.key] = true
+			if raw, ok := values[field.key]; ok {
+				if err := field.set(raw); err != nil {
+					errs = append(errs, fmt.Errorf("%s: %s: %w", *configPath, field.key, err))
+				}
+			}
+		}
+		for key := range values {
+			if !known[key] {
+				errs = append(errs, fmt.Errorf("%s: անհայտ կարգավորում %q", *configPath, key))
+			}
+		}
+	}
+
+	// Դատարկ արժեքով փոփոխականը նույնպես գերակայում է ֆայլին, որպեսզի հնարավոր լինի մաքրել գաղտնիքը
+	for _, field := range fields {
+		if field.env == "" {
+			continue
+		}
+		if raw, ok := os.LookupEnv(field.env); ok {
+			if err := field.set(raw); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", field.env, err))
+			}
+		}
+	}
+
+	for _, field := range fields {
+		if raw, ok := flagValues[field.key]; ok {
+			if err := field.set(raw); err != nil {
+				errs = append(errs, fmt.Errorf("-%s: %w", field.flagName(), err))
+			}
+		}
+	}
+
+	return c, flags.Args(), errors.Join(errs...)
+}
+
+// readConfigFile - Կարդալ YAML կամ TOML ֆայլը և հարթեցնել այն "բաժին.բանալի" տեսքի
+func readConfigFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("կարգավորումների ֆայլը չի կարդացվում: %w", err)
+	}
+
+	var document map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &document)
+	case ".toml":
+		err = toml.Unmarshal(data, &document)
+	default:
+		return nil, fmt.Errorf("%s: անհայտ ձևաչափ, սպասվում է .yaml, .yml կամ .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	values := make(map[string]string)
+	for section, content := range document {
+		entries, ok := content.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: %q բաժինը պետք է լինի բանալի-արժեք զույգերի խումբ", path, section)
+		}
+		for key, value := range entries {
+			values[section+"."+key] = fmt.Sprint(value)
+		}
+	}
+	return values, nil
+}
+
+// validate - Ստուգել կարգավորումների իմաստային ճշտությունը
+func (c *Config) validate() error {
+	var errs []error
+	for _, field := range configFields(c) {
+		switch value := field.value.Interface().(type) {
+		case time.Duration:
+			if value <= 0 {
+				errs = append(errs, fmt.Errorf("%s: պետք է լինի դրական, ստացվել է %s", field.key, value))
+			}
+		case int:
+			if value <= 0 {
+				errs = append(errs, fmt.Errorf("%s: պետք է լինի դրական, ստացվել է %d", field.key, value))
+			}
+		}
+	}
+
+	if _, err := parseLogLevel(c.Log.Level); err != nil {
+		errs = append(errs, fmt.Errorf("log.level: %w", err))
+	}
+	if c.Storage.Endpoint != "" {
+		if endpoint, err := url.Parse(c.Storage.Endpoint); err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+			errs = append(errs, fmt.Errorf("storage.endpoint: սպասվում է http(s) URL, ստացվել է %q", c.Storage.Endpoint))
+		}
+	}
+	switch c.Storage.AddressingStyle {
+	case addressingStyleAuto, addressingStylePath, addressingStyleVirtual:
+	default:
+		errs = append(errs, fmt.Errorf("storage.addressing_style: սպասվում է auto, path կամ virtual, ստացվել է %q", c.Storage.AddressingStyle))
+	}
+	// Ստատիկ բանալիները տրվում են զույգով, հակառակ դեպքում օգտագործվում է credential chain-ը
+	if (c.Storage.AccessKey == "") != (c.Storage.SecretKey == "") {
+		errs = append(errs, errors.New("storage.access_key և storage.secret_key: պետք է տրվեն միասին կամ երկուսն էլ դատարկ լինեն"))
+	}
+	required := map[string]string{
+		"storage.bucket":     c.Storage.Bucket,
+		"storage.upload_dir": c.Storage.UploadDir,
+		"database.host":      c.Database.Host,
+		"database.name":      c.Database.Name,
+	}
+	for _, field := range configFields(c) {
+		if value, ok := required[field.key]; ok && value == "" {
+			hint := ""
+			if field.env != "" {
+				hint = " (կարգավորեք " + field.env + ")"
+			}
+			errs = append(errs, fmt.Errorf("%s: պարտադիր է%s", field.key, hint))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// runConfigCommand - `erku config print [-format yaml|toml]` ենթահրամանը, վերադարձնում է ելքի կոդը
+func runConfigCommand(c Config, args []string, out io.Writer) int {
+	if len(args) == 0 || args[0] != "print" {
+		fmt.Fprintln(os.Stderr, "Օգտագործում: erku [դրոշներ] config print [-format yaml|toml]")
+		return 2
+	}
+
+	flags := flag.NewFlagSet("config print", flag.ContinueOnError)
+	format := flags.String("format", "yaml", "ելքի ձևաչափը (yaml կամ toml)")
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	// Բաժինները և բանալիները տպվում են Config-ի դաշտերի կարգով
+	var sections []string
+	values := make(map[string][]configField)
+	for _, field := range configFields(&c) {
+		section, _, _ := strings.Cut(field.key, ".")
+		if _, ok := values[section]; !ok {
+			sections = append(sections, section)
+		}
+		values[section] = append(values[section], field)
+	}
+
+	var err error
+	switch *format {
+	case "yaml":
+		document := &yaml.Node{Kind: yaml.MappingNode}
+		for _, section := range sections {
+			entries := &yaml.Node{Kind: yaml.MappingNode}
+			for _, field := range values[section] {
+				var value yaml.Node
+				if err := value.Encode(field.display()); err != nil {
+					fmt.Fprintf(os.Stderr, "Սխալ կարգավորումները տպելիս: %v\n", err)
+					return 1
+				}
+				_, key, _ := strings.Cut(field.key, ".")
+				entries.Content = append(entries.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, &value)
+			}
+			document.Content = append(document.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: section}, entries)
+		}
+		encoder := yaml.NewEncoder(out)
+		encoder.SetIndent(2)
+		err = encoder.Encode(document)
+	case "toml":
+		for i, section := range sections {
+			if i > 0 {
+				fmt.Fprintln(out)
+			}
+			fmt.Fprintf(out, "[%s]\n", section)
+			for _, field := range values[section] {
+				value, err := toml.Marshal(map[string]interface{}{"v": field.display()})
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Սխալ կարգավորումները տպելիս: %v\n", err)
+					return 1
+				}
+				_, key, _ := strings.Cut(field.key, ".")
+				fmt.Fprintf(out, "%s = %s", key, strings.TrimPrefix(string(value), "v = "))
+			}
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "անհայտ ձևաչափ %q\n", *format)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Սխալ կարգավորումները տպելիս: %v\n", err)
+		return 1
+	}
+
+	if err := c.validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Կարգավորումները անվավեր են:\n%v\n", err)
+		return 1
+	}
+	return 0
+}
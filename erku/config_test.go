@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// unsetEnv - Հեռացնել փոփոխականը թեստի ընթացքում և վերականգնել այն հետո
+func unsetEnv(t *testing.T, key string) {
+	t.Helper()
+	t.Setenv(key, "")
+	os.Unsetenv(key)
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "erku.yaml")
+	err := os.WriteFile(file, []byte("database:\n  password: from-file\njobs:\n  concurrency: 3\nhttp:\n  shutdown_timeout: 20s\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		env          map[string]string
+		flags        []string
+		password     string
+		concurrency  int
+		shutdownWait time.Duration
+	}{
+		{
+			name:         "ֆայլ",
+			password:     "from-file",
+			concurrency:  3,
+			shutdownWait: 20 * time.Second,
+		},
+		{
+			name:         "միջավայրը գերակայում է ֆայլին",
+			env:          map[string]string{"DB_PASSWORD": "from-env", "JOB_CONCURRENCY": "5", "SHUTDOWN_TIMEOUT": "40s"},
+			password:     "from-env",
+			concurrency:  5,
+			shutdownWait: 40 * time.Second,
+		},
+		{
+			name:         "դրոշը գերակայում է միջավայրին",
+			env:          map[string]string{"DB_PASSWORD": "from-env", "JOB_CONCURRENCY": "5"},
+			flags:        []string{"-database-password", "from-flag", "-jobs-concurrency", "7"},
+			password:     "from-flag",
+			concurrency:  7,
+			shutdownWait: 20 * time.Second,
+		},
+		{
+			name:         "դատարկ փոփոխականը մաքրում է ֆայլի գաղտնիքը",
+			env:          map[string]string{"DB_PASSWORD": ""},
+			password:     "",
+			concurrency:  3,
+			shutdownWait: 20 * time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"CONFIG_FILE", "DB_PASSWORD", "JOB_CONCURRENCY", "SHUTDOWN_TIMEOUT"} {
+				unsetEnv(t, key)
+			}
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			c, args, err := loadConfig(append([]string{"-config", file}, append(tt.flags, "config", "print")...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.Database.Password != tt.password {
+				t.Errorf("database.password %q, սպասվում էր %q", c.Database.Password, tt.password)
+			}
+			if c.Jobs.Concurrency != tt.concurrency {
+				t.Errorf("jobs.concurrency %d, սպասվում էր %d", c.Jobs.Concurrency, tt.concurrency)
+			}
+			if c.HTTP.ShutdownTimeout != tt.shutdownWait {
+				t.Errorf("http.shutdown_timeout %s, սպասվում էր %s", c.HTTP.ShutdownTimeout, tt.shutdownWait)
+			}
+			if strings.Join(args, " ") != "config print" {
+				t.Errorf("մնացած արգումենտներ %q", args)
+			}
+		})
+	}
+}
+
+func TestLoadConfigReportsAllErrors(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "erku.toml")
+	if err := os.WriteFile(file, []byte("[jobs]\nconcurrency = \"many\"\nunknown = 1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	unsetEnv(t, "CONFIG_FILE")
+	t.Setenv("SHUTDOWN_TIMEOUT", "")
+
+	_, _, err := loadConfig([]string{"-config", file, "-trash-retention", "soon"})
+	if err == nil {
+		t.Fatal("սխալ չկա")
+	}
+	for _, want := range []string{"jobs.concurrency", `"jobs.unknown"`, "SHUTDOWN_TIMEOUT", "-trash-retention"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("սխալում բացակայում է %s: %v", want, err)
+		}
+	}
+}
+
+func TestConfigPrintRedactsSecrets(t *testing.T) {
+	c := defaultConfig()
+	c.Database.Password = "db-secret"
+	c.Storage.AccessKey = "access"
+	c.Storage.SecretKey = "s3-secret"
+	c.Auth.APIToken = "api-secret"
+
+	for _, format := range []string{"yaml", "toml"} {
+		t.Run(format, func(t *testing.T) {
+			var out bytes.Buffer
+			if code := runConfigCommand(c, []string{"print", "-format", format}, &out); code != 0 {
+				t.Fatalf("ելքի կոդ %d", code)
+			}
+			printed := out.String()
+			for _, secret := range []string{"db-secret", "s3-secret", "api-secret"} {
+				if strings.Contains(printed, secret) {
+					t.Errorf("գաղտնիքը %q տպվել է:\n%s", secret, printed)
+				}
+			}
+			if got := strings.Count(printed, "REDACTED"); got != 3 {
+				t.Errorf("REDACTED %d անգամ, սպասվում էր 3 (դատարկ գաղտնիքները չեն թաքցվում):\n%s", got, printed)
+			}
+			for _, want := range []string{"access", "minio_gallery", "10s"} {
+				if !strings.Contains(printed, want) {
+					t.Errorf("ելքում բացակայում է %q:\n%s", want, printed)
+				}
+			}
+		})
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		want   string // սխալի մեջ սպասվող բանալին, դատարկ՝ վավեր
+	}{
+		{name: "լռելյայն", modify: func(c *Config) {}},
+		{name: "ստատիկ բանալիներ", modify: func(c *Config) { c.Storage.AccessKey, c.Storage.SecretKey = "a", "b" }},
+		{name: "MinIO endpoint", modify: func(c *Config) { c.Storage.Endpoint = "http://localhost:9000" }},
+		{name: "առանց API թոքենի", modify: func(c *Config) { c.Auth.APIToken = "" }},
+		{name: "զրոյական տևողություն", modify: func(c *Config) { c.Trash.Retention = 0 }, want: "trash.retention"},
+		{name: "բացասական թիվ", modify: func(c *Config) { c.Jobs.Concurrency = -1 }, want: "jobs.concurrency"},
+		{name: "լոգի մակարդակ", modify: func(c *Config) { c.Log.Level = "loud" }, want: "log.level"},
+		{name: "endpoint առանց սխեմայի", modify: func(c *Config) { c.Storage.Endpoint = "localhost:9000" }, want: "storage.endpoint"},
+		{name: "հասցեավորում", modify: func(c *Config) { c.Storage.AddressingStyle = "dns" }, want: "storage.addressing_style"},
+		{name: "միայն access key", modify: func(c *Config) { c.Storage.AccessKey = "a" }, want: "storage.secret_key"},
+		{name: "bucket", modify: func(c *Config) { c.Storage.Bucket = "" }, want: "storage.bucket: պարտադիր է (կարգավորեք BUCKET_NAME)"},
+		{name: "տվյալների բազա", modify: func(c *Config) { c.Database.Host = "" }, want: "database.host"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := defaultConfig()
+			tt.modify(&c)
+			err := c.validate()
+			switch {
+			case tt.want == "" && err != nil:
+				t.Errorf("անսպասելի սխալ: %v", err)
+			case tt.want != "" && err == nil:
+				t.Errorf("սխալ չկա, սպասվում էր %s", tt.want)
+			case tt.want != "" && !strings.Contains(err.Error(), tt.want):
+				t.Errorf("սխալ %v, սպասվում էր %s", err, tt.want)
+			}
+		})
+	}
+}
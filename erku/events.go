@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	eventImageCreated  = "image.created"
+	eventImageDeleted  = "image.deleted"
+	eventImageTagged   = "image.tagged"
+	eventImageUntagged = "image.untagged"
+	eventImageRestored = "image.restored"
+)
+
+// event - Նկարների կյանքի ցիկլի իրադարձություն
+type event struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"createdAt"`
+	Data      interface{} `json:"data"`
+}
+
+// imageEventData - Նկարի իրադարձության տվյալները
+type imageEventData struct {
+	ImageID     string   `json:"imageId"`
+	Name        string   `json:"name,omitempty"`
+	Size        int64    `json:"size,omitempty"`
+	ContentType string   `json:"contentType,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+}
+
+// newImageEventData - Իրադարձության տվյալները նկարի գրառումից
+func newImageEventData(image Image, tags []string) imageEventData {
+	return imageEventData{
+		ImageID:     image.ID,
+		Name:        image.Name,
+		Size:        image.Size,
+		ContentType: image.ContentType,
+		Tags:        tags,
+	}
+}
+
+// emitEvent - Հրապարակել իրադարձությունը բոլոր բաժանորդներին
+func emitEvent(ctx context.Context, eventType string, data interface{}) {
+	e := event{
+		ID:        newImageID(),
+		Type:      eventType,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	}
+
+	bus.publish(e)
+	// Փոփոխությունն արդեն կատարված է, ուստի առաքումները հերթագրվում են նույնիսկ եթե հարցումն ընդհատվել է
+	enqueueWebhookDeliveries(context.WithoutCancel(ctx), e)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/gin-gonic/gin"
+)
+
+// defaultHealthCheckTimeout - Յուրաքանչյուր կախվածության ստուգման առավելագույն տևողությունը
+const defaultHealthCheckTimeout = 2 * time.Second
+
+const (
+	healthStatusOK   = "ok"
+	healthStatusFail = "fail"
+)
+
+// dependencyStatus - Մեկ կախվածության ստուգման արդյունքը
+type dependencyStatus struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latencyMs"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// healthzHandler - Liveness. պրոցեսն աշխատում է և պատասխանում է հարցումներին
+func healthzHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": healthStatusOK})
+}
+
+// readyzHandler - Readiness. բազան և bucket-ը հասանելի են, հակառակ դեպքում 503
+func readyzHandler(c *gin.Context) {
+	timeout := cfg.Health.CheckTimeout
+	checks := map[string]func(context.Context) error{
+		"database": checkDatabase,
+		"storage":  checkBucket,
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	results := make(map[string]dependencyStatus, len(checks))
+	ready := true
+	for name, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+			defer cancel()
+
+			started := time.Now()
+			err := check(ctx)
+			result := dependencyStatus{
+				Status:    healthStatusOK,
+				LatencyMs: float64(time.Since(started).Microseconds()) / 1000,
+			}
+			if err != nil {
+				result.Status = healthStatusFail
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			results[name] = result
+			ready = ready && err == nil
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	status, code := "ready", http.StatusOK
+	if !ready {
+		status, code = "unavailable", http.StatusServiceUnavailable
+	}
+	c.JSON(code, gin.H{"status": status, "checks": results})
+}
+
+// isProbePath - Մետրիկաների և առողջության ստուգումների հարցումները, որոնք չեն trace-վում
+// և գրանցվում են միայն debug մակարդակով
+func isProbePath(path string) bool {
+	return path == "/metrics" || path == "/healthz" || path == "/readyz"
+}
+
+// checkDatabase - Ping MySQL-ին. մինչև առաջին հաջող կապակցումը բազան համարվում է անհասանելի
+func checkDatabase(ctx context.Context) error {
+	if !dbAvailable() {
+		return errors.New("բազային կապակցումը դեռ հաստատված չէ")
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// checkBucket - HeadBucket MinIO-ում
+func checkBucket(ctx context.Context) error {
+	_, err := s3Client.HeadBucket(ctx, &s3.HeadBucketInput{
+		Bucket: aws.String(cfg.Storage.Bucket),
+	})
+	return err
+}
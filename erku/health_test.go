@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// readiness - GET /readyz պատասխանը
+func readiness(t *testing.T) (int, string, map[string]dependencyStatus) {
+	t.Helper()
+	response := serve(http.MethodGet, "/readyz", "/readyz", readyzHandler, nil, "")
+	var body struct {
+		Status string                      `json:"status"`
+		Checks map[string]dependencyStatus `json:"checks"`
+	}
+	if err := json.Unmarshal(response.Body.Bytes(), &body); err != nil {
+		t.Fatalf("պատասխան %s: %v", response.Body, err)
+	}
+	return response.Code, body.Status, body.Checks
+}
+
+func TestHealthz(t *testing.T) {
+	// Liveness-ը կախված չէ բազայից և պահեստից
+	previous := dbReady.Load()
+	dbReady.Store(false)
+	t.Cleanup(func() { dbReady.Store(previous) })
+
+	if response := serve(http.MethodGet, "/healthz", "/healthz", healthzHandler, nil, ""); response.Code != http.StatusOK {
+		t.Errorf("կոդ %d", response.Code)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(t *testing.T, fake *fakeS3)
+		code    int
+		status  string
+		failing string
+	}{
+		{name: "պատրաստ", setup: func(t *testing.T, fake *fakeS3) {}, code: http.StatusOK, status: "ready"},
+		{
+			name:    "bucket-ը անհասանելի է",
+			setup:   func(t *testing.T, fake *fakeS3) { fake.failOn("HEAD") },
+			code:    http.StatusServiceUnavailable,
+			status:  "unavailable",
+			failing: "storage",
+		},
+		{
+			name: "բազան դեռ կապակցված չէ",
+			setup: func(t *testing.T, fake *fakeS3) {
+				dbReady.Store(false)
+			},
+			code:    http.StatusServiceUnavailable,
+			status:  "unavailable",
+			failing: "database",
+		},
+		{
+			name: "բազան փակված է",
+			setup: func(t *testing.T, fake *fakeS3) {
+				sqlDB, _ := db.DB()
+				sqlDB.Close()
+			},
+			code:    http.StatusServiceUnavailable,
+			status:  "unavailable",
+			failing: "database",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := setupTest(t)
+			tt.setup(t, fake)
+
+			code, status, checks := readiness(t)
+			if code != tt.code || status != tt.status {
+				t.Fatalf("կոդ %d, կարգավիճակ %q, սպասվում էր %d և %q: %+v", code, status, tt.code, tt.status, checks)
+			}
+			for _, name := range []string{"database", "storage"} {
+				want := healthStatusOK
+				if name == tt.failing {
+					want = healthStatusFail
+				}
+				if checks[name].Status != want || (want == healthStatusFail) != (checks[name].Error != "") {
+					t.Errorf("%s: %+v, սպասվում էր %s", name, checks[name], want)
+				}
+			}
+		})
+	}
+}
+
+func TestReadyzTimesOutSlowDependency(t *testing.T) {
+	fake := setupTest(t)
+	cfg.Health.CheckTimeout = 50 * time.Millisecond
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+	fake.before = func(op, key string) {
+		if op == "HEAD" && key == "" {
+			<-release
+		}
+	}
+
+	started := time.Now()
+	code, _, checks := readiness(t)
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("readyz-ը սպասել է %s, սահմանը %s", elapsed, cfg.Health.CheckTimeout)
+	}
+	if code != http.StatusServiceUnavailable || checks["storage"].Status != healthStatusFail || checks["database"].Status != healthStatusOK {
+		t.Errorf("կոդ %d: %+v", code, checks)
+	}
+}
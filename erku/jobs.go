@@ -0,0 +1,439 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	jobStatusPending   = "pending"
+	jobStatusRunning   = "running"
+	jobStatusCompleted = "completed"
+	// jobStatusDead - բոլոր փորձերը ձախողվել են, աշխատանքը այլևս չի կրկնվում
+	jobStatusDead = "dead"
+)
+
+const (
+	processingStatusPending    = "pending"
+	processingStatusProcessing = "processing"
+	processingStatusReady      = "ready"
+	processingStatusFailed     = "failed"
+)
+
+// jobTypeProcessImage - վերբեռնումից հետո նկարի ստուգում, հեշի և չափերի հաշվարկ
+const jobTypeProcessImage = "process_image"
+
+// processImagePayloadNew - նոր նկարի մշակման Payload-ը. եթե նկարը սպասում է սկաներին,
+// image.created-ը հրապարակվում է միայն ստուգումից հետո
+const processImagePayloadNew = "new"
+
+const (
+	defaultJobConcurrency  = 2
+	defaultJobMaxAttempts  = 5
+	defaultJobPollInterval = 2 * time.Second
+	// jobBaseBackoff - առաջին կրկնության սպասման ժամանակը, յուրաքանչյուր հաջորդը կրկնապատկվում է
+	jobBaseBackoff = 5 * time.Second
+	jobMaxBackoff  = 1 * time.Hour
+	// jobLockTimeout - այսքանից երկար «running» մնացած աշխատանքը համարվում է լքված
+	jobLockTimeout = 10 * time.Minute
+)
+
+// errImageNotScanFailed - նկարը scan_failed վիճակում չէ
+var errImageNotScanFailed = errors.New("նկարը scan_failed վիճակում չէ")
+
+// jobHeartbeatInterval - որքան հաճախ է կատարվող աշխատանքը թարմացնում locked_at-ը, որպեսզի
+// երկար աշխատանքը (օր.՝ զանգվածային ջնջումը) jobLockTimeout-ից հետո չվերցվի երկրորդ անգամ
+var jobHeartbeatInterval = jobLockTimeout / 4
+
+// Job - Հերթի աշխատանքի մոդելը GORM-ի համար
+type Job struct {
+	ID      uint   `json:"id" gorm:"primaryKey;autoIncrement"`
+	Type    string `json:"type" gorm:"type:varchar(50);not null"`
+	ImageID string `json:"imageId,omitempty" gorm:"type:varchar(36);index"`
+	// Payload - աշխատանքի տեսակից կախված պարամետրը (օր.՝ webhook առաքման ID)
+	Payload     string     `json:"payload,omitempty" gorm:"type:varchar(255)"`
+	Status      string     `json:"status" gorm:"type:varchar(20);not null;default:pending;index:idx_jobs_claim,priority:1"`
+	Attempts    int        `json:"attempts" gorm:"not null;default:0"`
+	MaxAttempts int        `json:"maxAttempts" gorm:"not null"`
+	RunAt       time.Time  `json:"runAt" gorm:"type:datetime;not null;index:idx_jobs_claim,priority:2"`
+	LockedAt    *time.Time `json:"lockedAt,omitempty" gorm:"type:datetime"`
+	LastError   string     `json:"lastError,omitempty" gorm:"type:text"`
+	CreatedAt   time.Time  `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time  `json:"updatedAt" gorm:"autoUpdateTime"`
+}
+
+// jobHandlers - Աշխատանքի տեսակից դրա կատարողը
+var jobHandlers = map[string]func(context.Context, *Job) error{
+	jobTypeProcessImage:   processImageJob,
+	jobTypeDeliverWebhook: deliverWebhookJob,
+	jobTypeSyncObject:     syncObjectJob,
+	jobTypeBulkOperation:  bulkOperationJob,
+}
+
+// getJobHandler - Աշխատանքի վիճակի ստացում
+func getJobHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	if !dbAvailable() {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Տվյալների բազան հասանելի չէ"})
+		return
+	}
+
+	var job Job
+	if err := db.WithContext(ctx).First(&job, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Աշխատանքը չի գտնվել"})
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// rescanImageHandler - Նորից ուղարկել ստուգման այն նկարը, որի ստուգման բոլոր փորձերը ձախողվել են
+func rescanImageHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	id := c.Param("id")
+	if !dbAvailable() {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Տվյալների բազան հասանելի չէ"})
+		return
+	}
+
+	var image Image
+	if err := db.WithContext(ctx).First(&image, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Նկարը չի գտնվել"})
+		return
+	}
+
+	// Նոր նկարի image.created-ը դեռ չի հրապարակվել, ուստի պահպանվում է նախորդ աշխատանքի Payload-ը
+	var previous Job
+	db.WithContext(ctx).Where("type = ? AND image_id = ?", jobTypeProcessImage, image.ID).Order("id DESC").Limit(1).Find(&previous)
+
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&image).Where("status = ?", imageStatusScanFailed).Updates(map[string]interface{}{
+			"status":            imageStatusScanning,
+			"processing_status": processingStatusPending,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errImageNotScanFailed
+		}
+		return tx.Create(&Job{
+			Type:        jobTypeProcessImage,
+			ImageID:     image.ID,
+			Payload:     previous.Payload,
+			Status:      jobStatusPending,
+			MaxAttempts: cfg.Jobs.MaxAttempts,
+			RunAt:       time.Now(),
+		}).Error
+	})
+	if errors.Is(err, errImageNotScanFailed) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Նկարի ստուգումը չի ձախողվել"})
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Սխալ նկարը կրկին ստուգման ուղարկելիս", "image_id", image.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Չհաջողվեց ուղարկել նկարը ստուգման"})
+		return
+	}
+
+	c.Header("Location", "/api/images/"+image.ID)
+	c.JSON(http.StatusAccepted, gin.H{
+		"id":               image.ID,
+		"status":           imageStatusScanning,
+		"processingStatus": processingStatusPending,
+	})
+}
+
+// enqueueJob - Ավելացնել աշխատանք հերթում (տրված տրանզակցիայի մեջ)
+func enqueueJob(tx *gorm.DB, jobType, imageID string) (*Job, error) {
+	job := &Job{
+		Type:        jobType,
+		ImageID:     imageID,
+		Status:      jobStatusPending,
+		MaxAttempts: cfg.Jobs.MaxAttempts,
+		RunAt:       time.Now(),
+	}
+	if err := tx.Create(job).Error; err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// enqueueNewImageProcessing - Հերթագրել նոր ստեղծված նկարի մշակումը
+func enqueueNewImageProcessing(tx *gorm.DB, image *Image) error {
+	job := &Job{
+		Type:        jobTypeProcessImage,
+		ImageID:     image.ID,
+		Payload:     processImagePayloadNew,
+		Status:      jobStatusPending,
+		MaxAttempts: cfg.Jobs.MaxAttempts,
+		RunAt:       time.Now(),
+	}
+	return tx.Create(job).Error
+}
+
+// announceCreatedImage - Հրապարակել image.created միայն տեսանելի նկարի համար. ստուգվող նկարի
+// իրադարձությունը կհրապարակի processImageJob-ը
+func announceCreatedImage(ctx context.Context, image Image, tags []string) {
+	if image.Status == imageStatusActive {
+		emitEvent(ctx, eventImageCreated, newImageEventData(image, tags))
+	}
+}
+
+// enqueueImageProcessing - Նշել նկարը որպես չմշակված և ավելացնել մշակման աշխատանք.
+// Միացված սկաների դեպքում նոր բովանդակությունը թաքցվում է մինչև ստուգումը
+func enqueueImageProcessing(tx *gorm.DB, image *Image) error {
+	image.ProcessingStatus = processingStatusPending
+	updates := map[string]interface{}{"processing_status": processingStatusPending}
+	if image.Status == imageStatusActive {
+		image.Status = initialImageStatus()
+		updates["status"] = image.Status
+	}
+	if err := tx.Model(image).Updates(updates).Error; err != nil {
+		return err
+	}
+	_, err := enqueueJob(tx, jobTypeProcessImage, image.ID)
+	return err
+}
+
+// startJobWorkers - Գործարկել հերթի աշխատողները JOB_CONCURRENCY քանակով:
+// ctx-ի չեղարկումից հետո աշխատողներն ավարտում են ընթացիկ աշխատանքը և այլևս նորը չեն վերցնում
+func startJobWorkers(ctx context.Context) {
+	concurrency := cfg.Jobs.Concurrency
+	interval := cfg.Jobs.PollInterval
+	for i := 0; i < concurrency; i++ {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			for ctx.Err() == nil {
+				// Քանի դեռ հերթում կա աշխատանք, շարունակել առանց սպասելու.
+				// Մինչև բազային կապակցվելը աշխատողները պարզապես սպասում են
+				if !dbAvailable() || !runNextJob(context.Background()) {
+					select {
+					case <-ctx.Done():
+					case <-time.After(interval):
+					}
+				}
+			}
+		}()
+	}
+	slog.Info("Գործարկվել են հերթի աշխատողները", "concurrency", concurrency)
+}
+
+// runNextJob - Վերցնել և կատարել հերթի հաջորդ աշխատանքը, վերադարձնում է false, եթե հերթը դատարկ է
+func runNextJob(ctx context.Context) bool {
+	job, err := claimJob(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Սխալ հերթից աշխատանք վերցնելիս", "error", err)
+		return false
+	}
+	if job == nil {
+		return false
+	}
+
+	ctx, span := startJobSpan(ctx, job)
+	handler, ok := jobHandlers[job.Type]
+	if !ok {
+		err = fmt.Errorf("անհայտ աշխատանքի տեսակ %q", job.Type)
+	} else {
+		stop := keepJobLocked(ctx, job)
+		err = runJobHandler(ctx, handler, job)
+		stop()
+	}
+	finishJob(ctx, job, err)
+	endSpan(span, err)
+	return true
+}
+
+// runJobHandler - Կատարել աշխատանքը՝ խուսափելով խուճապից աշխատողի դադարեցումից
+func runJobHandler(ctx context.Context, handler func(context.Context, *Job) error, job *Job) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("panic: %v", recovered)
+		}
+	}()
+	return handler(ctx, job)
+}
+
+// keepJobLocked - Թարմացնել աշխատանքի locked_at-ը, քանի դեռ այն կատարվում է. վերադարձնում է կանգնեցման ֆունկցիան
+func keepJobLocked(ctx context.Context, job *Job) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(jobHeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				err := db.WithContext(ctx).Model(&Job{}).
+					Where("id = ? AND status = ?", job.ID, jobStatusRunning).
+					Update("locked_at", time.Now()).Error
+				if err != nil {
+					slog.WarnContext(ctx, "Սխալ աշխատանքի կողպեքը թարմացնելիս", "job_id", job.ID, "error", err)
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// claimJob - Վերցնել պատրաստ աշխատանքը, որպեսզի այլ աշխատող այն չվերցնի
+func claimJob(ctx context.Context) (*Job, error) {
+	var job Job
+	now := time.Now()
+
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("(status = ? AND run_at <= ?) OR (status = ? AND locked_at < ?)",
+				jobStatusPending, now, jobStatusRunning, now.Add(-jobLockTimeout)).
+			Order("run_at").
+			Limit(1).
+			Find(&job)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+
+		job.Status = jobStatusRunning
+		job.Attempts++
+		job.LockedAt = &now
+		return tx.Model(&job).Updates(map[string]interface{}{
+			"status":    job.Status,
+			"attempts":  job.Attempts,
+			"locked_at": job.LockedAt,
+		}).Error
+	})
+	if err != nil || job.ID == 0 {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// finishJob - Գրանցել աշխատանքի արդյունքը. ձախողման դեպքում՝ կրկնություն աճող ընդմիջումով կամ dead վիճակ
+func finishJob(ctx context.Context, job *Job, jobErr error) {
+	updates := map[string]interface{}{"locked_at": nil}
+
+	switch {
+	case jobErr == nil:
+		updates["status"] = jobStatusCompleted
+		updates["last_error"] = ""
+	case job.Attempts >= job.MaxAttempts:
+		slog.ErrorContext(ctx, "Աշխատանքը տեղափոխվեց dead վիճակ", "job_id", job.ID, "job_type", job.Type, "image_id", job.ImageID, "attempts", job.Attempts, "error", jobErr)
+		updates["status"] = jobStatusDead
+		updates["last_error"] = jobErr.Error()
+		if job.ImageID != "" {
+			db.WithContext(ctx).Model(&Image{}).Where("id = ?", job.ImageID).Update("processing_status", processingStatusFailed)
+		}
+		// Առանց ստուգման նկարը կմնար թաքցված ընդմիշտ, ուստի այն տեղափոխվում է տեսանելի վերջնական վիճակ
+		if job.Type == jobTypeProcessImage {
+			db.WithContext(ctx).Model(&Image{}).
+				Where("id = ? AND status = ?", job.ImageID, imageStatusScanning).
+				Update("status", imageStatusScanFailed)
+		}
+	default:
+		backoff := jobBaseBackoff << (job.Attempts - 1)
+		if backoff > jobMaxBackoff || backoff <= 0 {
+			backoff = jobMaxBackoff
+		}
+		slog.WarnContext(ctx, "Աշխատանքը ձախողվեց, կկրկնվի", "job_id", job.ID, "job_type", job.Type, "image_id", job.ImageID, "attempts", job.Attempts, "backoff", backoff.String(), "error", jobErr)
+		updates["status"] = jobStatusPending
+		updates["run_at"] = time.Now().Add(backoff)
+		updates["last_error"] = jobErr.Error()
+	}
+
+	if err := db.WithContext(ctx).Model(job).Updates(updates).Error; err != nil {
+		slog.ErrorContext(ctx, "Սխալ աշխատանքի արդյունքը պահելիս", "job_id", job.ID, "error", err)
+	}
+}
+
+// processImageJob - Ստուգել վերբեռնված նկարը սկաներով և հաշվել դրա հեշը և չափերը մեկ ընթերցմամբ
+func processImageJob(ctx context.Context, job *Job) error {
+	var image Image
+	err := db.WithContext(ctx).First(&image, "id = ?", job.ImageID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// Նկարը արդեն ջնջվել է, մշակելու բան չկա
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if image.Status != imageStatusActive && image.Status != imageStatusScanning {
+		return nil
+	}
+
+	db.WithContext(ctx).Model(&image).Update("processing_status", processingStatusProcessing)
+
+	object, err := s3Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(cfg.Storage.Bucket),
+		Key:    aws.String(image.ObjectKey),
+	})
+	if err != nil {
+		return err
+	}
+	defer object.Body.Close()
+
+	// Սկաները կարդում է ամբողջ հոսքը, իսկ հեշը և չափերի համար անհրաժեշտ սկիզբը հավաքվում են զուգահեռ
+	hasher := sha256.New()
+	header := &prefixBuffer{limit: scanHeaderSize}
+	reader := io.TeeReader(object.Body, io.MultiWriter(hasher, header))
+
+	result := scanResult{Clean: true}
+	if scanner != nil {
+		if result, err = scanner.Scan(ctx, reader); err != nil {
+			return err
+		}
+	}
+	if _, err := io.Copy(io.Discard, reader); err != nil {
+		return err
+	}
+
+	width, height := imageDimensions(bytes.NewReader(header.Bytes()))
+	updates := map[string]interface{}{
+		"hash":              hex.EncodeToString(hasher.Sum(nil)),
+		"width":             width,
+		"height":            height,
+		"processing_status": processingStatusReady,
+	}
+	activated := result.Clean && image.Status == imageStatusScanning
+	if activated {
+		updates["status"] = imageStatusActive
+	}
+	if err := db.WithContext(ctx).Model(&image).Updates(updates).Error; err != nil {
+		return err
+	}
+
+	// Նոր նկարը տեսանելի դարձավ հենց հիմա, ուստի բաժանորդներն այն տեսնում են առաջին անգամ
+	if activated && job.Payload == processImagePayloadNew {
+		image.Status = imageStatusActive
+		tags, err := imageTagNames(db.WithContext(ctx), image.ID)
+		if err != nil {
+			slog.ErrorContext(ctx, "Սխալ նկարի թեգերը ստանալիս", "image_id", image.ID, "error", err)
+		}
+		emitEvent(ctx, eventImageCreated, newImageEventData(image, tags))
+	}
+
+	if !result.Clean {
+		slog.WarnContext(ctx, "Նկարում հայտնաբերվել է վնասակար բովանդակություն, այն մեկուսացվում է", "image_id", image.ID, "signature", result.Signature)
+		return quarantineImage(ctx, &image, result.Signature)
+	}
+	return nil
+}
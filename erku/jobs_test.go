@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+)
+
+// registerJobHandler - Ժամանակավոր աշխատանքի տեսակ թեստի համար
+func registerJobHandler(t *testing.T, jobType string, handler func(context.Context, *Job) error) {
+	t.Helper()
+	jobHandlers[jobType] = handler
+	t.Cleanup(func() { delete(jobHandlers, jobType) })
+}
+
+// insertJob - Պատրաստ աշխատանք հերթում
+func insertJob(t *testing.T, jobType, imageID, payload string, maxAttempts int) Job {
+	t.Helper()
+	job := Job{Type: jobType, ImageID: imageID, Payload: payload, Status: jobStatusPending, MaxAttempts: maxAttempts, RunAt: time.Now()}
+	if err := db.Create(&job).Error; err != nil {
+		t.Fatalf("աշխատանք: %v", err)
+	}
+	return job
+}
+
+func TestRunNextJobRetriesWithBackoffAndThenGivesUp(t *testing.T) {
+	setupTest(t)
+	calls := 0
+	registerJobHandler(t, "test_flaky", func(ctx context.Context, job *Job) error {
+		calls++
+		return errInjected
+	})
+	job := insertJob(t, "test_flaky", "", "", 2)
+
+	if !runNextJob(context.Background()) {
+		t.Fatal("աշխատանքը չի վերցվել")
+	}
+	db.First(&job, job.ID)
+	if job.Status != jobStatusPending || job.Attempts != 1 || job.LastError != errInjected.Error() || !job.RunAt.After(time.Now()) {
+		t.Fatalf("առաջին ձախողումից հետո %+v", job)
+	}
+	// Սպասման ընթացքում աշխատանքը չի վերցվում
+	if runNextJob(context.Background()) {
+		t.Fatal("աշխատանքը վերցվել է մինչև run_at-ը")
+	}
+
+	db.Model(&job).Update("run_at", time.Now().Add(-time.Second))
+	runNextJob(context.Background())
+	db.First(&job, job.ID)
+	if job.Status != jobStatusDead || job.Attempts != 2 || calls != 2 {
+		t.Fatalf("վերջին փորձից հետո %+v, կանչեր %d", job, calls)
+	}
+}
+
+func TestRunNextJobCompletesSuccessfulJob(t *testing.T) {
+	setupTest(t)
+	registerJobHandler(t, "test_ok", func(ctx context.Context, job *Job) error { return nil })
+	job := insertJob(t, "test_ok", "", "", 3)
+
+	runNextJob(context.Background())
+	db.First(&job, job.ID)
+	if job.Status != jobStatusCompleted || job.LockedAt != nil {
+		t.Fatalf("աշխատանք %+v", job)
+	}
+}
+
+func TestJobHeartbeatPreventsSecondClaim(t *testing.T) {
+	setupTest(t)
+	previous := jobHeartbeatInterval
+	jobHeartbeatInterval = 10 * time.Millisecond
+	t.Cleanup(func() { jobHeartbeatInterval = previous })
+
+	var reclaimed *Job
+	var claimErr error
+	registerJobHandler(t, "test_long", func(ctx context.Context, job *Job) error {
+		// Աշխատանքը կատարվում է jobLockTimeout-ից երկար
+		db.Model(&Job{}).Where("id = ?", job.ID).Update("locked_at", time.Now().Add(-2*jobLockTimeout))
+		time.Sleep(5 * jobHeartbeatInterval)
+		reclaimed, claimErr = claimJob(ctx)
+		return nil
+	})
+	insertJob(t, "test_long", "", "", 3)
+
+	runNextJob(context.Background())
+	if claimErr != nil {
+		t.Fatalf("claimJob: %v", claimErr)
+	}
+	if reclaimed != nil {
+		t.Fatalf("կատարվող աշխատանքը վերցվել է երկրորդ անգամ: %+v", reclaimed)
+	}
+}
+
+func TestProcessImageFinalFailureLeavesVisibleScanFailedImage(t *testing.T) {
+	fake := setupTest(t)
+	scanner = newClamdStub(t, "").scanner()
+	image := insertImage(t, fake, "img-1")
+	db.Model(&image).Updates(map[string]interface{}{"status": imageStatusScanning, "processing_status": processingStatusPending})
+	cfg.Jobs.MaxAttempts = 1
+	if err := enqueueNewImageProcessing(db, &image); err != nil {
+		t.Fatalf("enqueueNewImageProcessing: %v", err)
+	}
+	events := recordEvents(t)
+
+	runNextJob(context.Background())
+
+	var current Image
+	db.First(&current, "id = ?", image.ID)
+	if current.Status != imageStatusScanFailed || current.ProcessingStatus != processingStatusFailed {
+		t.Fatalf("նկար %s/%s, սպասվում էր %s/%s", current.Status, current.ProcessingStatus, imageStatusScanFailed, processingStatusFailed)
+	}
+
+	response := serve(http.MethodGet, "/api/images/:id", "/api/images/img-1", getImageByIdHandler, nil, "")
+	var body map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &body)
+	if response.Code != http.StatusOK || body["status"] != imageStatusScanFailed || body["url"] != nil {
+		t.Fatalf("կոդ %d, պատասխան %s", response.Code, response.Body)
+	}
+
+	// Ստուգումը կրկնվում է, և հաջողության դեպքում նոր նկարը հրապարակվում է
+	scanner = newClamdStub(t, "stream: OK").scanner()
+	response = serve(http.MethodPost, "/api/images/:id/rescan", "/api/images/img-1/rescan", rescanImageHandler, nil, "")
+	if response.Code != http.StatusAccepted {
+		t.Fatalf("rescan: կոդ %d: %s", response.Code, response.Body)
+	}
+	runNextJob(context.Background())
+
+	db.First(&current, "id = ?", image.ID)
+	if current.Status != imageStatusActive {
+		t.Fatalf("վիճակ %q, սպասվում էր %q", current.Status, imageStatusActive)
+	}
+	if got := events(); !slices.Equal(got, []string{eventImageCreated}) {
+		t.Fatalf("իրադարձություններ %v", got)
+	}
+
+	response = serve(http.MethodPost, "/api/images/:id/rescan", "/api/images/img-1/rescan", rescanImageHandler, nil, "")
+	if response.Code != http.StatusConflict {
+		t.Fatalf("ակտիվ նկարի rescan: կոդ %d, սպասվում էր 409", response.Code)
+	}
+}
+
+func TestFinishJobKeepsOtherImageStatuses(t *testing.T) {
+	fake := setupTest(t)
+	image := insertImage(t, fake, "img-1")
+	job := insertJob(t, jobTypeProcessImage, image.ID, "", 1)
+	job.Attempts = 1
+
+	finishJob(context.Background(), &job, errors.New("ձախողում"))
+
+	var current Image
+	db.First(&current, "id = ?", image.ID)
+	if current.Status != imageStatusActive || current.ProcessingStatus != processingStatusFailed {
+		t.Fatalf("նկար %s/%s", current.Status, current.ProcessingStatus)
+	}
+}
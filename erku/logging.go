@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// requestIDHeader - Հարցման ID-ի վերնագիրը, ընդունվում է հաճախորդից և վերադարձվում պատասխանում
+const requestIDHeader = "X-Request-ID"
+
+// slowQueryThreshold - Այս տևողությունից երկար SQL հարցումները գրանցվում են warn մակարդակով
+const slowQueryThreshold = 200 * time.Millisecond
+
+// logLevel - Լոգերի ընթացիկ մակարդակը, փոփոխելի աշխատանքի ընթացքում
+var logLevel = new(slog.LevelVar)
+
+// requestInfoKey - context-ում հարցման տվյալների բանալին
+type requestInfoKey struct{}
+
+type requestInfo struct {
+	id    string
+	route string
+}
+
+// contextHandler - Ավելացնել request_id, route և trace_id դաշտերը context-ից յուրաքանչյուր գրառմանը
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if info, ok := ctx.Value(requestInfoKey{}).(requestInfo); ok {
+		r.AddAttrs(slog.String("request_id", info.id))
+		if info.route != "" {
+			r.AddAttrs(slog.String("route", info.route))
+		}
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(slog.String("trace_id", sc.TraceID().String()), slog.String("span_id", sc.SpanID().String()))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// initLogging - JSON լոգեր stderr-ում, մակարդակը log.level կարգավորումից (debug, info, warn, error).
+// Ստանդարտ log փաթեթի գրառումները նույնպես անցնում են նույն handler-ով
+func initLogging() {
+	// Մակարդակն արդեն ստուգված է validate-ում
+	level, _ := parseLogLevel(cfg.Log.Level)
+	logLevel.Set(level)
+
+	slog.SetDefault(slog.New(contextHandler{slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: logLevel})}))
+}
+
+// parseLogLevel - Վերլուծել լոգի մակարդակի անունը
+func parseLogLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(name))); err != nil {
+		return slog.LevelInfo, fmt.Errorf("անհայտ լոգի մակարդակ %q", name)
+	}
+	return level, nil
+}
+
+// requestIDMiddleware - Վերցնել X-Request-ID-ն հաճախորդից կամ ստեղծել նորը, վերադարձնել այն պատասխանում
+// և պահել context-ում, որպեսզի հարցման բոլոր լոգերը ստանան նույն ID-ն
+func requestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		c.Header(requestIDHeader, id)
+
+		ctx := context.WithValue(c.Request.Context(), requestInfoKey{}, requestInfo{id: id, route: c.FullPath()})
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// validRequestID - Ընդունել միայն կարճ, տպվող ASCII ID-ներ, որպեսզի հաճախորդը չկարողանա աղավաղել լոգերը
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(buf)
+}
+
+// accessLogMiddleware - Յուրաքանչյուր HTTP հարցման համար մեկ կառուցվածքային գրառում
+func accessLogMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		started := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		case isProbePath(c.Request.URL.Path):
+			level = slog.LevelDebug
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Float64("duration_ms", float64(time.Since(started).Microseconds())/1000),
+			slog.Int("bytes", max(c.Writer.Size(), 0)),
+			slog.String("client_ip", c.ClientIP()),
+		}
+		if imageID := requestImageID(c); imageID != "" {
+			attrs = append(attrs, slog.String("image_id", imageID))
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("error", c.Errors.String()))
+		}
+		slog.LogAttrs(c.Request.Context(), level, "HTTP հարցում", attrs...)
+	}
+}
+
+// requestImageID - Հարցման հետ կապված նկարի ID-ն՝ մարշրուտից կամ X-Image-Id պատասխանի վերնագրից
+func requestImageID(c *gin.Context) string {
+	if strings.HasPrefix(c.FullPath(), "/api/images/:id") {
+		return c.Param("id")
+	}
+	return c.Writer.Header().Get("X-Image-Id")
+}
+
+// recoveryMiddleware - Խուճապը գրանցել որպես կառուցվածքային սխալ՝ stack trace-ով
+func recoveryMiddleware() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, recovered any) {
+		slog.ErrorContext(c.Request.Context(), "Խուճապ հարցումը մշակելիս",
+			"error", fmt.Sprint(recovered),
+			"stack", string(debug.Stack()),
+		)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Սերվերի ներքին սխալ"})
+	})
+}
+
+// getLogLevelHandler - Լոգի ընթացիկ մակարդակի ստացում
+func getLogLevelHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"level": logLevel.Level().String()})
+}
+
+// setLogLevelHandler - Լոգի մակարդակի փոփոխում առանց վերագործարկման
+func setLogLevelHandler(c *gin.Context) {
+	var request struct {
+		Level string `json:"level" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Պարտադիր է level դաշտը"})
+		return
+	}
+
+	level, err := parseLogLevel(request.Level)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	previous := logLevel.Level()
+	logLevel.Set(level)
+	slog.InfoContext(c.Request.Context(), "Լոգի մակարդակը փոխվեց", "from", previous.String(), "to", level.String())
+	c.JSON(http.StatusOK, gin.H{"level": level.String()})
+}
+
+// gormSlogLogger - GORM-ի լոգերը slog-ով. սխալները error մակարդակով, դանդաղ հարցումները warn,
+// իսկ debug մակարդակում՝ բոլոր հարցումները
+type gormSlogLogger struct{}
+
+func (l gormSlogLogger) LogMode(gormlogger.LogLevel) gormlogger.Interface {
+	return l
+}
+
+func (gormSlogLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	slog.InfoContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+func (gormSlogLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	slog.WarnContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+func (gormSlogLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	slog.ErrorContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+// ParamsFilter - Չգրանցել հարցումների պարամետրերի արժեքները, ինչպես tracing-ում
+func (gormSlogLogger) ParamsFilter(ctx context.Context, sql string, params ...interface{}) (string, []interface{}) {
+	return sql, nil
+}
+
+func (gormSlogLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	elapsed := time.Since(begin)
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+		sql, rows := fc()
+		slog.ErrorContext(ctx, "SQL հարցման սխալ", "error", err, "sql", sql, "rows", rows, "duration_ms", elapsed.Milliseconds())
+	case elapsed > slowQueryThreshold:
+		sql, rows := fc()
+		slog.WarnContext(ctx, "Դանդաղ SQL հարցում", "sql", sql, "rows", rows, "duration_ms", elapsed.Milliseconds())
+	case logLevel.Level() <= slog.LevelDebug:
+		sql, rows := fc()
+		slog.DebugContext(ctx, "SQL հարցում", "sql", sql, "rows", rows, "duration_ms", elapsed.Milliseconds())
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// captureLogs - Ուղղել slog-ի գրառումները բուֆեր՝ նույն contextHandler-ով, ինչ initLogging-ը
+func captureLogs(t *testing.T, level slog.Level) *bytes.Buffer {
+	t.Helper()
+	var buffer bytes.Buffer
+	previousLogger, previousLevel := slog.Default(), logLevel.Level()
+	logLevel.Set(level)
+	slog.SetDefault(slog.New(contextHandler{slog.NewJSONHandler(&buffer, &slog.HandlerOptions{Level: logLevel})}))
+	t.Cleanup(func() {
+		slog.SetDefault(previousLogger)
+		logLevel.Set(previousLevel)
+	})
+	return &buffer
+}
+
+// logRecords - Բուֆերի JSON գրառումները
+func logRecords(t *testing.T, buffer *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buffer.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("գրառումը JSON չէ: %s", line)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// loggedRouter - Մարշրուտիզատոր նույն լոգավորման միջնաշերտերով, ինչ main.go-ում
+func loggedRouter() *gin.Engine {
+	router := gin.New()
+	router.Use(recoveryMiddleware(), requestIDMiddleware(), accessLogMiddleware())
+	router.GET("/api/images/:id", func(c *gin.Context) {
+		slog.InfoContext(c.Request.Context(), "նկարի ընթերցում")
+		c.Status(http.StatusNotFound)
+	})
+	router.GET("/healthz", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/fail", func(c *gin.Context) { c.Status(http.StatusBadGateway) })
+	router.GET("/panic", func(c *gin.Context) { panic("boom") })
+	return router
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	captureLogs(t, slog.LevelInfo)
+	router := loggedRouter()
+
+	tests := []struct {
+		name, header string
+		keep         bool
+	}{
+		{"հաճախորդի ID", "abc-123", true},
+		{"բացակայող", "", false},
+		{"բացատով", "abc 123", false},
+		{"նոր տողով", "abc\n{\"level\":\"ERROR\"}", false},
+		{"չափազանց երկար", strings.Repeat("a", 129), false},
+	}
+	for _, tt := range tests {
+		request := httptest.NewRequest(http.MethodGet, "/api/images/img-1", nil)
+		if tt.header != "" {
+			request.Header.Set(requestIDHeader, tt.header)
+		}
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		got := recorder.Header().Get(requestIDHeader)
+		if tt.keep && got != tt.header {
+			t.Errorf("%s: ID %q, սպասվում էր %q", tt.name, got, tt.header)
+		}
+		if !tt.keep && (got == tt.header || len(got) != 32) {
+			t.Errorf("%s: ID %q, սպասվում էր նոր 32 նիշանոց ID", tt.name, got)
+		}
+	}
+}
+
+func TestRequestLogsShareRequestID(t *testing.T) {
+	buffer := captureLogs(t, slog.LevelInfo)
+	request := httptest.NewRequest(http.MethodGet, "/api/images/img-1", nil)
+	request.Header.Set(requestIDHeader, "req-1")
+	loggedRouter().ServeHTTP(httptest.NewRecorder(), request)
+
+	records := logRecords(t, buffer)
+	if len(records) != 2 {
+		t.Fatalf("%d գրառում, սպասվում էր հանդլերի և access log-ի", len(records))
+	}
+	for _, record := range records {
+		if record["request_id"] != "req-1" || record["route"] != "/api/images/:id" {
+			t.Errorf("%v: request_id %v, route %v", record["msg"], record["request_id"], record["route"])
+		}
+	}
+	access := records[1]
+	if access["level"] != "WARN" || access["status"] != float64(http.StatusNotFound) || access["image_id"] != "img-1" || access["path"] != "/api/images/img-1" {
+		t.Errorf("access log %v", access)
+	}
+}
+
+func TestAccessLogLevels(t *testing.T) {
+	tests := []struct {
+		target string
+		level  slog.Level
+		want   string
+	}{
+		// Probe-երը info մակարդակում չեն աղմկում
+		{"/healthz", slog.LevelInfo, ""},
+		{"/healthz", slog.LevelDebug, "DEBUG"},
+		{"/fail", slog.LevelInfo, "ERROR"},
+	}
+	for _, tt := range tests {
+		buffer := captureLogs(t, tt.level)
+		recorder := httptest.NewRecorder()
+		loggedRouter().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+		var access map[string]any
+		for _, record := range logRecords(t, buffer) {
+			if record["msg"] == "HTTP հարցում" {
+				access = record
+			}
+		}
+		if tt.want == "" {
+			if access != nil {
+				t.Errorf("%s (%s): անսպասելի գրառում %v", tt.target, tt.level, access)
+			}
+			continue
+		}
+		if access == nil || access["level"] != tt.want {
+			t.Errorf("%s (%s): access log %v, սպասվում էր %s", tt.target, tt.level, access, tt.want)
+		}
+	}
+}
+
+func TestRecoveryLogsPanic(t *testing.T) {
+	buffer := captureLogs(t, slog.LevelInfo)
+	request := httptest.NewRequest(http.MethodGet, "/panic", nil)
+	request.Header.Set(requestIDHeader, "req-1")
+	recorder := httptest.NewRecorder()
+	loggedRouter().ServeHTTP(recorder, request)
+
+	if recorder.Code != http.StatusInternalServerError || !strings.Contains(recorder.Body.String(), "error") {
+		t.Errorf("կոդ %d, պատասխան %s", recorder.Code, recorder.Body.String())
+	}
+	records := logRecords(t, buffer)
+	if len(records) == 0 || records[0]["error"] != "boom" || records[0]["request_id"] != "req-1" {
+		t.Fatalf("խուճապի գրառում %v", records)
+	}
+	if stack, _ := records[0]["stack"].(string); !strings.Contains(stack, "logging_test.go") {
+		t.Errorf("stack trace-ը չի պարունակում խուճապի տեղը")
+	}
+}
+
+func TestSetLogLevel(t *testing.T) {
+	captureLogs(t, slog.LevelInfo)
+	set := func(body string) int {
+		return serve(http.MethodPut, "/api/admin/log-level", "/api/admin/log-level", setLogLevelHandler, strings.NewReader(body), "application/json").Code
+	}
+
+	for _, body := range []string{`{}`, `{"level":"verbose"}`, `not json`} {
+		if code := set(body); code != http.StatusBadRequest {
+			t.Errorf("%s: կոդ %d, սպասվում էր 400", body, code)
+		}
+	}
+	if logLevel.Level() != slog.LevelInfo {
+		t.Fatalf("սխալ հարցումը փոխել է մակարդակը %s-ի", logLevel.Level())
+	}
+	if code := set(`{"level":" DEBUG "}`); code != http.StatusOK || logLevel.Level() != slog.LevelDebug {
+		t.Errorf("կոդ %d, մակարդակ %s", code, logLevel.Level())
+	}
+	response := serve(http.MethodGet, "/api/admin/log-level", "/api/admin/log-level", getLogLevelHandler, nil, "")
+	if !strings.Contains(response.Body.String(), `"DEBUG"`) {
+		t.Errorf("GET: %s", response.Body.String())
+	}
+}
+
+func TestGormLoggerOmitsQueryParameters(t *testing.T) {
+	fake := setupTest(t)
+	insertImage(t, fake, "img-1")
+	buffer := captureLogs(t, slog.LevelInfo)
+	session := db.Session(&gorm.Session{Logger: gormSlogLogger{}})
+
+	var image Image
+	session.First(&image, "id = ?", "secret-id")
+	if buffer.Len() != 0 {
+		t.Errorf("info մակարդակում արագ հարցումը և ErrRecordNotFound-ը չպետք է գրանցվեն:\n%s", buffer)
+	}
+
+	logLevel.Set(slog.LevelDebug)
+	session.First(&image, "id = ?", "secret-id")
+	session.Exec("SELECT * FROM missing_table WHERE id = ?", "secret-id")
+	records := logRecords(t, buffer)
+	if len(records) != 2 || records[0]["level"] != "DEBUG" || records[1]["level"] != "ERROR" {
+		t.Fatalf("գրառումներ %v", records)
+	}
+	if strings.Contains(buffer.String(), "secret-id") {
+		t.Errorf("պարամետրի արժեքը հայտնվել է լոգում:\n%s", buffer)
+	}
+}
@@ -2,41 +2,68 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
 	"fmt"
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
-	"log"
+	"io"
+	"log/slog"
 	"math/rand"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
 )
 
 var (
 	s3Client *s3.Client
 	db       *gorm.DB
+	// dbReady - Նշանակվում է db-ից հետո, երբ կապակցումը և միգրացիան հաջողվել են
+	dbReady atomic.Bool
 )
 
 const defaultUploadDir = "uploads/"
 
+const (
+	defaultDBReconnectInterval    = time.Second
+	defaultDBReconnectMaxInterval = 30 * time.Second
+)
+
 // Image - նկարի մոդելը GORM-ի համար
 type Image struct {
-	ID          string    `json:"id" gorm:"primaryKey;type:varchar(36)"`
-	ObjectKey   string    `json:"objectKey" gorm:"type:varchar(255);not null"`
-	Name        string    `json:"name" gorm:"type:varchar(255);not null"`
-	Size        int64     `json:"size" gorm:"type:bigint;not null"`
-	ContentType string    `json:"contentType" gorm:"type:varchar(100);not null"`
-	UploadedAt  time.Time `json:"uploadedAt" gorm:"type:datetime;not null"`
-	CreatedAt   time.Time `json:"createdAt" gorm:"autoCreateTime"`
-	UpdatedAt   time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+	ID          string `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	ObjectKey   string `json:"objectKey" gorm:"type:varchar(255);not null"`
+	Name        string `json:"name" gorm:"type:varchar(255);not null"`
+	Size        int64  `json:"size" gorm:"type:bigint;not null"`
+	ContentType string `json:"contentType" gorm:"type:varchar(100);not null"`
+	Hash        string `json:"hash" gorm:"type:varchar(64);index"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	Caption     string `json:"caption" gorm:"type:varchar(1000)"`
+	AltText     string `json:"altText" gorm:"type:varchar(500)"`
+	Status      string `json:"-" gorm:"type:varchar(20);not null;default:active;index"`
+	// ScanResult - սկաների հայտնաբերած ստորագրությունը մեկուսացված նկարների համար
+	ScanResult string `json:"-" gorm:"type:varchar(255)"`
+	// ProcessingStatus - վերբեռնումից հետո ֆոնային մշակման վիճակը
+	ProcessingStatus string         `json:"processingStatus" gorm:"type:varchar(20);not null;default:ready"`
+	UploadedAt       time.Time      `json:"uploadedAt" gorm:"type:datetime;not null"`
+	CreatedAt        time.Time      `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt        time.Time      `json:"updatedAt" gorm:"autoUpdateTime"`
+	DeletedAt        gorm.DeletedAt `json:"deletedAt,omitempty" gorm:"index"`
 }
 
 // Tag - թեգի մոդելը GORM-ի համար
@@ -56,147 +83,308 @@ type ImageTag struct {
 
 // ImageResponse - Պատկերի արձագանքի կառուցվածք API-ի համար
 type ImageResponse struct {
-	ID          string    `json:"id"`
-	URL         string    `json:"url"`
-	Name        string    `json:"name"`
-	Size        int64     `json:"size"`
-	ContentType string    `json:"contentType"`
-	UploadedAt  time.Time `json:"uploadedAt"`
-	Tags        []string  `json:"tags,omitempty"`
+	ID               string                 `json:"id"`
+	URL              string                 `json:"url"`
+	Name             string                 `json:"name"`
+	Size             int64                  `json:"size"`
+	ContentType      string                 `json:"contentType"`
+	Width            int                    `json:"width,omitempty"`
+	Height           int                    `json:"height,omitempty"`
+	Caption          string                 `json:"caption,omitempty"`
+	AltText          string                 `json:"altText,omitempty"`
+	Attributes       map[string]interface{} `json:"attributes,omitempty"`
+	ProcessingStatus string                 `json:"processingStatus"`
+	UploadedAt       time.Time              `json:"uploadedAt"`
+	UpdatedAt        time.Time              `json:"updatedAt"`
+	DeletedAt        *time.Time             `json:"deletedAt,omitempty"`
+	Tags             []string               `json:"tags,omitempty"`
 }
 
 func main() {
 	// Բեռնել .env ֆայլը
 	err := godotenv.Load()
-	if err != nil {
-		log.Printf("Զգուշացում: .env ֆայլը բեռնելու սխալ: %v", err)
-	}
-
-	// Ստանալ MinIO կարգավորումները միջավայրի փոփոխականներից
-	bucketName := getEnvWithDefault("BUCKET_NAME", "images")
-	accessKey := os.Getenv("AWS_ACCESS_KEY_ID")
-	secretKey := os.Getenv("AWS_SECRET_ACCESS_KEY")
-	endpointURL := getEnvWithDefault("MINIO_ENDPOINT", "http://localhost:9000")
 
-	// Ստուգել պարտադիր պարամետրերը
-	if accessKey == "" || secretKey == "" {
-		log.Fatal("Սխալ: MinIO access key և secret key պարտադիր են: Կարգավորեք AWS_ACCESS_KEY_ID և AWS_SECRET_ACCESS_KEY .env ֆայլում:")
+	// Կարգավորումները բեռնվում են .env-ից հետո, որպեսզի դրա փոփոխականները հասանելի լինեն
+	loaded, args, configErr := loadConfig(os.Args[1:])
+	if errors.Is(configErr, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if configErr != nil {
+		fmt.Fprintf(os.Stderr, "Սխալ կարգավորումներում:\n%v\n", configErr)
+		os.Exit(2)
+	}
+	cfg = loaded
+	if len(args) > 0 && args[0] == "config" {
+		os.Exit(runConfigCommand(cfg, args[1:], os.Stdout))
+	}
+	if err := cfg.validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Կարգավորումները անվավեր են:\n%v\n", err)
+		os.Exit(2)
 	}
 
-	// Կարգավորել MinIO հաճախորդին
-	customResolver := aws.EndpointResolverWithOptionsFunc(func(service, region string, options ...interface{}) (aws.Endpoint, error) {
-		return aws.Endpoint{
-			URL:               endpointURL,
-			HostnameImmutable: true,
-			SigningRegion:     "us-east-1",
-		}, nil
-	})
+	initLogging()
+	if err != nil {
+		slog.Warn("Զգուշացում: .env ֆայլը բեռնելու սխալ", "error", err)
+	}
 
-	cfg := aws.Config{
-		Credentials:                 credentials.NewStaticCredentialsProvider(accessKey, secretKey, ""),
-		EndpointResolverWithOptions: customResolver,
-		Region:                      "us-east-1",
-		RetryMaxAttempts:            3,
+	// OpenTelemetry-ն կարգավորվում է մինչև հաճախորդների ստեղծումը
+	shutdownTracing, err := initTracing(context.Background())
+	if err != nil {
+		slog.Error("Սխալ tracing-ը կարգավորելիս", "error", err)
+		os.Exit(1)
 	}
+	defer shutdownTracing(context.Background())
 
-	s3Client = s3.NewFromConfig(cfg, func(o *s3.Options) {
-		o.UsePathStyle = true // Կարևոր է MinIO համատեղելիության համար
-	})
+	// Կարգավորել S3 (կամ MinIO) հաճախորդին
+	bucketName := cfg.Storage.Bucket
+	s3Client, err = newS3Client(context.Background())
+	if err != nil {
+		slog.Error("Սխալ S3 հաճախորդը կարգավորելիս", "error", err)
+		os.Exit(1)
+	}
+	presignClient = s3.NewPresignClient(s3Client)
+	slog.Info("S3 հաճախորդը կարգավորված է", "region", s3Client.Options().Region, "endpoint", cfg.Storage.Endpoint, "path_style", s3Client.Options().UsePathStyle)
 
 	// MySQL + GORM կապակցում
 	initDB()
 
+	// Ենթահրամաններ, որոնք չեն գործարկում HTTP սերվերը
+	if len(args) > 0 && args[0] == "reconcile" {
+		os.Exit(runReconcile(args[1:]))
+	}
+
 	// Ստուգել արդյոք bucket-ը գոյություն ունի, ստեղծել եթե չկա
-	_, err = s3Client.HeadBucket(context.TODO(), &s3.HeadBucketInput{
+	// SIGINT/SIGTERM-ը դադարեցնում է ֆոնային աշխատողներին և սերվերը
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	_, err = s3Client.HeadBucket(ctx, &s3.HeadBucketInput{
 		Bucket: aws.String(bucketName),
 	})
 	if err != nil {
-		log.Printf("Bucket %s-ը գոյություն չունի, ստեղծվում է...", bucketName)
-		_, err = s3Client.CreateBucket(context.TODO(), &s3.CreateBucketInput{
-			Bucket: aws.String(bucketName),
-		})
-		if err != nil {
-			log.Fatalf("Չհաջողվեց ստեղծել bucket: %v", err)
+		slog.Info("Bucket-ը գոյություն չունի, ստեղծվում է...", "bucket", bucketName)
+		if err := createBucket(ctx, bucketName); err != nil {
+			slog.Error("Չհաջողվեց ստեղծել bucket", "bucket", bucketName, "error", err)
+			os.Exit(1)
 		}
 	}
 
+	// Միացնել տարբերակավորումը, եթե այն կարգավորված է
+	enableBucketVersioning(ctx, bucketName)
+
+	// Աղբամանի պարբերական մաքրում
+	startTrashPurger(ctx)
+	// Լքված վերբեռնումների պարբերական մաքրում
+	startUploadSweeper(ctx)
+	initScanner()
+	startJobWorkers(ctx)
+
 	// Կարգավորել Gin ռոութերը
-	router := gin.Default()
+	router := gin.New()
+	router.Use(recoveryMiddleware())
+	router.Use(otelgin.Middleware("erku", otelgin.WithFilter(func(r *http.Request) bool {
+		return !isProbePath(r.URL.Path)
+	})))
+	router.Use(metricsMiddleware())
+	router.Use(requestIDMiddleware(), accessLogMiddleware())
 
 	// CORS-ի պարզեցված կարգավորում
 	router.Use(cors.New(cors.Config{
 		AllowAllOrigins:  true,
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Length", "Content-Type", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Length", "Content-Type", "Authorization", "If-Match", "Range", "If-None-Match", "If-Modified-Since", "Tus-Resumable", "Upload-Length", "Upload-Metadata", "Upload-Offset", "X-Request-ID"},
+		ExposeHeaders:    []string{"Content-Length", "Content-Range", "Content-Disposition", "Accept-Ranges", "ETag", "Last-Modified", "Location", "Tus-Resumable", "Tus-Version", "Tus-Extension", "Tus-Max-Size", "Upload-Offset", "Upload-Length", "Upload-Expires", "X-Image-Id", "X-Request-ID"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
 
+	// MinIO-ի bucket ծանուցումներ (webhook target), հասանելի միայն ներքին ցանցից.
+	// Առանց թոքենի ցանկացած ոք կարող էր ներմուծել կամ թաքցնել նկարներ, ուստի մարշրուտը չի գրանցվում
+	if cfg.Auth.MinIOWebhookToken != "" {
+		router.POST("/internal/minio/events", requireBucketEventToken(), bucketEventsHandler)
+	} else {
+		slog.Warn("MINIO_WEBHOOK_TOKEN-ը կարգավորված չէ, MinIO ծանուցումներն անջատված են")
+	}
+
+	// Prometheus մետրիկաներ
+	router.GET("/metrics", requireAPIToken(), gin.WrapH(promhttp.Handler()))
+
+	// Liveness և readiness ստուգումներ
+	router.GET("/healthz", healthzHandler)
+	router.GET("/readyz", readyzHandler)
+
 	// API մարշրուտների կարգավորում
 	api := router.Group("/api")
 	{
 		// Նկարների հետ աշխատանքի մարշրուտներ
 		images := api.Group("/images")
 		{
-			images.GET("", getAllImagesHandler)             // Բոլոր նկարների ցանկի ստացում
-			images.GET("/:id", getImageByIdHandler)         // Կոնկրետ նկարի ստացում ID-ով
-			images.GET("/tags/:tag", getImagesByTagHandler) // Նկարների ստացում թեգով
-			images.POST("", uploadImageHandler)             // Նկարի վերբեռնում
-			images.DELETE("/:id", deleteImageHandler)       // Նկարի ջնջում
-			images.POST("/:id/tags", addTagsToImageHandler) // Նկարին թեգերի ավելացում
+			images.GET("", getAllImagesHandler)                                 // Բոլոր նկարների ցանկի ստացում
+			images.GET("/:id", getImageByIdHandler)                             // Կոնկրետ նկարի ստացում ID-ով
+			images.GET("/tags/:tag", getImagesByTagHandler)                     // Նկարների ստացում թեգով
+			images.POST("", uploadImageHandler)                                 // Նկարի վերբեռնում
+			images.POST("/batch", batchUploadHandler)                           // Բազմաթիվ ֆայլերի կամ արխիվի վերբեռնում
+			images.POST("/bulk", requireAPIToken(), bulkImagesHandler)          // Զանգվածային գործողություններ
+			images.GET("/bulk/:jobId", requireAPIToken(), getBulkJobHandler)    // Ֆոնային զանգվածային գործողության վիճակ
+			images.PATCH("/:id", updateImageMetadataHandler)                    // Նկարի տվյալների խմբագրում
+			images.DELETE("/:id", deleteImageHandler)                           // Նկարի ջնջում
+			images.POST("/:id/tags", addTagsToImageHandler)                     // Նկարին թեգերի ավելացում
+			images.POST("/:id/restore", requireAPIToken(), restoreImageHandler) // Նկարի վերականգնում աղբամանից
+			images.POST("/:id/rescan", rescanImageHandler)                      // Ձախողված ստուգման կրկնություն
+
+			// Նկարի բովանդակության տարբերակներ
+			images.PUT("/:id/content", replaceImageContentHandler)                      // Ֆայլի փոխարինում՝ պահպանելով ID-ն և թեգերը
+			images.GET("/:id/versions", getImageVersionsHandler)                        // Տարբերակների ցանկ
+			images.GET("/:id/versions/:versionId", getImageVersionHandler)              // Կոնկրետ տարբերակ
+			images.POST("/:id/versions/:versionId/restore", restoreImageVersionHandler) // Տարբերակի վերականգնում
+
+			images.GET("/:id/raw", requireMediaToken(), downloadImageHandler) // Նկարի բովանդակության պրոքսի
+		}
+
+		// Ընդհատվող վերբեռնումներ tus պրոտոկոլով
+		tus := api.Group("/uploads/tus", requireTusResumable())
+		{
+			tus.OPTIONS("", tusOptionsHandler)         // Սերվերի հնարավորություններ
+			tus.POST("", createTusUploadHandler)       // Նոր վերբեռնման ստեղծում
+			tus.HEAD("/:id", headTusUploadHandler)     // Ընթացիկ offset-ի ստացում
+			tus.PATCH("/:id", patchTusUploadHandler)   // Հաջորդ հատվածի ուղարկում
+			tus.DELETE("/:id", deleteTusUploadHandler) // Վերբեռնման չեղարկում
 		}
 
+		// Ուղիղ bucket վերբեռնումներ ստորագրված URL-ներով
+		api.POST("/uploads/presign", presignUploadHandler)       // Ստորագրված PUT/POST-ի ստացում
+		api.POST("/uploads/:id/complete", completeUploadHandler) // Վերբեռնման հաստատում
+
+		// Աղբաման
+		api.GET("/trash", requireAPIToken(), getTrashHandler) // Ջնջված նկարների ցանկի ստացում
+
 		// Թեգերի հետ աշխատանքի մարշրուտներ
 		api.GET("/tags", getAllTagsHandler) // Բոլոր թեգերի ցանկի ստացում
+
+		// Ալբոմներ
+		api.GET("/albums", getAllAlbumsHandler)                // Բոլոր ալբոմների ցանկի ստացում
+		api.GET("/albums/:name/images", getAlbumImagesHandler) // Ալբոմի նկարների ստացում
+
+		api.GET("/jobs/:id", getJobHandler) // Ֆոնային աշխատանքի վիճակ
+
+		// Փոփոխությունների ուղիղ հոսք
+		api.GET("/events", streamEventsHandler) // Server-Sent Events
+
+		// Webhook բաժանորդագրություններ
+		webhooks := api.Group("/webhooks", requireAPIToken())
+		{
+			webhooks.GET("", getWebhooksHandler)                                            // Բաժանորդագրությունների ցանկ
+			webhooks.POST("", createWebhookHandler)                                         // Նոր բաժանորդագրություն
+			webhooks.DELETE("/:id", deleteWebhookHandler)                                   // Բաժանորդագրության ջնջում
+			webhooks.GET("/:id/deliveries", getWebhookDeliveriesHandler)                    // Առաքումների մատյան
+			webhooks.POST("/:id/deliveries/:deliveryId/redeliver", redeliverWebhookHandler) // Կրկնակի ուղարկում
+		}
+
+		api.GET("/stats/presign", getPresignStatsHandler) // URL-ների քեշի վիճակագրություն
+
+		// Լոգի մակարդակի փոփոխում աշխատանքի ընթացքում
+		api.GET("/admin/log-level", requireAPIToken(), getLogLevelHandler)
+		api.PUT("/admin/log-level", requireAPIToken(), setLogLevelHandler)
 	}
 
-	fmt.Println("API սերվերը գործարկվել է՝ :8080")
-	router.Run(":8080")
+	if err := serveUntilShutdown(ctx, stop, newHTTPServer(router)); err != nil {
+		slog.Error("Սխալ HTTP սերվերը գործարկելիս", "error", err)
+		os.Exit(1)
+	}
 }
 
-// initDB - GORM-ով MySQL բազայի կապակցում և միգրացիա
+// models - Բազայում պահվող բոլոր մոդելները միգրացիայի համար
+var models = []interface{}{&Image{}, &Tag{}, &ImageTag{}, &Upload{}, &UploadPart{}, &ImageVersion{}, &ImageAttribute{}, &Album{}, &AlbumImage{}, &Job{}, &WebhookSubscription{}, &WebhookDelivery{}, &BulkOperation{}, &BulkOperationResult{}}
+
+// initDB - GORM-ով MySQL բազայի կապակցում և միգրացիա.
+// Եթե բազան հասանելի չէ, կապակցումը կրկնվում է ֆոնում, մինչև այն հաջողվի
 func initDB() {
+	if err := connectDB(); err != nil {
+		slog.Error("Սխալ MySQL բազային կապակցվելիս, կփորձենք կրկին", "error", err)
+		go reconnectDB()
+	}
+}
+
+// connectDB - Բացել կապակցումը և կատարել միգրացիան: db-ն նշանակվում է միայն հաջողության դեպքում
+func connectDB() error {
 	dsn := fmt.Sprintf("%s:%s@tcp(%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
-		getEnvWithDefault("DB_USER", "root"),
-		getEnvWithDefault("DB_PASSWORD", "java"),
-		getEnvWithDefault("DB_HOST", "localhost:3306"),
-		getEnvWithDefault("DB_NAME", "minio_gallery"),
+		cfg.Database.User,
+		cfg.Database.Password,
+		cfg.Database.Host,
+		cfg.Database.Name,
 	)
 
-	var err error
-	db, err = gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	conn, err := gorm.Open(mysql.Open(dsn), &gorm.Config{Logger: gormSlogLogger{}})
 	if err != nil {
-		log.Printf("Սխալ MySQL բազային կապակցվելիս: %v", err)
-		return
+		return err
+	}
+	if err := registerDBMetrics(conn); err != nil {
+		slog.Error("Սխալ բազայի մետրիկաները միացնելիս", "error", err)
+	}
+	if err := registerDBTracing(conn); err != nil {
+		slog.Error("Սխալ բազայի tracing-ը միացնելիս", "error", err)
 	}
 
 	// Մոդելների միգրացիա
-	err = db.AutoMigrate(&Image{}, &Tag{}, &ImageTag{})
+	err = conn.AutoMigrate(models...)
 	if err != nil {
-		log.Printf("Սխալ միգրացիայի ժամանակ: %v", err)
-		return
+		if sqlDB, dbErr := conn.DB(); dbErr == nil {
+			sqlDB.Close()
+		}
+		return fmt.Errorf("միգրացիա: %w", err)
 	}
 
-	log.Println("MySQL բազային կապակցումը հաստատված")
+	// Կապակցումից հետո database/sql-ի pool-ը ինքն է վերականգնում խզված կապերը
+	db = conn
+	dbReady.Store(true)
+	slog.Info("MySQL բազային կապակցումը հաստատված")
+	return nil
+}
+
+// reconnectDB - Կրկնել կապակցումը աճող ընդմիջումներով՝ մինչև DB_RECONNECT_MAX_INTERVAL
+func reconnectDB() {
+	interval := cfg.Database.ReconnectInterval
+	maxInterval := cfg.Database.ReconnectMaxInterval
+	for {
+		time.Sleep(interval)
+		err := connectDB()
+		if err == nil {
+			return
+		}
+		slog.Warn("MySQL բազան դեռ հասանելի չէ", "error", err, "retry_in", interval.String())
+		interval = min(interval*2, maxInterval)
+	}
+}
+
+// dbAvailable - Բազան կապակցված է և միգրացիաներն ավարտված են.
+// db փոփոխականը կարդալուց առաջ պետք է ստուգել այս ֆունկցիայով
+func dbAvailable() bool {
+	return dbReady.Load()
 }
 
 // getAllImagesHandler - Բոլոր նկարների ցանկի ստացում
 func getAllImagesHandler(c *gin.Context) {
+	ctx := c.Request.Context()
 	var images []Image
 	var result []ImageResponse
 
 	// Ստանալ բոլոր նկարները բազայից
-	if db != nil {
-		if err := db.Find(&images).Error; err != nil {
-			log.Printf("Սխալ նկարների ցանկը ստանալիս: %v", err)
+	if dbAvailable() {
+		// Ատրիբուտների ֆիլտրեր, օր.՝ ?attr.license=cc-by&attr.rating>=4
+		filters, err := parseAttributeFilters(c.Request.URL.RawQuery)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := db.WithContext(ctx).Scopes(activeImages, attributeFilters(filters)).Find(&images).Error; err != nil {
+			slog.ErrorContext(ctx, "Սխալ նկարների ցանկը ստանալիս", "error", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Չհաջողվեց ստանալ նկարների ցանկը"})
 			return
 		}
 
 		// Ձևավորել պատասխանը
 		for _, img := range images {
-			response, err := createImageResponse(img)
+			response, err := createImageResponse(ctx, img)
 			if err != nil {
 				continue
 			}
@@ -204,7 +392,7 @@ func getAllImagesHandler(c *gin.Context) {
 		}
 	} else {
 		// Եթե DB-ն հասանելի չէ, օգտագործել MinIO-ն ուղղակիորեն
-		result = getImagesFromMinIO()
+		result = getImagesFromMinIO(ctx)
 	}
 
 	// Եթե արդյունքը դատարկ է, վերադարձնել դատարկ զանգված, ոչ թե null
@@ -218,91 +406,105 @@ func getAllImagesHandler(c *gin.Context) {
 // getImageByIdHandler - Կոնկրետ նկարի ստացում ID-ով
 func getImageByIdHandler(c *gin.Context) {
 	id := c.Param("id")
+	ctx := c.Request.Context()
 
-	if db == nil {
+	if !dbAvailable() {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Տվյալների բազան հասանելի չէ"})
 		return
 	}
 
 	var image Image
-	if err := db.First(&image, "id = ?", id).Error; err != nil {
+	if err := db.WithContext(ctx).Where("status <> ?", imageStatusDeleting).First(&image, "id = ?", id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Նկարը չի գտնվել"})
 		return
 	}
 
+	// Ստուգվող, մեկուսացված կամ ստուգումը ձախողված նկարի URL-ը չի տրվում, միայն վիճակը,
+	// որպեսզի հաճախորդը կարողանա հետևել ստուգմանը և տեսնել ձախողումը
+	if image.Status != imageStatusActive {
+		c.JSON(http.StatusOK, gin.H{
+			"id":               image.ID,
+			"name":             image.Name,
+			"status":           image.Status,
+			"processingStatus": image.ProcessingStatus,
+		})
+		return
+	}
+
 	// Ստանալ նկարի թեգերը
 	var imageTags []ImageTag
 	var tags []Tag
 	var tagNames []string
 
-	db.Where("image_id = ?", id).Find(&imageTags)
+	db.WithContext(ctx).Where("image_id = ?", id).Find(&imageTags)
 
 	for _, it := range imageTags {
 		var tag Tag
-		if err := db.First(&tag, it.TagID).Error; err == nil {
+		if err := db.WithContext(ctx).First(&tag, it.TagID).Error; err == nil {
 			tags = append(tags, tag)
 			tagNames = append(tagNames, tag.Name)
 		}
 	}
 
-	// Ստեղծել նախապես ստորագրված URL նկարի համար
-	presignClient := s3.NewPresignClient(s3Client)
-	bucketName := getEnvWithDefault("BUCKET_NAME", "images")
-
-	presignedURL, err := presignClient.PresignGetObject(context.TODO(), &s3.GetObjectInput{
-		Bucket: aws.String(bucketName),
-		Key:    aws.String(image.ObjectKey),
-	}, func(opts *s3.PresignOptions) {
-		opts.Expires = 1 * time.Hour
-	})
-
+	// Ստանալ նախապես ստորագրված URL նկարի համար
+	presignedURL, err := presignImageURL(ctx, image.ObjectKey)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Չհաջողվեց ստեղծել նկարի URL"})
 		return
 	}
 
 	response := ImageResponse{
-		ID:          image.ID,
-		URL:         presignedURL.URL,
-		Name:        image.Name,
-		Size:        image.Size,
-		ContentType: image.ContentType,
-		UploadedAt:  image.UploadedAt,
-		Tags:        tagNames,
-	}
-
+		ID:               image.ID,
+		URL:              presignedURL,
+		Name:             image.Name,
+		Size:             image.Size,
+		ContentType:      image.ContentType,
+		Width:            image.Width,
+		Height:           image.Height,
+		Caption:          image.Caption,
+		AltText:          image.AltText,
+		Attributes:       getImageAttributes(ctx, image.ID),
+		ProcessingStatus: image.ProcessingStatus,
+		UploadedAt:       image.UploadedAt,
+		UpdatedAt:        image.UpdatedAt,
+		Tags:             tagNames,
+	}
+
+	// ETag-ը օգտագործվում է PATCH հարցման If-Match վերնագրում
+	c.Header("ETag", imageETag(image))
 	c.JSON(http.StatusOK, response)
 }
 
 // getImagesByTagHandler - Նկարների ստացում թեգով
 func getImagesByTagHandler(c *gin.Context) {
 	tagName := c.Param("tag")
+	ctx := c.Request.Context()
 
-	if db == nil {
+	if !dbAvailable() {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Տվյալների բազան հասանելի չէ"})
 		return
 	}
 
 	// Ստանալ թեգը
 	var tag Tag
-	if err := db.Where("name = ?", tagName).First(&tag).Error; err != nil {
+	if err := db.WithContext(ctx).Where("name = ?", tagName).First(&tag).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Թեգը չի գտնվել"})
 		return
 	}
 
 	// Ստանալ բոլոր նկարների ID-ները, որոնք ունեն այդ թեգը
 	var imageTags []ImageTag
-	db.Where("tag_id = ?", tag.ID).Find(&imageTags)
+	db.WithContext(ctx).Where("tag_id = ?", tag.ID).Find(&imageTags)
 
 	var result []ImageResponse
 
 	for _, it := range imageTags {
 		var image Image
-		if err := db.First(&image, "id = ?", it.ImageID).Error; err != nil {
+		if err := db.WithContext(ctx).Scopes(activeImages).First(&image, "id = ?", it.ImageID).Error; err != nil {
 			continue
 		}
 
-		response, err := createImageResponse(image)
+		response, err := createImageResponse(ctx, image)
 		if err != nil {
 			continue
 		}
@@ -320,37 +522,55 @@ func getImagesByTagHandler(c *gin.Context) {
 
 // uploadImageHandler - Նկարի վերբեռնում
 func uploadImageHandler(c *gin.Context) {
+	started := time.Now()
+	ctx := c.Request.Context()
+
 	// Ստանալ ֆայլը ձևից
 	file, header, err := c.Request.FormFile("image")
 	if err != nil {
-		log.Printf("Սխալ ձևի ֆայլը կարդալիս: %v", err)
+		slog.WarnContext(ctx, "Սխալ ձևի ֆայլը կարդալիս", "error", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Չհաջողվեց կարդալ ֆայլը"})
 		return
 	}
 	defer file.Close()
 
 	// Ստանալ թեգերը, եթե տրված են
-	tagsParam := c.PostForm("tags")
-	var tagNames []string
-	if tagsParam != "" {
-		tagNames = strings.Split(tagsParam, ",")
-		// Մաքրել սպիտակ տարածությունները
-		for i := range tagNames {
-			tagNames[i] = strings.TrimSpace(tagNames[i])
-		}
-	}
+	tagNames := parseTagList(c.PostForm("tags"))
 
-	bucketName := getEnvWithDefault("BUCKET_NAME", "images")
-	uploadDir := getEnvWithDefault("UPLOAD_DIR", defaultUploadDir)
+	bucketName := cfg.Storage.Bucket
+	uploadDir := cfg.Storage.UploadDir
 
 	// Ստեղծել յունիք ID նկարի համար
-	imageID := fmt.Sprintf("%d-%d", time.Now().UnixNano(), rand.Intn(100000))
+	imageID := newImageID()
 
 	// Կառուցել ֆայլի ուղին և վերբեռնել MinIO
 	extension := filepath.Ext(header.Filename)
 	objectKey := filepath.Join(uploadDir, imageID+extension)
 
-	_, err = s3Client.PutObject(context.TODO(), &s3.PutObjectInput{
+	// Հաշվել բովանդակության հեշը կրկնօրինակների հայտնաբերման համար
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		slog.WarnContext(ctx, "Սխալ ֆայլը կարդալիս", "image_id", imageID, "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Չհաջողվեց կարդալ ֆայլը"})
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		slog.ErrorContext(ctx, "Սխալ ֆայլը կարդալիս", "image_id", imageID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Չհաջողվեց կարդալ ֆայլը"})
+		return
+	}
+
+	// Չափերը կարդացվում են միայն նկարի վերնագրից
+	width, height := imageDimensions(file)
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		slog.ErrorContext(ctx, "Սխալ ֆայլը կարդալիս", "image_id", imageID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Չհաջողվեց կարդալ ֆայլը"})
+		return
+	}
+
+	upload := newSaga("upload")
+
+	_, err = s3Client.PutObject(ctx, &s3.PutObjectInput{
 		Bucket:      aws.String(bucketName),
 		Key:         aws.String(objectKey),
 		Body:        file,
@@ -358,13 +578,14 @@ func uploadImageHandler(c *gin.Context) {
 	})
 
 	if err != nil {
-		log.Printf("Սխալ MinIO վերբեռնելիս: %v", err)
+		slog.ErrorContext(ctx, "Սխալ MinIO վերբեռնելիս", "image_id", imageID, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Չհաջողվեց վերբեռնել նկարը"})
 		return
 	}
+	upload.onFailure(deleteObjectCompensation(objectKey))
 
 	// Եթե DB-ն հասանելի է, պահել նկարի մասին ինֆորմացիան
-	if db != nil {
+	if dbAvailable() {
 		// Ստեղծել նկարի գրառում
 		image := Image{
 			ID:          imageID,
@@ -372,38 +593,23 @@ func uploadImageHandler(c *gin.Context) {
 			Name:        header.Filename,
 			Size:        header.Size,
 			ContentType: getContentType(extension),
+			Hash:        hex.EncodeToString(hasher.Sum(nil)),
+			Width:       width,
+			Height:      height,
 			UploadedAt:  time.Now(),
 		}
 
-		if err := db.Create(&image).Error; err != nil {
-			log.Printf("Սխալ նկարը բազայում պահելիս: %v", err)
-		}
-
-		// Ավելացնել թեգերը, եթե տրված են
-		for _, tagName := range tagNames {
-			if tagName == "" {
-				continue
-			}
-
-			// Ստուգել թեգի գոյությունը կամ ստեղծել նորը
-			var tag Tag
-			if err := db.Where("name = ?", tagName).FirstOrCreate(&tag, Tag{Name: tagName}).Error; err != nil {
-				log.Printf("Սխալ թեգը ստուգելիս/ստեղծելիս: %v", err)
-				continue
-			}
-
-			// Ստեղծել կապը նկարի և թեգի միջև
-			imageTag := ImageTag{
-				ImageID: imageID,
-				TagID:   tag.ID,
-			}
-
-			if err := db.Create(&imageTag).Error; err != nil {
-				log.Printf("Սխալ նկարին թեգ ավելացնելիս: %v", err)
-			}
+		// Նկարը և թեգերը պահվում են միասին, ձախողման դեպքում օբյեկտը ջնջվում է
+		if err := createImageRecord(ctx, &image, tagNames); err != nil {
+			slog.ErrorContext(ctx, "Սխալ նկարը բազայում պահելիս", "image_id", image.ID, "error", err)
+			upload.compensate(ctx)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Չհաջողվեց պահել նկարի մասին տվյալները"})
+			return
 		}
 	}
 
+	observeUpload(uploadMethodMultipart, header.Size, started)
+
 	// Վերադարձնել պատասխան React հավելվածին
 	c.JSON(http.StatusOK, gin.H{
 		"id":     imageID,
@@ -415,43 +621,28 @@ func uploadImageHandler(c *gin.Context) {
 // deleteImageHandler - Նկարի ջնջում
 func deleteImageHandler(c *gin.Context) {
 	id := c.Param("id")
+	ctx := c.Request.Context()
 
-	if db == nil {
+	if !dbAvailable() {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Տվյալների բազան հասանելի չէ"})
 		return
 	}
 
 	// Ստանալ նկարի տվյալները նախքան ջնջելը
 	var image Image
-	if err := db.First(&image, "id = ?", id).Error; err != nil {
+	if err := db.WithContext(ctx).First(&image, "id = ?", id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Նկարը չի գտնվել"})
 		return
 	}
 
-	// Ջնջել նկարը MinIO-ից
-	bucketName := getEnvWithDefault("BUCKET_NAME", "images")
-	_, err := s3Client.DeleteObject(context.TODO(), &s3.DeleteObjectInput{
-		Bucket: aws.String(bucketName),
-		Key:    aws.String(image.ObjectKey),
-	})
-
-	if err != nil {
-		log.Printf("Սխալ նկարը MinIO-ից ջնջելիս: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Չհաջողվեց ջնջել նկարը պահեստից"})
-		return
-	}
-
-	// Ջնջել նկարի թեգերը
-	if err := db.Where("image_id = ?", id).Delete(&ImageTag{}).Error; err != nil {
-		log.Printf("Սխալ նկարի թեգերը ջնջելիս: %v", err)
-	}
-
-	// Ջնջել նկարի գրառումը բազայից
-	if err := db.Delete(&image).Error; err != nil {
-		log.Printf("Սխալ նկարը բազայից ջնջելիս: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Չհաջողվեց ջնջել նկարի մասին տվյալները"})
+	// Տեղափոխել նկարը աղբաման, օբյեկտը կջնջվի պահպանման ժամկետից հետո
+	if err := db.WithContext(ctx).Delete(&image).Error; err != nil {
+		slog.ErrorContext(ctx, "Սխալ նկարը աղբաման տեղափոխելիս", "image_id", image.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Չհաջողվեց ջնջել նկարը"})
 		return
 	}
+	invalidatePresignedURL(image.ObjectKey)
+	emitEvent(ctx, eventImageDeleted, newImageEventData(image, nil))
 
 	c.JSON(http.StatusOK, gin.H{
 		"status":  "success",
@@ -462,15 +653,16 @@ func deleteImageHandler(c *gin.Context) {
 // addTagsToImageHandler - Նկարին թեգերի ավելացում
 func addTagsToImageHandler(c *gin.Context) {
 	id := c.Param("id")
+	ctx := c.Request.Context()
 
-	if db == nil {
+	if !dbAvailable() {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Տվյալների բազան հասանելի չէ"})
 		return
 	}
 
 	// Ստուգել նկարի գոյությունը
 	var image Image
-	if err := db.First(&image, "id = ?", id).Error; err != nil {
+	if err := db.WithContext(ctx).Scopes(activeImages).First(&image, "id = ?", id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Նկարը չի գտնվել"})
 		return
 	}
@@ -486,9 +678,27 @@ func addTagsToImageHandler(c *gin.Context) {
 	}
 
 	// Ավելացնել յուրաքանչյուր թեգը
+	addedTags, err := attachTags(db.WithContext(ctx), id, input.Tags)
+	if err != nil {
+		slog.ErrorContext(ctx, "Սխալ նկարին թեգ ավելացնելիս", "image_id", id, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Չհաջողվեց ավելացնել թեգերը"})
+		return
+	}
+	if len(addedTags) > 0 {
+		emitEvent(ctx, eventImageTagged, newImageEventData(image, addedTags))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":    "success",
+		"addedTags": addedTags,
+	})
+}
+
+// attachTags - Կապել թեգերը նկարին՝ ստեղծելով բացակայող թեգերը, և վերադարձնել նոր ավելացվածները
+func attachTags(tx *gorm.DB, imageID string, tagNames []string) ([]string, error) {
 	var addedTags []string
 
-	for _, tagName := range input.Tags {
+	for _, tagName := range tagNames {
 		tagName = strings.TrimSpace(tagName)
 		if tagName == "" {
 			continue
@@ -496,46 +706,75 @@ func addTagsToImageHandler(c *gin.Context) {
 
 		// Ստուգել թեգի գոյությունը կամ ստեղծել նորը
 		var tag Tag
-		if err := db.Where("name = ?", tagName).FirstOrCreate(&tag, Tag{Name: tagName}).Error; err != nil {
-			log.Printf("Սխալ թեգը ստուգելիս/ստեղծելիս: %v", err)
-			continue
+		if err := tx.Where("name = ?", tagName).FirstOrCreate(&tag, Tag{Name: tagName}).Error; err != nil {
+			return addedTags, err
 		}
 
 		// Ստուգել, թե արդյոք այս կապը արդեն գոյություն ունի
 		var existingImageTag ImageTag
-		result := db.Where("image_id = ? AND tag_id = ?", id, tag.ID).First(&existingImageTag)
+		result := tx.Where("image_id = ? AND tag_id = ?", imageID, tag.ID).Limit(1).Find(&existingImageTag)
+		if result.Error != nil {
+			return addedTags, result.Error
+		}
 
 		if result.RowsAffected == 0 {
 			// Ստեղծել կապը նկարի և թեգի միջև
 			imageTag := ImageTag{
-				ImageID: id,
+				ImageID: imageID,
 				TagID:   tag.ID,
 			}
 
-			if err := db.Create(&imageTag).Error; err != nil {
-				log.Printf("Սխալ նկարին թեգ ավելացնելիս: %v", err)
-				continue
+			if err := tx.Create(&imageTag).Error; err != nil {
+				return addedTags, err
 			}
 
 			addedTags = append(addedTags, tagName)
 		}
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"status":    "success",
-		"addedTags": addedTags,
-	})
+	return addedTags, nil
+}
+
+// imageTagNames - Նկարի թեգերի անունները
+func imageTagNames(tx *gorm.DB, imageID string) ([]string, error) {
+	var names []string
+	err := tx.Model(&Tag{}).
+		Joins("JOIN image_tags ON image_tags.tag_id = tags.id").
+		Where("image_tags.image_id = ?", imageID).
+		Order("tags.name").
+		Pluck("tags.name", &names).Error
+	return names, err
+}
+
+// parseTagList - Ստորակետերով բաժանված թեգերի տողը վերածել ցանկի
+func parseTagList(tagsParam string) []string {
+	if tagsParam == "" {
+		return nil
+	}
+
+	tagNames := strings.Split(tagsParam, ",")
+	// Մաքրել սպիտակ տարածությունները
+	for i := range tagNames {
+		tagNames[i] = strings.TrimSpace(tagNames[i])
+	}
+	return tagNames
+}
+
+// newImageID - Ստեղծել յունիք ID նկարի համար
+func newImageID() string {
+	return fmt.Sprintf("%d-%d", time.Now().UnixNano(), rand.Intn(100000))
 }
 
 // getAllTagsHandler - Բոլոր թեգերի ցանկի ստացում
 func getAllTagsHandler(c *gin.Context) {
-	if db == nil {
+	ctx := c.Request.Context()
+	if !dbAvailable() {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Տվյալների բազան հասանելի չէ"})
 		return
 	}
 
 	var tags []Tag
-	if err := db.Find(&tags).Error; err != nil {
+	if err := db.WithContext(ctx).Find(&tags).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Չհաջողվեց ստանալ թեգերի ցանկը"})
 		return
 	}
@@ -544,18 +783,18 @@ func getAllTagsHandler(c *gin.Context) {
 }
 
 // getImagesFromMinIO - Ստանալ նկարները ուղղակիորեն MinIO-ից (առանց բազայի)
-func getImagesFromMinIO() []ImageResponse {
-	bucketName := getEnvWithDefault("BUCKET_NAME", "images")
-	uploadDir := getEnvWithDefault("UPLOAD_DIR", defaultUploadDir)
+func getImagesFromMinIO(ctx context.Context) []ImageResponse {
+	bucketName := cfg.Storage.Bucket
+	uploadDir := cfg.Storage.UploadDir
 
 	// Ստանալ bucket-ում առկա օբյեկտների ցանկը
-	resp, err := s3Client.ListObjectsV2(context.TODO(), &s3.ListObjectsV2Input{
+	resp, err := s3Client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
 		Bucket: aws.String(bucketName),
 		Prefix: aws.String(uploadDir),
 	})
 
 	if err != nil {
-		log.Printf("Սխալ MinIO-ից օբյեկտները թվարկելիս: %v", err)
+		slog.ErrorContext(ctx, "Սխալ MinIO-ից օբյեկտները թվարկելիս", "error", err)
 		return nil
 	}
 
@@ -572,16 +811,9 @@ func getImagesFromMinIO() []ImageResponse {
 			continue
 		}
 
-		presignClient := s3.NewPresignClient(s3Client)
-		presignedURL, err := presignClient.PresignGetObject(context.TODO(), &s3.GetObjectInput{
-			Bucket: aws.String(bucketName),
-			Key:    item.Key,
-		}, func(opts *s3.PresignOptions) {
-			opts.Expires = 1 * time.Hour
-		})
-
+		presignedURL, err := presignImageURL(ctx, *item.Key)
 		if err != nil {
-			log.Printf("Սխալ նախապես ստորագրված URL ստեղծելիս: %v", err)
+			slog.ErrorContext(ctx, "Սխալ նախապես ստորագրված URL ստեղծելիս", "key", *item.Key, "error", err)
 			continue
 		}
 
@@ -593,7 +825,7 @@ func getImagesFromMinIO() []ImageResponse {
 
 		images = append(images, ImageResponse{
 			ID:          id,
-			URL:         presignedURL.URL,
+			URL:         presignedURL,
 			Name:        filename,
 			Size:        *item.Size,
 			ContentType: getContentType(filepath.Ext(filename)),
@@ -605,45 +837,47 @@ func getImagesFromMinIO() []ImageResponse {
 }
 
 // createImageResponse - Ստեղծել ImageResponse օբյեկտ նկարի մոդելից
-func createImageResponse(image Image) (ImageResponse, error) {
-	// Ստեղծել նախապես ստորագրված URL նկարի համար
-	presignClient := s3.NewPresignClient(s3Client)
-	bucketName := getEnvWithDefault("BUCKET_NAME", "images")
-
-	presignedURL, err := presignClient.PresignGetObject(context.TODO(), &s3.GetObjectInput{
-		Bucket: aws.String(bucketName),
-		Key:    aws.String(image.ObjectKey),
-	}, func(opts *s3.PresignOptions) {
-		opts.Expires = 1 * time.Hour
-	})
-
+func createImageResponse(ctx context.Context, image Image) (ImageResponse, error) {
+	// Ստանալ նախապես ստորագրված URL նկարի համար
+	presignedURL, err := presignImageURL(ctx, image.ObjectKey)
 	if err != nil {
 		return ImageResponse{}, err
 	}
 
-	// Ստանալ նկարի թեգերը
+	// Ստանալ նկարի թեգերը և ատրիբուտները
 	var tags []string
+	var attributes map[string]interface{}
+
+	if dbAvailable() {
+		attributes = getImageAttributes(ctx, image.ID)
 
-	if db != nil {
 		var imageTags []ImageTag
-		db.Where("image_id = ?", image.ID).Find(&imageTags)
+		db.WithContext(ctx).Where("image_id = ?", image.ID).Find(&imageTags)
 
 		for _, it := range imageTags {
 			var tag Tag
-			if err := db.First(&tag, it.TagID).Error; err == nil {
+			if err := db.WithContext(ctx).First(&tag, it.TagID).Error; err == nil {
 				tags = append(tags, tag.Name)
 			}
 		}
 	}
 
 	return ImageResponse{
-		ID:          image.ID,
-		URL:         presignedURL.URL,
-		Name:        image.Name,
-		Size:        image.Size,
-		ContentType: image.ContentType,
-		UploadedAt:  image.UploadedAt,
-		Tags:        tags,
+		ID:               image.ID,
+		URL:              presignedURL,
+		Name:             image.Name,
+		Size:             image.Size,
+		ContentType:      image.ContentType,
+		Width:            image.Width,
+		Height:           image.Height,
+		Caption:          image.Caption,
+		AltText:          image.AltText,
+		Attributes:       attributes,
+		ProcessingStatus: image.ProcessingStatus,
+		UploadedAt:       image.UploadedAt,
+		UpdatedAt:        image.UpdatedAt,
+		DeletedAt:        deletedAt(image),
+		Tags:             tags,
 	}, nil
 }
 
@@ -673,11 +907,3 @@ func getContentType(extension string) string {
 		return "application/octet-stream"
 	}
 }
-
-func getEnvWithDefault(key, defaultValue string) string {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue
-	}
-	return value
-}
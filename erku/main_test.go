@@ -0,0 +1,467 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// errInjected - Թեստերում արհեստականորեն առաջացված սխալը
+var errInjected = errors.New("injected failure")
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// fakeS3 - S3-ի պարզեցված իմիտացիա path-style հարցումների համար՝ ձախողումների ներարկմամբ
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	types   map[string]string
+	// calls - կատարված գործողությունները հերթականությամբ, օր.՝ "PUT uploads/1.png"
+	calls []string
+	// fail - գործողությունները (PUT, GET, HEAD, DELETE, COPY, ABORT), որոնք պետք է ձախողվեն
+	fail map[string]bool
+	// before - կանչվում է գործողությունը կատարելուց առաջ
+	before func(op, key string)
+	// versioned - bucket-ի տարբերակավորումը միացված է, history-ն պահում է յուրաքանչյուր բանալու տարբերակները
+	versioned   bool
+	history     map[string][]fakeVersion
+	nextVersion int
+}
+
+// fakeVersion - Օբյեկտի մեկ տարբերակ տարբերակավորված bucket-ում
+type fakeVersion struct {
+	id   string
+	data []byte
+}
+
+// newTestS3 - Գործարկել fakeS3-ը և ուղղել s3Client-ը և presignClient-ը դեպի այն
+func newTestS3(t *testing.T) *fakeS3 {
+	t.Helper()
+	fake := &fakeS3{
+		objects: make(map[string][]byte),
+		types:   make(map[string]string),
+		fail:    make(map[string]bool),
+		history: make(map[string][]fakeVersion),
+	}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	previousClient, previousPresign := s3Client, presignClient
+	s3Client = s3.New(s3.Options{
+		Region:                     "us-east-1",
+		BaseEndpoint:               aws.String(server.URL),
+		UsePathStyle:               true,
+		Credentials:                credentials.NewStaticCredentialsProvider("test", "test", ""),
+		Retryer:                    aws.NopRetryer{},
+		RequestChecksumCalculation: aws.RequestChecksumCalculationWhenRequired,
+	})
+	presignClient = s3.NewPresignClient(s3Client)
+	t.Cleanup(func() { s3Client, presignClient = previousClient, previousPresign })
+	return fake
+}
+
+// put - Ավելացնել օբյեկտ առանց գործողությունը գրանցելու
+func (f *fakeS3) put(key string, data []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.store(key, data)
+}
+
+// store - Գրել օբյեկտը, տարբերակավորված bucket-ում վերադարձնում է նոր տարբերակի ID-ն
+func (f *fakeS3) store(key string, data []byte) string {
+	f.objects[key] = data
+	if !f.versioned {
+		return ""
+	}
+	f.nextVersion++
+	id := fmt.Sprintf("v%d", f.nextVersion)
+	f.history[key] = append(f.history[key], fakeVersion{id: id, data: data})
+	return id
+}
+
+// version - Բանալու տրված տարբերակը, դատարկ ID-ի դեպքում՝ ընթացիկը
+func (f *fakeS3) version(key, id string) (fakeVersion, bool) {
+	versions := f.history[key]
+	if id == "" {
+		if data, ok := f.objects[key]; ok {
+			current := fakeVersion{data: data}
+			if len(versions) > 0 {
+				current.id = versions[len(versions)-1].id
+			}
+			return current, true
+		}
+		return fakeVersion{}, false
+	}
+	for _, version := range versions {
+		if version.id == id {
+			return version, true
+		}
+	}
+	return fakeVersion{}, false
+}
+
+// versionIDs - Բանալու տարբերակների ID-ները՝ հնից նոր
+func (f *fakeS3) versionIDs(key string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var ids []string
+	for _, version := range f.history[key] {
+		ids = append(ids, version.id)
+	}
+	return ids
+}
+
+// current - Բանալու ընթացիկ բովանդակությունը
+func (f *fakeS3) current(key string) []byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.objects[key]
+}
+
+// putTyped - Ավելացնել օբյեկտ Content-Type-ով, ինչպես ուղիղ վերբեռնումից հետո
+func (f *fakeS3) putTyped(key, contentType string, data []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.objects[key] = data
+	f.types[key] = contentType
+}
+
+// has - Օբյեկտը գոյություն ունի
+func (f *fakeS3) has(key string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, ok := f.objects[key]
+	return ok
+}
+
+// keys - Բոլոր օբյեկտների բանալիները
+func (f *fakeS3) keys() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	keys := make([]string, 0, len(f.objects))
+	for key := range f.objects {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// callLog - Կատարված գործողությունների պատճենը
+func (f *fakeS3) callLog() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.calls...)
+}
+
+// failOn - Ձախողել տրված գործողությունը մինչև թեստի ավարտը
+func (f *fakeS3) failOn(op string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fail[op] = true
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// /bucket/key...
+	_, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+
+	op := r.Method
+	switch {
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		op = "COPY"
+	case r.Method == http.MethodPost && r.URL.Query().Has("delete"):
+		op = "DELETE_OBJECTS"
+	case r.Method == http.MethodDelete && r.URL.Query().Has("uploadId"):
+		op = "ABORT"
+	}
+
+	if f.before != nil {
+		f.before(op, key)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, op+" "+key)
+	if f.fail[op] {
+		writeS3Error(w, http.StatusInternalServerError, "InternalError")
+		return
+	}
+
+	switch op {
+	case "PUT":
+		data, _ := io.ReadAll(r.Body)
+		if id := f.store(key, data); id != "" {
+			w.Header().Set("X-Amz-Version-Id", id)
+		}
+		f.types[key] = r.Header.Get("Content-Type")
+		w.Header().Set("ETag", `"etag"`)
+	case "COPY":
+		source, versionID, _ := strings.Cut(r.Header.Get("X-Amz-Copy-Source"), "?versionId=")
+		source, _ = url.PathUnescape(source)
+		versionID, _ = url.QueryUnescape(versionID)
+		_, sourceKey, _ := strings.Cut(strings.TrimPrefix(source, "/"), "/")
+		version, ok := f.version(sourceKey, versionID)
+		if !ok {
+			writeS3Error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		if id := f.store(key, version.data); id != "" {
+			w.Header().Set("X-Amz-Version-Id", id)
+		}
+		f.types[key] = f.types[sourceKey]
+		fmt.Fprint(w, `<CopyObjectResult><ETag>"etag"</ETag></CopyObjectResult>`)
+	case "GET", "HEAD":
+		if key == "" && r.URL.Query().Get("list-type") == "2" {
+			f.writeList(w, r.URL.Query().Get("prefix"))
+			return
+		}
+		if key == "" && op == "HEAD" {
+			// HeadBucket
+			return
+		}
+		version, ok := f.version(key, r.URL.Query().Get("versionId"))
+		if !ok {
+			writeS3Error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		data := version.data
+		if version.id != "" {
+			w.Header().Set("X-Amz-Version-Id", version.id)
+		}
+		w.Header().Set("Content-Type", f.types[key])
+		w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+		w.Header().Set("ETag", `"etag"`)
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		if op == "GET" {
+			w.Write(data)
+		}
+	case "DELETE":
+		if versionID := r.URL.Query().Get("versionId"); versionID != "" {
+			// Ընթացիկ տարբերակի ջնջումից հետո ընթացիկ է դառնում նախորդը
+			versions := f.history[key]
+			for i, version := range versions {
+				if version.id == versionID {
+					versions = append(versions[:i:i], versions[i+1:]...)
+					break
+				}
+			}
+			f.history[key] = versions
+			if len(versions) > 0 {
+				f.objects[key] = versions[len(versions)-1].data
+			} else {
+				delete(f.objects, key)
+			}
+		} else {
+			delete(f.objects, key)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case "ABORT":
+		w.WriteHeader(http.StatusNoContent)
+	case "DELETE_OBJECTS":
+		var request struct {
+			Objects []struct {
+				Key string `xml:"Key"`
+			} `xml:"Object"`
+		}
+		xml.NewDecoder(r.Body).Decode(&request)
+		for _, object := range request.Objects {
+			delete(f.objects, object.Key)
+		}
+		fmt.Fprint(w, `<DeleteResult></DeleteResult>`)
+	default:
+		writeS3Error(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+// writeList - ListObjectsV2-ի պատասխանը մեկ էջով
+func (f *fakeS3) writeList(w http.ResponseWriter, prefix string) {
+	var contents strings.Builder
+	for key, data := range f.objects {
+		if strings.HasPrefix(key, prefix) {
+			fmt.Fprintf(&contents, "<Contents><Key>%s</Key><Size>%d</Size><LastModified>%s</LastModified></Contents>",
+				key, len(data), time.Now().UTC().Format(time.RFC3339))
+		}
+	}
+	fmt.Fprintf(w, "<ListBucketResult><Name>%s</Name><Prefix>%s</Prefix><IsTruncated>false</IsTruncated>%s</ListBucketResult>",
+		cfg.Storage.Bucket, prefix, contents.String())
+}
+
+func writeS3Error(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `<Error><Code>%s</Code><Message>%s</Message></Error>`, code, code)
+}
+
+// newTestDB - SQLite բազա հիշողության մեջ՝ բոլոր մոդելների միգրացիայով
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	// Ենթաթեստերի անուններում կարող են լինել «#» և «?», որոնք կփոխեին URI-ն և կստեղծեին ֆայլ սկավառակի վրա
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", url.PathEscape(t.Name()))
+	conn, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("sqlite: %v", err)
+	}
+	sqlDB, err := conn.DB()
+	if err != nil {
+		t.Fatalf("sqlite: %v", err)
+	}
+	// Մեկ կապակցումը բավարար է և խուսափում է SQLite-ի աղյուսակների կողպումներից
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := conn.AutoMigrate(models...); err != nil {
+		t.Fatalf("միգրացիա: %v", err)
+	}
+
+	previousDB, previousReady := db, dbReady.Load()
+	db = conn
+	dbReady.Store(true)
+	t.Cleanup(func() {
+		db = previousDB
+		dbReady.Store(previousReady)
+	})
+	return conn
+}
+
+// setupTest - Լռելյայն կարգավորումներ, թեստային բազա և S3
+func setupTest(t *testing.T) *fakeS3 {
+	t.Helper()
+	previousConfig, previousScanner := cfg, scanner
+	cfg = defaultConfig()
+	cfg.Storage.Bucket = "test"
+	cfg.Storage.UploadDir = "uploads"
+	scanner = nil
+	t.Cleanup(func() { cfg, scanner = previousConfig, previousScanner })
+
+	newTestDB(t)
+	return newTestS3(t)
+}
+
+// failDBWrites - Ձախողել տրված աղյուսակի Create կամ Update գործողությունները
+func failDBWrites(t *testing.T, operation, table string) {
+	t.Helper()
+	inject := func(tx *gorm.DB) {
+		if tx.Statement.Table == table {
+			tx.AddError(errInjected)
+		}
+	}
+	name := "test:fail_" + operation + "_" + table
+	var err error
+	switch operation {
+	case "create":
+		err = db.Callback().Create().Before("gorm:create").Register(name, inject)
+	case "update":
+		err = db.Callback().Update().Before("gorm:update").Register(name, inject)
+	case "delete":
+		err = db.Callback().Delete().Before("gorm:delete").Register(name, inject)
+	default:
+		t.Fatalf("անհայտ գործողություն %q", operation)
+	}
+	if err != nil {
+		t.Fatalf("callback: %v", err)
+	}
+}
+
+// insertImage - Ստեղծել ակտիվ նկարի գրառում և դրա օբյեկտը
+func insertImage(t *testing.T, fake *fakeS3, id string) Image {
+	t.Helper()
+	image := Image{
+		ID:               id,
+		ObjectKey:        "uploads/" + id + ".png",
+		Name:             id + ".png",
+		Size:             int64(len(testPNG)),
+		ContentType:      "image/png",
+		Status:           imageStatusActive,
+		ProcessingStatus: processingStatusReady,
+		UploadedAt:       time.Now(),
+	}
+	if err := db.Create(&image).Error; err != nil {
+		t.Fatalf("նկարի գրառում: %v", err)
+	}
+	fake.put(image.ObjectKey, testPNG)
+	return image
+}
+
+// testPNG - 1x1 չափի PNG նկար
+var testPNG = []byte{
+	0x89, 0x50, 0x4e, 0x47, 0x0d, 0x0a, 0x1a, 0x0a, 0x00, 0x00, 0x00, 0x0d, 0x49, 0x48, 0x44, 0x52,
+	0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01, 0x08, 0x06, 0x00, 0x00, 0x00, 0x1f, 0x15, 0xc4,
+	0x89, 0x00, 0x00, 0x00, 0x0d, 0x49, 0x44, 0x41, 0x54, 0x78, 0x9c, 0x63, 0x00, 0x01, 0x00, 0x00,
+	0x05, 0x00, 0x01, 0x0d, 0x0a, 0x2d, 0xb4, 0x00, 0x00, 0x00, 0x00, 0x49, 0x45, 0x4e, 0x44, 0xae,
+	0x42, 0x60, 0x82,
+}
+
+// multipartImage - Ձևի մարմին «image» դաշտով
+func multipartImage(t *testing.T, filename string, data []byte) (*bytes.Buffer, string) {
+	t.Helper()
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("image", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(data)
+	writer.Close()
+	return body, writer.FormDataContentType()
+}
+
+// serve - Կատարել հարցումը մեկ մարշրուտով ռոութերի վրա
+func serve(method, route, target string, handler gin.HandlerFunc, body io.Reader, contentType string) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.Handle(method, route, handler)
+	request := httptest.NewRequest(method, target, body)
+	if contentType != "" {
+		request.Header.Set("Content-Type", contentType)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+// recordEvents - Բաժանորդագրվել իրադարձություններին, վերադարձնում է մինչ այդ պահը ստացված տեսակները
+func recordEvents(t *testing.T) func() []string {
+	t.Helper()
+	log := recordEventLog(t)
+	return func() []string {
+		var types []string
+		for _, e := range log() {
+			types = append(types, e.Type)
+		}
+		return types
+	}
+}
+
+// recordEventLog - Ինչպես recordEvents-ը, բայց վերադարձնում է իրադարձություններն ամբողջությամբ
+func recordEventLog(t *testing.T) func() []event {
+	t.Helper()
+	ch, _, _ := bus.subscribe("")
+	t.Cleanup(func() { bus.unsubscribe(ch) })
+	var received []event
+	return func() []event {
+		for {
+			select {
+			case item := <-ch:
+				received = append(received, item.Event)
+			default:
+				return received
+			}
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	maxNameLength           = 255
+	maxCaptionLength        = 1000
+	maxAltTextLength        = 500
+	maxAttributeValueLength = 1000
+	// maxImageAttributes - մեկ նկարի ատրիբուտների առավելագույն քանակը
+	maxImageAttributes = 50
+)
+
+// errPreconditionFailed - նկարը փոփոխվել է If-Match-ում նշված տարբերակից հետո
+var errPreconditionFailed = errors.New("նկարը փոփոխվել է")
+
+// imageMetadataRequest - PATCH հարցման մարմինը, բացակայող դաշտերը չեն փոխվում
+type imageMetadataRequest struct {
+	Name    *string `json:"name"`
+	Caption *string `json:"caption"`
+	AltText *string `json:"altText"`
+	// Attributes - null արժեքը ջնջում է ատրիբուտը
+	Attributes map[string]*attributeInput `json:"attributes"`
+}
+
+// updateImageMetadataHandler - Նկարի անվան, նկարագրության և ատրիբուտների խմբագրում
+func updateImageMetadataHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	id := c.Param("id")
+
+	if !dbAvailable() {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Տվյալների բազան հասանելի չէ"})
+		return
+	}
+
+	var request imageMetadataRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Անվավեր հարցում"})
+		return
+	}
+	if message := validateImageMetadata(&request); message != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": message})
+		return
+	}
+
+	var image Image
+	if err := db.WithContext(ctx).Scopes(activeImages).First(&image, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Նկարը չի գտնվել"})
+		return
+	}
+
+	if match := c.GetHeader("If-Match"); match != "" && !etagMatches(match, imageETag(image)) {
+		c.Header("ETag", imageETag(image))
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Նկարը փոփոխվել է, թարմացրեք և փորձեք կրկին"})
+		return
+	}
+
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		updates := map[string]interface{}{"updated_at": time.Now()}
+		if request.Name != nil {
+			updates["name"] = *request.Name
+		}
+		if request.Caption != nil {
+			updates["caption"] = *request.Caption
+		}
+		if request.AltText != nil {
+			updates["alt_text"] = *request.AltText
+		}
+
+		// Պայմանական թարմացումը բացառում է միաժամանակյա խմբագրումների կորուստը
+		result := tx.Model(&Image{}).
+			Where("id = ? AND updated_at = ?", image.ID, image.UpdatedAt).
+			Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errPreconditionFailed
+		}
+
+		return setImageAttributes(tx, image.ID, request.Attributes)
+	})
+	if errors.Is(err, errPreconditionFailed) {
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Նկարը փոփոխվել է, թարմացրեք և փորձեք կրկին"})
+		return
+	}
+	if errors.Is(err, errTooManyAttributes) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Չափազանց շատ ատրիբուտներ"})
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Սխալ նկարի տվյալները խմբագրելիս", "image_id", id, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Չհաջողվեց թարմացնել նկարի տվյալները"})
+		return
+	}
+
+	// Կրկին կարդալ գրառումը, որպեսզի ETag-ը համընկնի բազայում պահված UpdatedAt-ի հետ
+	if err := db.WithContext(ctx).First(&image, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Չհաջողվեց թարմացնել նկարի տվյալները"})
+		return
+	}
+
+	response, err := createImageResponse(ctx, image)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Չհաջողվեց ստեղծել նկարի URL"})
+		return
+	}
+
+	c.Header("ETag", imageETag(image))
+	c.JSON(http.StatusOK, response)
+}
+
+// validateImageMetadata - Ստուգել խմբագրման հարցումը, վերադարձնում է սխալի հաղորդագրությունը
+func validateImageMetadata(request *imageMetadataRequest) string {
+	if request.Name != nil {
+		name := strings.TrimSpace(*request.Name)
+		if name == "" {
+			return "Անունը չի կարող դատարկ լինել"
+		}
+		if utf8.RuneCountInString(name) > maxNameLength || strings.ContainsAny(name, "/\\") {
+			return "Անվավեր անուն"
+		}
+		request.Name = &name
+	}
+	if request.Caption != nil && utf8.RuneCountInString(*request.Caption) > maxCaptionLength {
+		return "Նկարագրությունը չափազանց երկար է"
+	}
+	if request.AltText != nil && utf8.RuneCountInString(*request.AltText) > maxAltTextLength {
+		return "Այլընտրանքային տեքստը չափազանց երկար է"
+	}
+
+	if len(request.Attributes) > maxImageAttributes {
+		return "Չափազանց շատ ատրիբուտներ"
+	}
+	for key, value := range request.Attributes {
+		if !attributeKeyPattern.MatchString(key) {
+			return "Անվավեր ատրիբուտի բանալի: " + key
+		}
+		if value == nil {
+			continue
+		}
+		if utf8.RuneCountInString(value.Value) > maxAttributeValueLength {
+			return "Ատրիբուտի արժեքը չափազանց երկար է: " + key
+		}
+		if _, err := value.attribute("", key); err != nil {
+			return "Անվավեր ատրիբուտի արժեք " + key + ": " + err.Error()
+		}
+	}
+	return ""
+}
+
+// imageETag - Նկարի տվյալների տարբերակի նույնացուցիչը UpdatedAt-ից
+func imageETag(image Image) string {
+	return `"` + image.ID + "-" + strconv.FormatInt(image.UpdatedAt.UnixNano(), 36) + `"`
+}
+
+// etagMatches - Ստուգել If-Match վերնագիրը (մի քանի արժեք կամ "*"), թույլ ETag-երը չեն համընկնում
+func etagMatches(header, etag string) bool {
+	for _, value := range strings.Split(header, ",") {
+		value = strings.TrimSpace(value)
+		if value == "*" || value == etag {
+			return true
+		}
+	}
+	return false
+}
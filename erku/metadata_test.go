@@ -0,0 +1,111 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// patchMetadata - PATCH /api/images/:id If-Match վերնագրով
+func patchMetadata(id, body, ifMatch string) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.PATCH("/api/images/:id", updateImageMetadataHandler)
+	request := httptest.NewRequest(http.MethodPatch, "/api/images/"+id, strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	if ifMatch != "" {
+		request.Header.Set("If-Match", ifMatch)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+// currentETag - GET /api/images/:id պատասխանի ETag-ը
+func currentETag(t *testing.T, id string) string {
+	t.Helper()
+	response := serve(http.MethodGet, "/api/images/:id", "/api/images/"+id, getImageByIdHandler, nil, "")
+	if response.Code != http.StatusOK || response.Header().Get("ETag") == "" {
+		t.Fatalf("GET: կոդ %d, ETag %q", response.Code, response.Header().Get("ETag"))
+	}
+	return response.Header().Get("ETag")
+}
+
+func TestUpdateMetadataIfMatch(t *testing.T) {
+	fake := setupTest(t)
+	insertImage(t, fake, "img-1")
+
+	first := currentETag(t, "img-1")
+	response := patchMetadata("img-1", `{"caption": "first"}`, first)
+	if response.Code != http.StatusOK {
+		t.Fatalf("կոդ %d: %s", response.Code, response.Body)
+	}
+	second := response.Header().Get("ETag")
+	if second == first || second != currentETag(t, "img-1") {
+		t.Fatalf("ETag-ը չի թարմացվել: %s -> %s", first, second)
+	}
+
+	// Հին ETag-ով խմբագրումը մերժվում է և վերադարձնում ընթացիկ ETag-ը
+	response = patchMetadata("img-1", `{"caption": "stale"}`, first)
+	if response.Code != http.StatusPreconditionFailed {
+		t.Fatalf("հին ETag: կոդ %d, սպասվում էր 412", response.Code)
+	}
+	if response.Header().Get("ETag") != second {
+		t.Errorf("412-ի ETag %q, սպասվում էր %q", response.Header().Get("ETag"), second)
+	}
+	var image Image
+	db.First(&image, "id = ?", "img-1")
+	if image.Caption != "first" {
+		t.Errorf("նկարագրություն %q, սպասվում էր first", image.Caption)
+	}
+
+	tests := []struct {
+		name    string
+		ifMatch func() string
+		want    int
+	}{
+		{"թույլ ETag", func() string { return "W/" + currentETag(t, "img-1") }, http.StatusPreconditionFailed},
+		{"ցանկ", func() string { return `"other", ` + currentETag(t, "img-1") }, http.StatusOK},
+		{"աստղանիշ", func() string { return "*" }, http.StatusOK},
+		{"առանց If-Match", func() string { return "" }, http.StatusOK},
+	}
+	for _, tt := range tests {
+		if response := patchMetadata("img-1", `{"caption": "`+tt.name+`"}`, tt.ifMatch()); response.Code != tt.want {
+			t.Errorf("%s: կոդ %d, սպասվում էր %d", tt.name, response.Code, tt.want)
+		}
+	}
+}
+
+func TestUpdateMetadataConcurrentWriteFailsPrecondition(t *testing.T) {
+	fake := setupTest(t)
+	insertImage(t, fake, "img-1")
+	etag := currentETag(t, "img-1")
+
+	// Մեկ այլ խմբագրում հասնում է ETag-ի ստուգումից հետո, բայց թարմացումից առաջ: SQLite-ի միակ
+	// կապակցման պատճառով այն կատարվում է նույն տրանզակցիայում և հետ է գլորվում դրա հետ
+	raced := false
+	err := db.Callback().Update().Before("gorm:update").Register("test:concurrent_edit", func(tx *gorm.DB) {
+		if tx.Statement.Table == "images" && !raced {
+			raced = true
+			tx.Session(&gorm.Session{NewDB: true}).Exec("UPDATE images SET caption = ?, updated_at = ? WHERE id = ?",
+				"other", time.Now().Add(time.Second), "img-1")
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := patchMetadata("img-1", `{"caption": "mine", "attributes": {"rating": 5}}`, etag)
+	if response.Code != http.StatusPreconditionFailed {
+		t.Fatalf("կոդ %d, սպասվում էր 412: %s", response.Code, response.Body)
+	}
+	var attributes int64
+	db.Model(&ImageAttribute{}).Where("image_id = ?", "img-1").Count(&attributes)
+	if attributes != 0 {
+		t.Errorf("ատրիբուտները գրվել են մերժված խմբագրումից: %d", attributes)
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+	"github.com/aws/smithy-go/middleware"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"gorm.io/gorm"
+)
+
+const metricsNamespace = "erku"
+
+// Վերբեռնման եղանակները upload_* մետրիկաների method պիտակի համար
+const (
+	uploadMethodMultipart = "multipart"
+	uploadMethodBatch     = "batch"
+	uploadMethodTus       = "tus"
+	uploadMethodPresigned = "presigned"
+	uploadMethodReplace   = "replace"
+)
+
+var (
+	httpRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP հարցումների քանակը ըստ մարշրուտի և կարգավիճակի",
+	}, []string{"method", "route", "status"})
+
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP հարցումների տևողությունը ըստ մարշրուտի և կարգավիճակի",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	uploadSizeBytes = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "upload_size_bytes",
+		Help:      "Վերբեռնված նկարների չափը ըստ վերբեռնման եղանակի",
+		Buckets:   prometheus.ExponentialBuckets(16<<10, 4, 8),
+	}, []string{"method"})
+
+	uploadDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "upload_duration_seconds",
+		Help:      "Վերբեռնման տևողությունը սկզբից մինչև նկարի գրանցումը",
+		Buckets:   prometheus.ExponentialBuckets(0.05, 2, 12),
+	}, []string{"method"})
+
+	s3OperationDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "s3_operation_duration_seconds",
+		Help:      "S3 գործողությունների տևողությունը՝ ներառյալ կրկնությունները",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation"})
+
+	s3OperationErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "s3_operation_errors_total",
+		Help:      "Ձախողված S3 գործողությունների քանակը ըստ սխալի կոդի",
+	}, []string{"operation", "code"})
+
+	dbQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "GORM հարցումների տևողությունը ըստ գործողության և աղյուսակի",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"operation", "table"})
+)
+
+func init() {
+	prometheus.MustRegister(imageStatsCollector{
+		count: prometheus.NewDesc(metricsNamespace+"_images_stored", "Պահված նկարների քանակը՝ ներառյալ աղբամանը", nil, nil),
+		bytes: prometheus.NewDesc(metricsNamespace+"_images_stored_bytes", "Պահված նկարների ընդհանուր չափը", nil, nil),
+	})
+}
+
+// metricsMiddleware - Հաշվել HTTP հարցումները և դրանց տևողությունը
+func metricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		// Մարշրուտի ձևանմուշը, որպեսզի ID-ները չստեղծեն անսահմանափակ պիտակներ
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(c.Writer.Status())
+		httpRequestsTotal.WithLabelValues(c.Request.Method, route, status).Inc()
+		httpRequestDuration.WithLabelValues(c.Request.Method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// observeUpload - Գրանցել հաջողված վերբեռնման չափը և տևողությունը
+func observeUpload(method string, size int64, started time.Time) {
+	uploadSizeBytes.WithLabelValues(method).Observe(float64(size))
+	uploadDuration.WithLabelValues(method).Observe(time.Since(started).Seconds())
+}
+
+// withS3Metrics - S3 հաճախորդի տարբերակ, որը չափում է յուրաքանչյուր գործողություն
+func withS3Metrics(o *s3.Options) {
+	o.APIOptions = append(o.APIOptions, func(stack *middleware.Stack) error {
+		if isPresignStack(stack) {
+			return nil
+		}
+		operation := stack.ID()
+		return stack.Finalize.Add(middleware.FinalizeMiddlewareFunc("erkuMetrics",
+			func(ctx context.Context, in middleware.FinalizeInput, next middleware.FinalizeHandler) (middleware.FinalizeOutput, middleware.Metadata, error) {
+				start := time.Now()
+				out, metadata, err := next.HandleFinalize(ctx, in)
+				s3OperationDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+				if err != nil {
+					s3OperationErrors.WithLabelValues(operation, s3ErrorCode(err)).Inc()
+				}
+				return out, metadata, err
+			}), middleware.Before)
+	})
+}
+
+// isPresignStack - Presign-ի ժամանակ Deserialize քայլը դատարկվում է, և հարցում չի ուղարկվում
+func isPresignStack(stack *middleware.Stack) bool {
+	return len(stack.Deserialize.List()) == 0
+}
+
+// s3ErrorCode - S3 սխալի կոդը (օր.՝ NoSuchKey) կամ ցանցային սխալների ընդհանուր անվանումը
+func s3ErrorCode(err error) string {
+	var apiErr smithy.APIError
+	switch {
+	case errors.As(err, &apiErr):
+		return apiErr.ErrorCode()
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	default:
+		return "network"
+	}
+}
+
+// dbMetricsStartKey - հարցման սկզբի ժամանակը GORM-ի instance արժեքներում
+const dbMetricsStartKey = "metrics:start"
+
+// registerDBMetrics - Ավելացնել GORM callback-ներ, որոնք չափում են յուրաքանչյուր հարցում
+func registerDBMetrics(db *gorm.DB) error {
+	callbacks := db.Callback()
+	return errors.Join(
+		callbacks.Create().Before("gorm:create").Register("metrics:before_create", startDBTimer),
+		callbacks.Create().After("gorm:create").Register("metrics:after_create", observeDBQuery("create")),
+		callbacks.Query().Before("gorm:query").Register("metrics:before_query", startDBTimer),
+		callbacks.Query().After("gorm:query").Register("metrics:after_query", observeDBQuery("query")),
+		callbacks.Update().Before("gorm:update").Register("metrics:before_update", startDBTimer),
+		callbacks.Update().After("gorm:update").Register("metrics:after_update", observeDBQuery("update")),
+		callbacks.Delete().Before("gorm:delete").Register("metrics:before_delete", startDBTimer),
+		callbacks.Delete().After("gorm:delete").Register("metrics:after_delete", observeDBQuery("delete")),
+		callbacks.Row().Before("gorm:row").Register("metrics:before_row", startDBTimer),
+		callbacks.Row().After("gorm:row").Register("metrics:after_row", observeDBQuery("row")),
+		callbacks.Raw().Before("gorm:raw").Register("metrics:before_raw", startDBTimer),
+		callbacks.Raw().After("gorm:raw").Register("metrics:after_raw", observeDBQuery("raw")),
+	)
+}
+
+func startDBTimer(tx *gorm.DB) {
+	tx.InstanceSet(dbMetricsStartKey, time.Now())
+}
+
+// observeDBQuery - Գրանցել հարցման տևողությունը startDBTimer-ի պահած ժամանակից
+func observeDBQuery(operation string) func(*gorm.DB) {
+	return func(tx *gorm.DB) {
+		value, ok := tx.InstanceGet(dbMetricsStartKey)
+		if !ok {
+			return
+		}
+		table := tx.Statement.Table
+		if table == "" {
+			table = "unknown"
+		}
+		dbQueryDuration.WithLabelValues(operation, table).Observe(time.Since(value.(time.Time)).Seconds())
+	}
+}
+
+// imageStatsCollector - Նկարների քանակը և չափը հաշվվում են բազայից յուրաքանչյուր scrape-ի ժամանակ
+type imageStatsCollector struct {
+	count *prometheus.Desc
+	bytes *prometheus.Desc
+}
+
+func (c imageStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.count
+	ch <- c.bytes
+}
+
+func (c imageStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	if !dbAvailable() {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var stats struct {
+		Count int64
+		Bytes int64
+	}
+	err := db.WithContext(ctx).Unscoped().Model(&Image{}).
+		Select("COUNT(*) AS count, COALESCE(SUM(size), 0) AS bytes").
+		Scan(&stats).Error
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.count, err)
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.count, prometheus.GaugeValue, float64(stats.Count))
+	ch <- prometheus.MustNewConstMetric(c.bytes, prometheus.GaugeValue, float64(stats.Bytes))
+}
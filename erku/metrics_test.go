@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// metricValue - Մետրիկայի արժեքը labels զույգերով (անուն, արժեք, ...) ընտրված շարքից:
+// Հիստոգրամի համար՝ դիտարկումների քանակը, բացակայող շարքի համար՝ 0
+func metricValue(t *testing.T, gatherer prometheus.Gatherer, name string, labels ...string) float64 {
+	t.Helper()
+	families, err := gatherer.Gather()
+	if err != nil {
+		t.Fatalf("gather: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, metric := range family.GetMetric() {
+			values := make(map[string]string)
+			for _, label := range metric.GetLabel() {
+				values[label.GetName()] = label.GetValue()
+			}
+			for i := 0; i+1 < len(labels); i += 2 {
+				if values[labels[i]] != labels[i+1] {
+					continue metrics
+				}
+			}
+			switch {
+			case metric.GetHistogram() != nil:
+				return float64(metric.GetHistogram().GetSampleCount())
+			case metric.GetCounter() != nil:
+				return metric.GetCounter().GetValue()
+			default:
+				return metric.GetGauge().GetValue()
+			}
+		}
+	}
+	return 0
+}
+
+func TestMetricsMiddlewareLabelsByRoute(t *testing.T) {
+	router := gin.New()
+	router.Use(metricsMiddleware())
+	router.GET("/api/images/:id", func(c *gin.Context) { c.Status(http.StatusNotFound) })
+
+	requests := func(route, status string) float64 {
+		return metricValue(t, prometheus.DefaultGatherer, "erku_http_requests_total", "method", "GET", "route", route, "status", status)
+	}
+	byRoute, unmatched := requests("/api/images/:id", "404"), requests("unmatched", "404")
+
+	for _, target := range []string{"/api/images/a", "/api/images/b", "/nowhere"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+	}
+	// ID-ները չեն դառնում պիտակներ
+	if got := requests("/api/images/:id", "404") - byRoute; got != 2 {
+		t.Errorf("/api/images/:id: %v հարցում, սպասվում էր 2", got)
+	}
+	if got := requests("unmatched", "404") - unmatched; got != 1 {
+		t.Errorf("unmatched: %v հարցում, սպասվում էր 1", got)
+	}
+}
+
+func TestS3MetricsCountOperationsAndErrors(t *testing.T) {
+	setupTest(t)
+	client := s3.New(s3Client.Options(), withS3Metrics)
+	ctx := context.Background()
+
+	operations := func(operation string) float64 {
+		return metricValue(t, prometheus.DefaultGatherer, "erku_s3_operation_duration_seconds", "operation", operation)
+	}
+	failures := func(operation, code string) float64 {
+		return metricValue(t, prometheus.DefaultGatherer, "erku_s3_operation_errors_total", "operation", operation, "code", code)
+	}
+	puts, gets, missing := operations("PutObject"), operations("GetObject"), failures("GetObject", "NoSuchKey")
+
+	if _, err := client.PutObject(ctx, &s3.PutObjectInput{Bucket: aws.String("test"), Key: aws.String("a.png")}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String("test"), Key: aws.String("missing.png")}); err == nil {
+		t.Fatal("GetObject-ը պետք է ձախողվեր")
+	}
+	// Presign-ը հարցում չի ուղարկում և չի չափվում
+	if _, err := s3.NewPresignClient(client).PresignGetObject(ctx, &s3.GetObjectInput{Bucket: aws.String("test"), Key: aws.String("a.png")}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := operations("PutObject") - puts; got != 1 {
+		t.Errorf("PutObject: %v դիտարկում, սպասվում էր 1", got)
+	}
+	if got := operations("GetObject") - gets; got != 1 {
+		t.Errorf("GetObject: %v դիտարկում, սպասվում էր 1 (presign-ը չպետք է հաշվվի)", got)
+	}
+	if got := failures("GetObject", "NoSuchKey") - missing; got != 1 {
+		t.Errorf("GetObject NoSuchKey: %v սխալ, սպասվում էր 1", got)
+	}
+}
+
+func TestDBMetricsAndImageStats(t *testing.T) {
+	fake := setupTest(t)
+	if err := registerDBMetrics(db); err != nil {
+		t.Fatal(err)
+	}
+
+	queries := func(operation string) float64 {
+		return metricValue(t, prometheus.DefaultGatherer, "erku_db_query_duration_seconds", "operation", operation, "table", "images")
+	}
+	creates, reads := queries("create"), queries("query")
+
+	insertImage(t, fake, "img-1")
+	insertImage(t, fake, "img-2")
+	var image Image
+	db.First(&image, "id = ?", "img-1")
+
+	if got := queries("create") - creates; got != 2 {
+		t.Errorf("create: %v դիտարկում, սպասվում էր 2", got)
+	}
+	if got := queries("query") - reads; got < 1 {
+		t.Errorf("query: %v դիտարկում", got)
+	}
+
+	// Աղբամանի նկարները նույնպես զբաղեցնում են տեղ և հաշվվում են
+	db.Delete(&Image{}, "id = ?", "img-2")
+	if got := metricValue(t, prometheus.DefaultGatherer, "erku_images_stored"); got != 2 {
+		t.Errorf("erku_images_stored %v, սպասվում էր 2", got)
+	}
+	if got := metricValue(t, prometheus.DefaultGatherer, "erku_images_stored_bytes"); got != float64(2*len(testPNG)) {
+		t.Errorf("erku_images_stored_bytes %v, սպասվում էր %d", got, 2*len(testPNG))
+	}
+}
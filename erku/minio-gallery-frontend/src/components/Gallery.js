@@ -1,7 +1,7 @@
 // src/components/Gallery.js
 import React, { useState, useEffect } from 'react';
 import { Row, Col, Alert, Spinner } from 'react-bootstrap';
-import { fetchImages } from '../services/api';
+import { fetchImages, subscribeToEvents } from '../services/api';
 import ImageCard from './ImageCard';
 
 const Gallery = ({ refreshTrigger }) => {
@@ -10,9 +10,11 @@ const Gallery = ({ refreshTrigger }) => {
   const [error, setError] = useState('');
 
   // Նկարների ցանկի ստացում API-ից
-  const loadImages = async () => {
+  const loadImages = async (silent = false) => {
     try {
-      setLoading(true);
+      if (!silent) {
+        setLoading(true);
+      }
       const data = await fetchImages();
       // Համոզվենք, որ data-ն զանգված է (array)
       setImages(Array.isArray(data) ? data : []);
@@ -32,6 +34,11 @@ const Gallery = ({ refreshTrigger }) => {
     loadImages();
   }, [refreshTrigger]);
 
+  // Սերվերի իրադարձությունների դեպքում ցանկի թարմացում առանց էջի վերաբեռնման
+  useEffect(() => {
+    return subscribeToEvents(() => loadImages(true));
+  }, []);
+
   // Նկարների բացակայության դեպքում ցուցադրվող բովանդակություն
   if (loading) {
     return (
@@ -93,4 +93,18 @@ export const deleteImage = async (id) => {
     console.error(`Error deleting image ${id}:`, error);
     throw error;
   }
-};
\ No newline at end of file
+};
+// Պատկերասրահի փոփոխությունների ուղիղ հոսքին բաժանորդագրում (Server-Sent Events)
+// EventSource-ը ինքն է վերամիանում և ուղարկում Last-Event-ID-ն
+export const subscribeToEvents = (onEvent) => {
+  const source = new EventSource(`${API_URL}/events`);
+  const eventTypes = ['image.created', 'image.deleted', 'image.tagged', 'image.untagged', 'image.restored', 'stream.reset'];
+
+  eventTypes.forEach((type) => {
+    source.addEventListener(type, (message) => {
+      onEvent(type, message.data ? JSON.parse(message.data) : null);
+    });
+  });
+
+  return () => source.close();
+};
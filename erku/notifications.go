@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// jobTypeSyncObject - bucket-ի օբյեկտի և images աղյուսակի համաձայնեցում ծանուցումից հետո
+const jobTypeSyncObject = "sync_object"
+
+// defaultBucketEventDelay - ծանուցման մշակման հետաձգումը, որպեսզի erku-ի սեփական վերբեռնումները հասցնեն գրանցվել
+const defaultBucketEventDelay = 30 * time.Second
+
+// bucketNotification - MinIO webhook target-ի ծանուցման ձևաչափը
+type bucketNotification struct {
+	EventName string              `json:"EventName"`
+	Key       string              `json:"Key"`
+	Records   []bucketEventRecord `json:"Records"`
+}
+
+// bucketEventRecord - S3 իրադարձության գրառում
+type bucketEventRecord struct {
+	EventName string `json:"eventName"`
+	S3        struct {
+		Bucket struct {
+			Name string `json:"name"`
+		} `json:"bucket"`
+		Object struct {
+			// Key - URL-կոդավորված բանալի
+			Key string `json:"key"`
+		} `json:"object"`
+	} `json:"s3"`
+}
+
+// requireBucketEventToken - Ստուգել MinIO-ի auth_token-ը MINIO_WEBHOOK_TOKEN-ի հետ
+func requireBucketEventToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		expected := cfg.Auth.MinIOWebhookToken
+		if expected == "" {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "MinIO ծանուցումների թոքենը կարգավորված չէ"})
+			return
+		}
+
+		provided := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(expected)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Անվավեր կամ բացակայող թոքեն"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// bucketEventsHandler - MinIO/S3 ծանուցումների ընդունում bucket-ում արտաքինից կատարված փոփոխությունների համար
+func bucketEventsHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	if !dbAvailable() {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Տվյալների բազան հասանելի չէ"})
+		return
+	}
+
+	var notification bucketNotification
+	if err := c.ShouldBindJSON(&notification); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Անվավեր ծանուցում"})
+		return
+	}
+
+	bucketName := cfg.Storage.Bucket
+	uploadDir := cfg.Storage.UploadDir
+	delay := cfg.Uploads.BucketEventDelay
+
+	queued := 0
+	for _, record := range notification.Records {
+		if record.S3.Bucket.Name != bucketName {
+			continue
+		}
+		if !strings.HasPrefix(record.EventName, "s3:ObjectCreated:") && !strings.HasPrefix(record.EventName, "s3:ObjectRemoved:") {
+			continue
+		}
+
+		key, err := url.QueryUnescape(record.S3.Object.Key)
+		if err != nil {
+			continue
+		}
+		// Միայն նկարների պանակը, tus-ի մասերը և մեկուսացված օբյեկտները անտեսվում են
+		if !strings.HasPrefix(key, uploadDir) || strings.HasSuffix(key, "/") || len(key) > 255 {
+			continue
+		}
+
+		job := &Job{
+			Type:        jobTypeSyncObject,
+			Payload:     key,
+			Status:      jobStatusPending,
+			MaxAttempts: cfg.Jobs.MaxAttempts,
+			RunAt:       time.Now().Add(delay),
+		}
+		if err := db.WithContext(ctx).Create(job).Error; err != nil {
+			slog.ErrorContext(ctx, "Սխալ ծանուցումը հերթագրելիս", "key", key, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Չհաջողվեց մշակել ծանուցումը"})
+			return
+		}
+		queued++
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "queued": queued})
+}
+
+// syncObjectJob - Համաձայնեցնել օբյեկտի ընթացիկ վիճակը բազայի հետ. ծանուցման տեսակի փոխարեն
+// ստուգվում է օբյեկտի փաստացի վիճակը, ուստի կրկնվող կամ խառնված ծանուցումները անվտանգ են
+func syncObjectJob(ctx context.Context, job *Job) error {
+	key := job.Payload
+	bucketName := cfg.Storage.Bucket
+
+	// erku-ի միջոցով դեռ ավարտվող վերբեռնումը կգրանցի նկարը ինքնուրույն
+	var pending int64
+	err := db.WithContext(ctx).Model(&Upload{}).
+		Where("object_key = ? AND status IN ?", key, pendingUploadStatuses).
+		Count(&pending).Error
+	if err != nil {
+		return err
+	}
+	if pending > 0 {
+		return nil
+	}
+
+	var image Image
+	err = db.WithContext(ctx).Unscoped().Where("object_key = ?", key).First(&image).Error
+	exists := err == nil
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	head, err := s3Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(key),
+	})
+	var respErr *awshttp.ResponseError
+	if errors.As(err, &respErr) && respErr.HTTPStatusCode() == http.StatusNotFound {
+		return syncRemovedObject(ctx, image, exists)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		id, err := importOrphanObject(ctx, orphanObject{
+			Key:          key,
+			Size:         aws.ToInt64(head.ContentLength),
+			LastModified: aws.ToTime(head.LastModified),
+		})
+		if err != nil {
+			return err
+		}
+		slog.InfoContext(ctx, "Արտաքինից վերբեռնված օբյեկտը գրանցվեց", "image_id", id, "key", key)
+		return nil
+	}
+
+	// erku-ի սեփական գրառումները թարմացվում են օբյեկտից հետո, ուստի ավելի նոր օբյեկտը նշանակում է արտաքին փոփոխություն
+	if image.Status == imageStatusDeleting || !aws.ToTime(head.LastModified).After(image.UpdatedAt) {
+		return nil
+	}
+
+	updates := map[string]interface{}{"size": aws.ToInt64(head.ContentLength)}
+	if contentType := aws.ToString(head.ContentType); contentType != "" {
+		updates["content_type"] = contentType
+	}
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&image).Updates(updates).Error; err != nil {
+			return err
+		}
+		invalidatePresignedURL(image.ObjectKey)
+		return enqueueImageProcessing(tx, &image)
+	})
+}
+
+// syncRemovedObject - Ջնջել այն նկարի գրառումը, որի օբյեկտը հեռացվել է bucket-ից erku-ից դուրս
+func syncRemovedObject(ctx context.Context, image Image, exists bool) error {
+	// Տապանաքարով նկարները ջնջվում են հենց erku-ի կողմից
+	if !exists || image.Status == imageStatusDeleting {
+		return nil
+	}
+
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return deleteImageRows(tx, image.ID)
+	})
+	if err != nil {
+		return err
+	}
+
+	invalidatePresignedURL(image.ObjectKey)
+	slog.InfoContext(ctx, "Նկարը ջնջվեց, քանի որ դրա օբյեկտը հեռացվել է bucket-ից", "image_id", image.ID, "key", image.ObjectKey)
+	if !image.DeletedAt.Valid {
+		emitEvent(ctx, eventImageDeleted, newImageEventData(image, nil))
+	}
+	return nil
+}
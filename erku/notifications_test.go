@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestBucketEventTokenFailsClosed(t *testing.T) {
+	previous := cfg
+	t.Cleanup(func() { cfg = previous })
+
+	router := gin.New()
+	router.POST("/internal/minio/events", requireBucketEventToken(), func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	tests := []struct {
+		name          string
+		token         string
+		authorization string
+		want          int
+	}{
+		{name: "չկարգավորված թոքեն", want: http.StatusServiceUnavailable},
+		{name: "չկարգավորված թոքեն, դատարկ վերնագիր", authorization: "Bearer ", want: http.StatusServiceUnavailable},
+		{name: "բացակայող թոքեն", token: "secret", want: http.StatusUnauthorized},
+		{name: "սխալ թոքեն", token: "secret", authorization: "Bearer wrong", want: http.StatusUnauthorized},
+		{name: "վավեր թոքեն", token: "secret", authorization: "Bearer secret", want: http.StatusNoContent},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg.Auth.MinIOWebhookToken = test.token
+			request := httptest.NewRequest(http.MethodPost, "/internal/minio/events", nil)
+			if test.authorization != "" {
+				request.Header.Set("Authorization", test.authorization)
+			}
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+			if recorder.Code != test.want {
+				t.Fatalf("կոդ %d, սպասվում էր %d", recorder.Code, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// presignExpiry - նախապես ստորագրված URL-ի վավերականության ժամկետը
+	presignExpiry = 1 * time.Hour
+	// presignRefreshMargin - ժամկետի ավարտից որքան առաջ URL-ը վերաստորագրվի
+	presignRefreshMargin = 10 * time.Minute
+	// presignSweepInterval - հնացած գրառումների մաքրման հաճախականությունը
+	presignSweepInterval = 5 * time.Minute
+)
+
+var (
+	presignClient *s3.PresignClient
+	presignCache  = newURLCache()
+)
+
+// urlCacheEntry - քեշավորված URL-ը և դրա ժամկետի ավարտը
+type urlCacheEntry struct {
+	url       string
+	expiresAt time.Time
+}
+
+// urlCache - նախապես ստորագրված URL-ների հիշողության քեշ՝ TTL-ի հաշվառմամբ
+type urlCache struct {
+	mu        sync.Mutex
+	entries   map[string]urlCacheEntry
+	lastSweep time.Time
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+}
+
+// urlCacheStats - քեշի վիճակագրությունը API-ի համար
+type urlCacheStats struct {
+	Hits    uint64 `json:"hits"`
+	Misses  uint64 `json:"misses"`
+	Entries int    `json:"entries"`
+}
+
+func newURLCache() *urlCache {
+	return &urlCache{entries: make(map[string]urlCacheEntry)}
+}
+
+// get - Վերադարձնել URL-ը, եթե այն դեռ բավականաչափ հեռու է ժամկետի ավարտից
+func (uc *urlCache) get(key string, now time.Time) (string, bool) {
+	uc.mu.Lock()
+	entry, ok := uc.entries[key]
+	uc.mu.Unlock()
+
+	if !ok || now.Add(presignRefreshMargin).After(entry.expiresAt) {
+		uc.misses.Add(1)
+		return "", false
+	}
+
+	uc.hits.Add(1)
+	return entry.url, true
+}
+
+// set - Պահել URL-ը և պարբերաբար մաքրել ժամկետանց գրառումները
+func (uc *urlCache) set(key, url string, expiresAt, now time.Time) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+
+	uc.entries[key] = urlCacheEntry{url: url, expiresAt: expiresAt}
+
+	if now.Sub(uc.lastSweep) < presignSweepInterval {
+		return
+	}
+	for k, e := range uc.entries {
+		if now.After(e.expiresAt) {
+			delete(uc.entries, k)
+		}
+	}
+	uc.lastSweep = now
+}
+
+// invalidate - Հեռացնել օբյեկտի URL-ը քեշից (օր.՝ ջնջելուց կամ փոխարինելուց հետո)
+func (uc *urlCache) invalidate(key string) {
+	uc.mu.Lock()
+	delete(uc.entries, key)
+	uc.mu.Unlock()
+}
+
+func (uc *urlCache) stats() urlCacheStats {
+	uc.mu.Lock()
+	entries := len(uc.entries)
+	uc.mu.Unlock()
+
+	return urlCacheStats{
+		Hits:    uc.hits.Load(),
+		Misses:  uc.misses.Load(),
+		Entries: entries,
+	}
+}
+
+// presignCacheKey - Քեշի բանալին bucket-ի և օբյեկտի բանալու համադրությունն է
+func presignCacheKey(bucketName, objectKey string) string {
+	return bucketName + "/" + objectKey
+}
+
+// presignImageURL - Ստանալ օբյեկտի նախապես ստորագրված URL-ը քեշից կամ ստորագրել նորը
+func presignImageURL(ctx context.Context, objectKey string) (string, error) {
+	bucketName := cfg.Storage.Bucket
+	cacheKey := presignCacheKey(bucketName, objectKey)
+
+	now := time.Now()
+	if url, ok := presignCache.get(cacheKey, now); ok {
+		return url, nil
+	}
+
+	presignedURL, err := presignClient.PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectKey),
+	}, func(opts *s3.PresignOptions) {
+		opts.Expires = presignExpiry
+	})
+	if err != nil {
+		return "", err
+	}
+
+	presignCache.set(cacheKey, presignedURL.URL, now.Add(presignExpiry), now)
+	return presignedURL.URL, nil
+}
+
+// invalidatePresignedURL - Մոռանալ օբյեկտի քեշավորված URL-ը
+func invalidatePresignedURL(objectKey string) {
+	bucketName := cfg.Storage.Bucket
+	presignCache.invalidate(presignCacheKey(bucketName, objectKey))
+}
+
+// getPresignStatsHandler - Քեշի hit/miss վիճակագրության ստացում
+func getPresignStatsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, presignCache.stats())
+}
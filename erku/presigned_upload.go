@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// directUploadExpiry - ուղիղ վերբեռնման URL-ի վավերականության ժամկետը
+	directUploadExpiry = 15 * time.Minute
+	// directUploadGrace - URL-ի ժամկետից հետո complete-ի համար տրվող լրացուցիչ ժամանակը,
+	// որից հետո վերբեռնումը և արդեն PUT արված օբյեկտը ջնջվում են
+	directUploadGrace = 15 * time.Minute
+	// directUploadMaxSize - ուղիղ վերբեռնման առավելագույն չափը
+	directUploadMaxSize = 100 << 20
+	// sniffSize - բովանդակության տեսակը որոշելու համար կարդացվող բայթերի քանակը
+	sniffSize = 512
+)
+
+// presignUploadRequest - Ուղիղ վերբեռնման հայտի մարմինը
+type presignUploadRequest struct {
+	Filename    string   `json:"filename" binding:"required"`
+	ContentType string   `json:"contentType"`
+	Size        int64    `json:"size" binding:"required,gt=0"`
+	Tags        []string `json:"tags"`
+	// Method - "PUT" (լռությամբ) կամ "POST" policy-ով ձևի համար
+	Method string `json:"method"`
+}
+
+// presignUploadResponse - Հաճախորդին տրվող ստորագրված վերբեռնման տվյալները
+type presignUploadResponse struct {
+	ID        string            `json:"id"`
+	Method    string            `json:"method"`
+	URL       string            `json:"url"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	Fields    map[string]string `json:"fields,omitempty"`
+	ExpiresAt time.Time         `json:"expiresAt"`
+}
+
+// presignUploadHandler - Ստեղծել սպասող վերբեռնում և վերադարձնել ստորագրված PUT URL կամ POST policy
+func presignUploadHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	if !dbAvailable() {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Տվյալների բազան հասանելի չէ"})
+		return
+	}
+
+	var input presignUploadRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Անվավեր հարցում"})
+		return
+	}
+	if input.Size > directUploadMaxSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Ֆայլը չափազանց մեծ է"})
+		return
+	}
+
+	extension := filepath.Ext(input.Filename)
+	contentType := input.ContentType
+	if contentType == "" {
+		contentType = getContentType(extension)
+	}
+	if !strings.HasPrefix(contentType, "image/") {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Թույլատրվում են միայն նկարներ"})
+		return
+	}
+
+	method := strings.ToUpper(input.Method)
+	if method == "" {
+		method = http.MethodPut
+	}
+	if method != http.MethodPut && method != http.MethodPost {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "method-ը պետք է լինի PUT կամ POST"})
+		return
+	}
+
+	uploadID := newImageID()
+	uploadDir := cfg.Storage.UploadDir
+	bucketName := cfg.Storage.Bucket
+
+	upload := Upload{
+		ID:          uploadID,
+		ObjectKey:   filepath.Join(uploadDir, uploadID+extension),
+		Name:        input.Filename,
+		ContentType: contentType,
+		Tags:        strings.Join(input.Tags, ","),
+		Length:      input.Size,
+		Status:      uploadStatusPresigned,
+	}
+
+	response := presignUploadResponse{
+		ID:        upload.ID,
+		Method:    method,
+		ExpiresAt: time.Now().Add(directUploadExpiry),
+	}
+
+	if method == http.MethodPut {
+		presigned, err := presignClient.PresignPutObject(ctx, &s3.PutObjectInput{
+			Bucket:        aws.String(bucketName),
+			Key:           aws.String(upload.ObjectKey),
+			ContentType:   aws.String(contentType),
+			ContentLength: aws.Int64(input.Size),
+		}, func(opts *s3.PresignOptions) {
+			opts.Expires = directUploadExpiry
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "Սխալ PUT URL-ը ստորագրելիս", "image_id", upload.ID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Չհաջողվեց ստեղծել վերբեռնման URL"})
+			return
+		}
+
+		response.URL = presigned.URL
+		response.Headers = map[string]string{"Content-Type": contentType}
+	} else {
+		presigned, err := presignClient.PresignPostObject(ctx, &s3.PutObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String(upload.ObjectKey),
+		}, func(opts *s3.PresignPostOptions) {
+			opts.Expires = directUploadExpiry
+			opts.Conditions = []interface{}{
+				[]interface{}{"content-length-range", 1, directUploadMaxSize},
+				map[string]string{"Content-Type": contentType},
+			}
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "Սխալ POST policy-ն ստորագրելիս", "image_id", upload.ID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Չհաջողվեց ստեղծել վերբեռնման URL"})
+			return
+		}
+
+		presigned.Values["Content-Type"] = contentType
+		response.URL = presigned.URL
+		response.Fields = presigned.Values
+	}
+
+	if err := db.WithContext(ctx).Create(&upload).Error; err != nil {
+		slog.ErrorContext(ctx, "Սխալ վերբեռնման վիճակը պահելիս", "image_id", upload.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Չհաջողվեց ստեղծել վերբեռնումը"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// completeUploadHandler - Ստուգել bucket-ում հայտնված օբյեկտը և գրանցել նկարը
+func completeUploadHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	id := c.Param("id")
+
+	if !dbAvailable() {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Տվյալների բազան հասանելի չէ"})
+		return
+	}
+
+	var upload Upload
+	if err := db.WithContext(ctx).First(&upload, "id = ? AND status = ?", id, uploadStatusPresigned).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Վերբեռնումը չի գտնվել"})
+		return
+	}
+
+	bucketName := cfg.Storage.Bucket
+
+	head, err := s3Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(upload.ObjectKey),
+	})
+	if err != nil {
+		var respErr *awshttp.ResponseError
+		if errors.As(err, &respErr) && respErr.HTTPStatusCode() == http.StatusNotFound {
+			c.JSON(http.StatusConflict, gin.H{"error": "Ֆայլը դեռ վերբեռնված չէ"})
+			return
+		}
+		slog.ErrorContext(ctx, "Սխալ օբյեկտը ստուգելիս", "image_id", upload.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Չհաջողվեց ստուգել վերբեռնումը"})
+		return
+	}
+
+	size := aws.ToInt64(head.ContentLength)
+	if reason := verifyUploadedObject(ctx, &upload, size, aws.ToString(head.ContentType)); reason != "" {
+		// Անվավեր օբյեկտը չպետք է մնա bucket-ում
+		if _, err := s3Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String(upload.ObjectKey),
+		}); err != nil {
+			slog.ErrorContext(ctx, "Սխալ անվավեր օբյեկտը ջնջելիս", "image_id", upload.ID, "error", err)
+		}
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": reason})
+		return
+	}
+
+	image, err := finishUpload(ctx, &upload, size)
+	if errors.Is(err, errUploadExpired) {
+		c.JSON(http.StatusGone, gin.H{"error": "Վերբեռնման ժամկետն անցել է"})
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Սխալ նկարը բազայում պահելիս", "image_id", upload.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Չհաջողվեց պահել նկարը"})
+		return
+	}
+	observeUpload(uploadMethodPresigned, size, upload.CreatedAt)
+
+	// Մինչև սկաների ստուգումը նկարի URL-ը չի տրվում, հաճախորդը հետևում է նկարի վիճակին
+	if image.Status != imageStatusActive {
+		c.Header("Location", "/api/images/"+image.ID)
+		c.JSON(http.StatusAccepted, gin.H{
+			"id":               image.ID,
+			"status":           image.Status,
+			"processingStatus": image.ProcessingStatus,
+		})
+		return
+	}
+
+	response, err := createImageResponse(ctx, image)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Չհաջողվեց ստեղծել նկարի URL"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// verifyUploadedObject - Ստուգել չափը, տեսակը և իրական բովանդակությունը, վերադարձնել մերժման պատճառը
+func verifyUploadedObject(ctx context.Context, upload *Upload, size int64, contentType string) string {
+	if size != upload.Length {
+		return "Ֆայլի չափը չի համապատասխանում հայտարարվածին"
+	}
+	if contentType != upload.ContentType {
+		return "Ֆայլի տեսակը չի համապատասխանում հայտարարվածին"
+	}
+
+	// Բայթերը չեն անցել սերվերով, ուստի ստուգել ֆայլի սկիզբը
+	head, err := s3Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(cfg.Storage.Bucket),
+		Key:    aws.String(upload.ObjectKey),
+		Range:  aws.String("bytes=0-" + strconv.Itoa(sniffSize-1)),
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Սխալ ֆայլի սկիզբը կարդալիս", "image_id", upload.ID, "error", err)
+		return "Չհաջողվեց կարդալ ֆայլը"
+	}
+	defer head.Body.Close()
+
+	sniff, err := io.ReadAll(io.LimitReader(head.Body, sniffSize))
+	if err != nil {
+		return "Չհաջողվեց կարդալ ֆայլը"
+	}
+
+	if !isImageContent(upload.ContentType, sniff) {
+		return "Ֆայլը նկար չէ"
+	}
+
+	return ""
+}
+
+// expirePresignedUploads - Նշել որպես ժամկետանց այն ուղիղ վերբեռնումները, որոնց URL-ը և complete-ի
+// լրացուցիչ ժամանակն անցել են
+func expirePresignedUploads(ctx context.Context) error {
+	cutoff := time.Now().Add(-directUploadExpiry - directUploadGrace)
+	return db.WithContext(ctx).Model(&Upload{}).
+		Where("status = ? AND created_at < ?", uploadStatusPresigned, cutoff).
+		Update("status", uploadStatusExpired).Error
+}
+
+// deletePresignedObject - Ջնջել չավարտված ուղիղ վերբեռնման օբյեկտը, եթե հաճախորդը հասցրել է այն PUT անել
+func deletePresignedObject(ctx context.Context, upload *Upload) error {
+	_, err := s3Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(cfg.Storage.Bucket),
+		Key:    aws.String(upload.ObjectKey),
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Սխալ չավարտված վերբեռնման օբյեկտը ջնջելիս", "image_id", upload.ID, "error", err)
+	}
+	return err
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// insertPresignedUpload - Ուղիղ վերբեռնում, որի URL-ը տրվել է issuedAt-ին և օբյեկտը արդեն PUT է արված
+func insertPresignedUpload(t *testing.T, fake *fakeS3, id string, issuedAt time.Time) Upload {
+	t.Helper()
+	upload := Upload{
+		ID:          id,
+		ObjectKey:   "uploads/" + id + ".png",
+		Name:        "cat.png",
+		ContentType: "image/png",
+		Length:      int64(len(testPNG)),
+		Status:      uploadStatusPresigned,
+	}
+	if err := db.Create(&upload).Error; err != nil {
+		t.Fatalf("վերբեռնում: %v", err)
+	}
+	db.Model(&Upload{}).Where("id = ?", id).UpdateColumns(map[string]interface{}{"created_at": issuedAt, "updated_at": issuedAt})
+	fake.putTyped(upload.ObjectKey, "image/png", testPNG)
+	return upload
+}
+
+func TestSweepExpiredUploadsRemovesUncompletedPresignedUploads(t *testing.T) {
+	fake := setupTest(t)
+	abandoned := insertPresignedUpload(t, fake, "abandoned", time.Now().Add(-directUploadExpiry-directUploadGrace-time.Minute))
+	// URL-ի ժամկետն անցել է, բայց complete-ի լրացուցիչ ժամանակը դեռ ոչ
+	late := insertPresignedUpload(t, fake, "late", time.Now().Add(-directUploadExpiry-time.Minute))
+
+	sweepExpiredUploads(context.Background())
+
+	if fake.has(abandoned.ObjectKey) {
+		t.Fatal("չավարտված վերբեռնման օբյեկտը չի ջնջվել")
+	}
+	var count int64
+	db.Model(&Upload{}).Where("id = ?", abandoned.ID).Count(&count)
+	if count != 0 {
+		t.Fatal("ժամկետանց վերբեռնման գրառումը չի ջնջվել")
+	}
+
+	if !fake.has(late.ObjectKey) {
+		t.Fatal("լրացուցիչ ժամանակում գտնվող վերբեռնման օբյեկտը ջնջվել է")
+	}
+	response := serve(http.MethodPost, "/api/uploads/:id/complete", "/api/uploads/late/complete", completeUploadHandler, nil, "")
+	if response.Code != http.StatusOK && response.Code != http.StatusAccepted {
+		t.Fatalf("կոդ %d: %s", response.Code, response.Body)
+	}
+}
+
+func TestCompleteUploadFailsWhenSweptDuringCompletion(t *testing.T) {
+	fake := setupTest(t)
+	upload := insertPresignedUpload(t, fake, "img-1", time.Now())
+
+	// Մաքրիչը վերցնում է վերբեռնումը, մինչ complete-ը ստուգում է օբյեկտը
+	fake.before = func(op, key string) {
+		if op == "GET" && key == upload.ObjectKey {
+			db.Model(&Upload{}).Where("id = ?", upload.ID).Update("status", uploadStatusExpired)
+		}
+	}
+
+	response := serve(http.MethodPost, "/api/uploads/:id/complete", "/api/uploads/img-1/complete", completeUploadHandler, nil, "")
+	if response.Code != http.StatusGone {
+		t.Fatalf("կոդ %d, սպասվում էր 410: %s", response.Code, response.Body)
+	}
+	var count int64
+	db.Model(&Image{}).Unscoped().Count(&count)
+	if count != 0 {
+		t.Fatalf("ժամկետանց վերբեռնումից ստեղծվել է %d նկար", count)
+	}
+}
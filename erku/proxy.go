@@ -0,0 +1,168 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/gin-gonic/gin"
+)
+
+// proxyCacheControl - Cache-Control վերնագիրը պրոքսիով տրվող նկարների համար
+const proxyCacheControl = "private, max-age=3600"
+
+// requireAPIToken - Ստուգել API_TOKEN-ը Authorization: Bearer վերնագրում
+func requireAPIToken() gin.HandlerFunc {
+	return apiTokenMiddleware(false)
+}
+
+// requireMediaToken - Ինչպես requireAPIToken-ը, բայց GET հարցումների համար ընդունում է նաև access_token պարամետրը,
+// քանի որ <img> թեգերը չեն կարող վերնագրեր ուղարկել
+func requireMediaToken() gin.HandlerFunc {
+	return apiTokenMiddleware(true)
+}
+
+// apiTokenMiddleware - Թոքենի ստուգում. չկարգավորված թոքենի դեպքում մարշրուտը փակ է, ոչ թե բաց
+func apiTokenMiddleware(allowQuery bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		expected := cfg.Auth.APIToken
+		if expected == "" {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "API թոքենը կարգավորված չէ"})
+			return
+		}
+
+		var provided string
+		if value, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok {
+			provided = value
+		}
+		if provided == "" && allowQuery && c.Request.Method == http.MethodGet {
+			provided = c.Query("access_token")
+		}
+
+		if provided == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(expected)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Անվավեր կամ բացակայող թոքեն"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// downloadImageHandler - Նկարի բովանդակության հոսքային փոխանցում MinIO-ից
+func downloadImageHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	id := c.Param("id")
+
+	if !dbAvailable() {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Տվյալների բազան հասանելի չէ"})
+		return
+	}
+
+	var image Image
+	if err := db.WithContext(ctx).Scopes(activeImages).First(&image, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Նկարը չի գտնվել"})
+		return
+	}
+
+	streamObject(c, image.ObjectKey, image.Name)
+}
+
+// streamObject - Փոխանցել օբյեկտը հաճախորդին՝ հաշվի առնելով Range և պայմանական վերնագրերը
+func streamObject(c *gin.Context, objectKey, filename string) {
+	bucketName := cfg.Storage.Bucket
+
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectKey),
+	}
+	if rangeHeader := c.GetHeader("Range"); rangeHeader != "" {
+		input.Range = aws.String(rangeHeader)
+	}
+	if ifNoneMatch := c.GetHeader("If-None-Match"); ifNoneMatch != "" {
+		input.IfNoneMatch = aws.String(ifNoneMatch)
+	}
+	if ifModifiedSince := c.GetHeader("If-Modified-Since"); ifModifiedSince != "" {
+		if t, err := http.ParseTime(ifModifiedSince); err == nil {
+			input.IfModifiedSince = aws.Time(t)
+		}
+	}
+
+	result, err := s3Client.GetObject(c.Request.Context(), input)
+	if err != nil {
+		var respErr *awshttp.ResponseError
+		if errors.As(err, &respErr) {
+			switch respErr.HTTPStatusCode() {
+			case http.StatusNotModified:
+				// S3-ը 304-ի դեպքում նույնպես վերադարձնում է ETag և Last-Modified
+				copyHeader(c, respErr.Response.Header, "ETag")
+				copyHeader(c, respErr.Response.Header, "Last-Modified")
+				c.Header("Cache-Control", proxyCacheControl)
+				c.Status(http.StatusNotModified)
+				return
+			case http.StatusRequestedRangeNotSatisfiable:
+				copyHeader(c, respErr.Response.Header, "Content-Range")
+				c.JSON(http.StatusRequestedRangeNotSatisfiable, gin.H{"error": "Անվավեր Range վերնագիր"})
+				return
+			case http.StatusNotFound:
+				c.JSON(http.StatusNotFound, gin.H{"error": "Նկարը պահեստում չի գտնվել"})
+				return
+			}
+		}
+
+		slog.ErrorContext(c.Request.Context(), "Սխալ MinIO-ից օբյեկտը ստանալիս", "key", objectKey, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Չհաջողվեց ստանալ նկարը"})
+		return
+	}
+	defer result.Body.Close()
+
+	headers := map[string]string{
+		"Accept-Ranges":       "bytes",
+		"Cache-Control":       proxyCacheControl,
+		"Content-Disposition": contentDisposition(c.Query("download") == "1", filename),
+	}
+	if result.ETag != nil {
+		headers["ETag"] = *result.ETag
+	}
+	if result.LastModified != nil {
+		headers["Last-Modified"] = result.LastModified.UTC().Format(http.TimeFormat)
+	}
+
+	status := http.StatusOK
+	if result.ContentRange != nil {
+		headers["Content-Range"] = *result.ContentRange
+		status = http.StatusPartialContent
+	}
+
+	contentType := aws.ToString(result.ContentType)
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	c.DataFromReader(status, aws.ToInt64(result.ContentLength), contentType, result.Body, headers)
+}
+
+// contentDisposition - Ձևավորել Content-Disposition վերնագիրը inline կամ attachment ռեժիմով
+func contentDisposition(download bool, filename string) string {
+	disposition := "inline"
+	if download {
+		disposition = "attachment"
+	}
+
+	if value := mime.FormatMediaType(disposition, map[string]string{"filename": filename}); value != "" {
+		return value
+	}
+	return disposition
+}
+
+// copyHeader - Պատճենել վերնագիրը S3-ի պատասխանից, եթե այն առկա է
+func copyHeader(c *gin.Context, header http.Header, name string) {
+	if value := header.Get(name); value != "" {
+		c.Header(name, value)
+	}
+}
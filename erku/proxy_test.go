@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAPITokenMiddleware(t *testing.T) {
+	previous := cfg
+	t.Cleanup(func() { cfg = previous })
+
+	ok := func(c *gin.Context) { c.Status(http.StatusNoContent) }
+	router := gin.New()
+	router.GET("/admin", requireAPIToken(), ok)
+	router.GET("/raw", requireMediaToken(), ok)
+	router.POST("/raw", requireMediaToken(), ok)
+
+	tests := []struct {
+		name          string
+		token         string
+		method        string
+		target        string
+		authorization string
+		want          int
+	}{
+		{name: "չկարգավորված թոքեն", method: http.MethodGet, target: "/admin", authorization: "Bearer ", want: http.StatusServiceUnavailable},
+		{name: "չկարգավորված թոքեն, դատարկ query", method: http.MethodGet, target: "/raw?access_token=", want: http.StatusServiceUnavailable},
+		{name: "բացակայող թոքեն", token: "secret", method: http.MethodGet, target: "/admin", want: http.StatusUnauthorized},
+		{name: "սխալ թոքեն", token: "secret", method: http.MethodGet, target: "/admin", authorization: "Bearer wrong", want: http.StatusUnauthorized},
+		{name: "առանց Bearer", token: "secret", method: http.MethodGet, target: "/admin", authorization: "secret", want: http.StatusUnauthorized},
+		{name: "վավեր վերնագիր", token: "secret", method: http.MethodGet, target: "/admin", authorization: "Bearer secret", want: http.StatusNoContent},
+		{name: "query API մարշրուտում", token: "secret", method: http.MethodGet, target: "/admin?access_token=secret", want: http.StatusUnauthorized},
+		{name: "query պրոքսիում", token: "secret", method: http.MethodGet, target: "/raw?access_token=secret", want: http.StatusNoContent},
+		{name: "query պրոքսիի POST-ում", token: "secret", method: http.MethodPost, target: "/raw?access_token=secret", want: http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg.Auth.APIToken = test.token
+			request := httptest.NewRequest(test.method, test.target, nil)
+			if test.authorization != "" {
+				request.Header.Set("Authorization", test.authorization)
+			}
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+			if recorder.Code != test.want {
+				t.Fatalf("կոդ %d, սպասվում էր %d", recorder.Code, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"gorm.io/gorm"
+)
+
+// reconcileBatchSize - բազայից մեկ անգամում կարդացվող գրառումների քանակը
+const reconcileBatchSize = 500
+
+// reconcileOptions - reconcile ենթահրամանի դրոշները
+type reconcileOptions struct {
+	dryRun         bool
+	importOrphans  bool
+	deleteDangling bool
+	jsonOutput     bool
+	prefix         string
+}
+
+// storedObject - bucket-ում գտնված օբյեկտի տվյալները
+type storedObject struct {
+	size         int64
+	lastModified time.Time
+}
+
+// orphanObject - Օբյեկտ, որի համար բազայում գրառում չկա
+type orphanObject struct {
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"lastModified"`
+	ImportedID   string    `json:"importedId,omitempty"`
+}
+
+// danglingRow - Գրառում, որի օբյեկտը bucket-ում չկա
+type danglingRow struct {
+	ID        string `json:"id"`
+	ObjectKey string `json:"objectKey"`
+	Deleted   bool   `json:"deleted"`
+}
+
+// reconcileMismatch - Գրառման և օբյեկտի տվյալների անհամապատասխանություն
+type reconcileMismatch struct {
+	ID        string `json:"id"`
+	ObjectKey string `json:"objectKey"`
+	Field     string `json:"field"`
+	Database  string `json:"database"`
+	Storage   string `json:"storage"`
+}
+
+// reconcileReport - Համաձայնեցման արդյունքը
+type reconcileReport struct {
+	Bucket         string              `json:"bucket"`
+	Prefix         string              `json:"prefix"`
+	DryRun         bool                `json:"dryRun"`
+	ObjectsScanned int                 `json:"objectsScanned"`
+	RowsScanned    int                 `json:"rowsScanned"`
+	OrphanObjects  []orphanObject      `json:"orphanObjects"`
+	DanglingRows   []danglingRow       `json:"danglingRows"`
+	Mismatches     []reconcileMismatch `json:"mismatches"`
+	Errors         []string            `json:"errors"`
+}
+
+// runReconcile - `erku reconcile` ենթահրամանը, վերադարձնում է ելքի կոդը
+func runReconcile(args []string) int {
+	opts := reconcileOptions{}
+	flags := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+	flags.BoolVar(&opts.dryRun, "dry-run", false, "ցույց տալ փոփոխությունները առանց դրանք կատարելու")
+	flags.BoolVar(&opts.importOrphans, "import-orphans", false, "ստեղծել Image գրառումներ առանց գրառման օբյեկտների համար")
+	flags.BoolVar(&opts.deleteDangling, "delete-dangling", false, "ջնջել գրառումները, որոնց օբյեկտը չկա")
+	flags.BoolVar(&opts.jsonOutput, "json", false, "տպել հաշվետվությունը JSON ձևաչափով")
+	flags.StringVar(&opts.prefix, "prefix", cfg.Storage.UploadDir, "ստուգվող օբյեկտների նախածանցը")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if !dbAvailable() {
+		slog.Error("Սխալ: reconcile-ը պահանջում է տվյալների բազա")
+		return 1
+	}
+
+	report, err := reconcile(context.Background(), opts)
+	if err != nil {
+		slog.Error("Սխալ համաձայնեցման ժամանակ", "error", err)
+		return 1
+	}
+
+	if opts.jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			slog.Error("Սխալ հաշվետվությունը տպելիս", "error", err)
+			return 1
+		}
+	} else {
+		printReconcileReport(report)
+	}
+
+	if len(report.Errors) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// reconcile - Համեմատել bucket-ը և images աղյուսակը, և ըստ ընտրանքների ուղղել տարբերությունները
+func reconcile(ctx context.Context, opts reconcileOptions) (*reconcileReport, error) {
+	bucketName := cfg.Storage.Bucket
+	report := &reconcileReport{
+		Bucket:        bucketName,
+		Prefix:        opts.prefix,
+		DryRun:        opts.dryRun,
+		OrphanObjects: []orphanObject{},
+		DanglingRows:  []danglingRow{},
+		Mismatches:    []reconcileMismatch{},
+		Errors:        []string{},
+	}
+
+	objects, err := listStoredObjects(ctx, bucketName, opts.prefix)
+	if err != nil {
+		return nil, err
+	}
+	report.ObjectsScanned = len(objects)
+
+	// Գրառումները (ներառյալ աղբամանում գտնվողները) կարդացվում են խմբաքանակներով,
+	// համապատասխան օբյեկտները հանվում են ցանկից
+	var images []Image
+	err = db.WithContext(ctx).Unscoped().FindInBatches(&images, reconcileBatchSize, func(tx *gorm.DB, batch int) error {
+		for _, image := range images {
+			report.RowsScanned++
+
+			object, ok := objects[image.ObjectKey]
+			if !ok {
+				if strings.HasPrefix(image.ObjectKey, opts.prefix) {
+					report.DanglingRows = append(report.DanglingRows, danglingRow{ID: image.ID, ObjectKey: image.ObjectKey})
+				}
+				continue
+			}
+			delete(objects, image.ObjectKey)
+
+			compareImageWithObject(ctx, report, image, object)
+		}
+		return nil
+	}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// Ընթացիկ վերբեռնումների օբյեկտները դեռ գրառում չունեն, այն կստեղծի վերբեռնման ավարտը
+	var pendingKeys []string
+	err = db.WithContext(ctx).Model(&Upload{}).
+		Where("status IN ?", pendingUploadStatuses).
+		Pluck("object_key", &pendingKeys).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range pendingKeys {
+		delete(objects, key)
+	}
+
+	for key, object := range objects {
+		report.OrphanObjects = append(report.OrphanObjects, orphanObject{
+			Key:          key,
+			Size:         object.size,
+			LastModified: object.lastModified,
+		})
+	}
+
+	if opts.dryRun {
+		return report, nil
+	}
+
+	if opts.importOrphans {
+		for i := range report.OrphanObjects {
+			id, err := importOrphanObject(ctx, report.OrphanObjects[i])
+			if err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("import %s: %v", report.OrphanObjects[i].Key, err))
+				continue
+			}
+			report.OrphanObjects[i].ImportedID = id
+		}
+	}
+
+	if opts.deleteDangling {
+		for i := range report.DanglingRows {
+			err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+				return deleteImageRows(tx, report.DanglingRows[i].ID)
+			})
+			if err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("delete %s: %v", report.DanglingRows[i].ID, err))
+				continue
+			}
+			report.DanglingRows[i].Deleted = true
+		}
+	}
+
+	return report, nil
+}
+
+// listStoredObjects - Էջ առ էջ ստանալ bucket-ի բոլոր օբյեկտները տրված նախածանցով
+func listStoredObjects(ctx context.Context, bucketName, prefix string) (map[string]storedObject, error) {
+	objects := make(map[string]storedObject)
+
+	paginator := s3.NewListObjectsV2Paginator(s3Client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucketName),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range page.Contents {
+			key := aws.ToString(item.Key)
+			// Բացառել պանակները
+			if key == prefix || strings.HasSuffix(key, "/") {
+				continue
+			}
+			objects[key] = storedObject{
+				size:         aws.ToInt64(item.Size),
+				lastModified: aws.ToTime(item.LastModified),
+			}
+		}
+	}
+
+	return objects, nil
+}
+
+// compareImageWithObject - Համեմատել գրառման չափը և տեսակը օբյեկտի հետ
+func compareImageWithObject(ctx context.Context, report *reconcileReport, image Image, object storedObject) {
+	if image.Size != object.size {
+		report.Mismatches = append(report.Mismatches, reconcileMismatch{
+			ID:        image.ID,
+			ObjectKey: image.ObjectKey,
+			Field:     "size",
+			Database:  fmt.Sprint(image.Size),
+			Storage:   fmt.Sprint(object.size),
+		})
+	}
+
+	head, err := s3Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(report.Bucket),
+		Key:    aws.String(image.ObjectKey),
+	})
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("head %s: %v", image.ObjectKey, err))
+		return
+	}
+
+	if contentType := aws.ToString(head.ContentType); contentType != image.ContentType {
+		report.Mismatches = append(report.Mismatches, reconcileMismatch{
+			ID:        image.ID,
+			ObjectKey: image.ObjectKey,
+			Field:     "contentType",
+			Database:  image.ContentType,
+			Storage:   contentType,
+		})
+	}
+}
+
+// importOrphanObject - Ստեղծել Image գրառում առանց գրառման օբյեկտի համար
+func importOrphanObject(ctx context.Context, object orphanObject) (string, error) {
+	filename := filepath.Base(object.Key)
+	extension := filepath.Ext(filename)
+
+	contentType := getContentType(extension)
+	head, err := s3Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(cfg.Storage.Bucket),
+		Key:    aws.String(object.Key),
+	})
+	if err != nil {
+		return "", err
+	}
+	if value := aws.ToString(head.ContentType); value != "" {
+		contentType = value
+	}
+
+	// Օգտագործել ֆայլի անունից ստացված ID-ն (ինչպես getImagesFromMinIO-ում), եթե այն դեռ զբաղված չէ
+	id := strings.TrimSuffix(filename, extension)
+	var count int64
+	if err := db.WithContext(ctx).Unscoped().Model(&Image{}).Where("id = ?", id).Count(&count).Error; err != nil {
+		return "", err
+	}
+	if count > 0 || len(id) > 36 || id == "" {
+		id = newImageID()
+	}
+
+	image := Image{
+		ID:          id,
+		ObjectKey:   object.Key,
+		Name:        filename,
+		Size:        object.Size,
+		ContentType: contentType,
+		UploadedAt:  object.LastModified,
+	}
+	if err := createImageRecord(ctx, &image, nil); err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+// printReconcileReport - Տպել հաշվետվությունը մարդու համար ընթեռնելի տեսքով
+func printReconcileReport(report *reconcileReport) {
+	mode := ""
+	if report.DryRun {
+		mode = " (dry-run)"
+	}
+
+	fmt.Printf("Համաձայնեցում%s: bucket=%s prefix=%s\n", mode, report.Bucket, report.Prefix)
+	fmt.Printf("Ստուգված օբյեկտներ: %d, գրառումներ: %d\n\n", report.ObjectsScanned, report.RowsScanned)
+
+	fmt.Printf("Առանց գրառման օբյեկտներ: %d\n", len(report.OrphanObjects))
+	for _, object := range report.OrphanObjects {
+		status := ""
+		if object.ImportedID != "" {
+			status = " -> ներմուծված որպես " + object.ImportedID
+		}
+		fmt.Printf("  %s (%d բայթ)%s\n", object.Key, object.Size, status)
+	}
+
+	fmt.Printf("Առանց օբյեկտի գրառումներ: %d\n", len(report.DanglingRows))
+	for _, row := range report.DanglingRows {
+		status := ""
+		if row.Deleted {
+			status = " -> ջնջված"
+		}
+		fmt.Printf("  %s %s%s\n", row.ID, row.ObjectKey, status)
+	}
+
+	fmt.Printf("Անհամապատասխանություններ: %d\n", len(report.Mismatches))
+	for _, mismatch := range report.Mismatches {
+		fmt.Printf("  %s %s: բազա=%q պահեստ=%q\n", mismatch.ID, mismatch.Field, mismatch.Database, mismatch.Storage)
+	}
+
+	if len(report.Errors) > 0 {
+		fmt.Printf("Սխալներ: %d\n", len(report.Errors))
+		for _, message := range report.Errors {
+			fmt.Printf("  %s\n", message)
+		}
+	}
+}
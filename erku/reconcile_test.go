@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestReconcileSkipsObjectsOfPendingUploads(t *testing.T) {
+	fake := setupTest(t)
+	fake.put("uploads/orphan.png", testPNG)
+	fake.put("uploads/direct.png", testPNG)
+	fake.put("uploads/resumable.png", testPNG)
+	fake.put("uploads/done.png", testPNG)
+	for _, upload := range []Upload{
+		{ID: "direct", ObjectKey: "uploads/direct.png", Name: "direct.png", ContentType: "image/png", Status: uploadStatusPresigned},
+		{ID: "resumable", ObjectKey: "uploads/resumable.png", Name: "resumable.png", ContentType: "image/png", Status: uploadStatusInProgress},
+		{ID: "done", ObjectKey: "uploads/done.png", Name: "done.png", ContentType: "image/png", Status: uploadStatusCompleted},
+	} {
+		if err := db.Create(&upload).Error; err != nil {
+			t.Fatalf("upload: %v", err)
+		}
+	}
+
+	report, err := reconcile(context.Background(), reconcileOptions{importOrphans: true, prefix: "uploads/"})
+	if err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+
+	imported := make(map[string]bool)
+	for _, orphan := range report.OrphanObjects {
+		imported[orphan.Key] = orphan.ImportedID != ""
+	}
+	if len(imported) != 2 || !imported["uploads/orphan.png"] || !imported["uploads/done.png"] {
+		t.Fatalf("ներմուծված օբյեկտներ %v, սպասվում էր միայն orphan.png և done.png", imported)
+	}
+
+	var keys []string
+	db.Model(&Image{}).Order("object_key").Pluck("object_key", &keys)
+	if len(keys) != 2 {
+		t.Fatalf("ստեղծված նկարներ %v", keys)
+	}
+}
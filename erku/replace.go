@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// replaceImageContentHandler - Նկարի ֆայլի փոխարինում՝ պահպանելով ID-ն, անունը և թեգերը:
+// Ընդունում է ինչպես multipart ձև «image» դաշտով, այնպես էլ հարցման մարմնում ուղարկված նկարը
+func replaceImageContentHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	started := time.Now()
+	id := c.Param("id")
+
+	if !dbAvailable() {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Տվյալների բազան հասանելի չէ"})
+		return
+	}
+
+	var image Image
+	if err := db.WithContext(ctx).Scopes(activeImages).First(&image, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Նկարը չի գտնվել"})
+		return
+	}
+
+	body := io.Reader(c.Request.Body)
+	contentType := c.ContentType()
+	if contentType == "multipart/form-data" {
+		file, header, err := c.Request.FormFile("image")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Չհաջողվեց կարդալ ֆայլը"})
+			return
+		}
+		defer file.Close()
+		body = file
+		contentType = getContentType(filepath.Ext(header.Filename))
+	}
+
+	data, err := readLimited(body)
+	if errors.Is(err, errFileTooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Ֆայլը չափազանց մեծ է"})
+		return
+	}
+	if err != nil || len(data) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Չհաջողվեց կարդալ ֆայլը"})
+		return
+	}
+
+	if !isImageContent(contentType, data) {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Ֆայլը նկար չէ"})
+		return
+	}
+
+	if err := replaceImageObject(ctx, &image, data, contentType); err != nil {
+		slog.ErrorContext(ctx, "Սխալ նկարը փոխարինելիս", "image_id", id, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Չհաջողվեց փոխարինել նկարը"})
+		return
+	}
+	observeUpload(uploadMethodReplace, int64(len(data)), started)
+
+	response, err := createImageResponse(ctx, image)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Չհաջողվեց ստեղծել նկարի URL"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// replaceImageObject - Փոխարինել նկարի օբյեկտը և թարմացնել գրառումը այնպես, որ ձախողման դեպքում հինը մնա անփոփոխ
+func replaceImageObject(ctx context.Context, image *Image, data []byte, contentType string) error {
+	sum := sha256.Sum256(data)
+	width, height := imageDimensions(bytes.NewReader(data))
+	content := ImageVersion{
+		ImageID:     image.ID,
+		Size:        int64(len(data)),
+		ContentType: contentType,
+		Hash:        hex.EncodeToString(sum[:]),
+		Width:       width,
+		Height:      height,
+	}
+
+	bucketName := cfg.Storage.Bucket
+	replace := newSaga("replace image")
+
+	if versioningEnabled() {
+		// Տարբերակավորված bucket-ում նույն բանալու վրա գրելը պահպանում է նախորդ տարբերակը
+		if err := recordCurrentVersion(ctx, image); err != nil {
+			return err
+		}
+
+		result, err := s3Client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket:      aws.String(bucketName),
+			Key:         aws.String(image.ObjectKey),
+			Body:        bytes.NewReader(data),
+			ContentType: aws.String(contentType),
+		})
+		if err != nil {
+			return err
+		}
+		if result.VersionId != nil {
+			// Նոր տարբերակի ջնջումը վերադարձնում է նախորդը
+			replace.onFailure(deleteVersionCompensation(image.ObjectKey, *result.VersionId))
+		}
+
+		content.VersionID = aws.ToString(result.VersionId)
+		if err := applyImageVersion(ctx, image, content); err != nil {
+			replace.compensate(ctx)
+			return err
+		}
+		imageContentChanged(ctx, image)
+		return nil
+	}
+
+	// Առանց տարբերակավորման նոր ֆայլը գրվում է նոր բանալով, որպեսզի հինը մնա մինչև գրառման թարմացումը
+	oldKey := image.ObjectKey
+	uploadDir := cfg.Storage.UploadDir
+	extension := filepath.Ext(oldKey)
+	if value, ok := contentTypeExtensions[contentType]; ok {
+		extension = value
+	}
+	newKey := filepath.Join(uploadDir, image.ID+"-"+strconv.FormatInt(time.Now().UnixNano(), 36)+extension)
+
+	_, err := s3Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(bucketName),
+		Key:         aws.String(newKey),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		return err
+	}
+	replace.onFailure(deleteObjectCompensation(newKey))
+
+	err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return updateImageContent(tx, image, newKey, content)
+	})
+	if err != nil {
+		image.ObjectKey = oldKey
+		replace.compensate(ctx)
+		return err
+	}
+
+	// Հին օբյեկտն այլևս հղում չունի, դրա ջնջման ձախողումը կուղղի reconcile-ը
+	if err := deleteObjectCompensation(oldKey)(context.WithoutCancel(ctx)); err != nil {
+		slog.ErrorContext(ctx, "Սխալ հին օբյեկտը ջնջելիս", "image_id", image.ID, "key", oldKey, "error", err)
+	}
+	invalidatePresignedURL(oldKey)
+	imageContentChanged(ctx, image)
+	return nil
+}
+
+// contentTypeExtensions - MIME տեսակից ընդլայնում, փոխարինված ֆայլի բանալու համար
+var contentTypeExtensions = map[string]string{
+	"image/jpeg":    ".jpg",
+	"image/png":     ".png",
+	"image/gif":     ".gif",
+	"image/webp":    ".webp",
+	"image/svg+xml": ".svg",
+}
+
+// imageContentChanged - Թարմացնել նկարի բովանդակությունից կախված տվյալները
+func imageContentChanged(ctx context.Context, image *Image) {
+	invalidatePresignedURL(image.ObjectKey)
+	if err := enqueueImageProcessing(db.WithContext(ctx), image); err != nil {
+		slog.ErrorContext(ctx, "Սխալ նկարի մշակումը հերթագրելիս", "image_id", image.ID, "error", err)
+	}
+}
+
+// imageDimensions - Կարդալ նկարի լայնությունը և բարձրությունը վերնագրից (անհայտ ձևաչափի դեպքում՝ 0, 0)
+func imageDimensions(r io.Reader) (int, int) {
+	config, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return 0, 0
+	}
+	return config.Width, config.Height
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+)
+
+func TestReplaceImageContentAcceptsMultipartAndRawBody(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        func(t *testing.T) (*bytes.Buffer, string)
+		contentType string
+	}{
+		{name: "multipart", body: func(t *testing.T) (*bytes.Buffer, string) { return multipartImage(t, "new.png", testPNG) }},
+		{name: "raw", body: func(t *testing.T) (*bytes.Buffer, string) { return bytes.NewBuffer(testPNG), "image/png" }},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := setupTest(t)
+			image := insertImage(t, fake, "img-1")
+			if _, err := attachTags(db, image.ID, []string{"cats"}); err != nil {
+				t.Fatal(err)
+			}
+
+			body, contentType := test.body(t)
+			response := serve(http.MethodPut, "/api/images/:id/content", "/api/images/img-1/content", replaceImageContentHandler, body, contentType)
+			if response.Code != http.StatusOK {
+				t.Fatalf("կոդ %d: %s", response.Code, response.Body)
+			}
+
+			var current Image
+			db.First(&current, "id = ?", image.ID)
+			if current.ObjectKey == image.ObjectKey || !fake.has(current.ObjectKey) || fake.has(image.ObjectKey) {
+				t.Fatalf("օբյեկտը չի փոխարինվել: հին %s, նոր %s, bucket %v", image.ObjectKey, current.ObjectKey, fake.keys())
+			}
+			if current.ContentType != "image/png" {
+				t.Fatalf("տեսակ %q", current.ContentType)
+			}
+			var tags int64
+			db.Model(&ImageTag{}).Where("image_id = ?", image.ID).Count(&tags)
+			if tags != 1 {
+				t.Fatalf("թեգերը չեն պահպանվել: %d", tags)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"gorm.io/gorm"
+)
+
+const (
+	imageStatusActive = "active"
+	// imageStatusDeleting - «տապանաքար», գրառումը թաքցված է, իսկ օբյեկտը դեռ ջնջվում է
+	imageStatusDeleting = "deleting"
+)
+
+// saga - Քայլերի հաջորդականություն, որտեղ յուրաքանչյուր հաջողված քայլ գրանցում է իր փոխհատուցումը
+type saga struct {
+	name          string
+	compensations []func(context.Context) error
+}
+
+func newSaga(name string) *saga {
+	return &saga{name: name}
+}
+
+// onFailure - Գրանցել գործողություն, որը կչեղարկի արդեն կատարված քայլը
+func (s *saga) onFailure(compensation func(context.Context) error) {
+	s.compensations = append(s.compensations, compensation)
+}
+
+// compensate - Կատարել փոխհատուցումները հակառակ հերթականությամբ
+func (s *saga) compensate(ctx context.Context) {
+	// Փոխհատուցումը պետք է ավարտվի նույնիսկ եթե հաճախորդն արդեն անջատվել է
+	ctx = context.WithoutCancel(ctx)
+	for i := len(s.compensations) - 1; i >= 0; i-- {
+		if err := s.compensations[i](ctx); err != nil {
+			slog.ErrorContext(ctx, "Սխալ գործողության փոխհատուցման ժամանակ", "operation", s.name, "error", err)
+		}
+	}
+}
+
+// activeImages - Միայն տեսանելի (ոչ ջնջվող) նկարների ֆիլտր
+func activeImages(tx *gorm.DB) *gorm.DB {
+	return tx.Where("status = ?", imageStatusActive)
+}
+
+// deleteObjectCompensation - Փոխհատուցում, որը ջնջում է արդեն վերբեռնված օբյեկտը
+func deleteObjectCompensation(objectKey string) func(context.Context) error {
+	return func(ctx context.Context) error {
+		_, err := s3Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(cfg.Storage.Bucket),
+			Key:    aws.String(objectKey),
+		})
+		return err
+	}
+}
+
+// createImageRecord - Պահել նկարը, դրա թեգերը և մշակման աշխատանքը մեկ տրանզակցիայում
+func createImageRecord(ctx context.Context, image *Image, tagNames []string) error {
+	image.Status = initialImageStatus()
+	image.ProcessingStatus = processingStatusPending
+	var addedTags []string
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(image).Error; err != nil {
+			return err
+		}
+		var err error
+		if addedTags, err = attachTags(tx, image.ID, tagNames); err != nil {
+			return err
+		}
+		return enqueueNewImageProcessing(tx, image)
+	})
+	if err != nil {
+		return err
+	}
+
+	announceCreatedImage(ctx, *image, addedTags)
+	return nil
+}
+
+// purgeImage - Ընդմիշտ ջնջել նկարը. նախ գրառումը նշվում է որպես ջնջվող, ապա ջնջվում է օբյեկտը, վերջում՝ գրառումը
+func purgeImage(ctx context.Context, image *Image) error {
+	// Տապանաքարը թաքցնում է նկարը, որպեսզի ոչ ոք չստանա URL արդեն ջնջվող օբյեկտի համար
+	if err := db.WithContext(ctx).Unscoped().Model(image).Update("status", imageStatusDeleting).Error; err != nil {
+		return err
+	}
+
+	deleteObject := deleteObjectCompensation(image.ObjectKey)
+	if versioningEnabled() {
+		// Տարբերակավորված bucket-ում պարզ ջնջումը միայն նշիչ է ավելացնում
+		deleteObject = func(ctx context.Context) error { return deleteAllVersions(ctx, image.ObjectKey) }
+	}
+	if err := deleteObject(ctx); err != nil {
+		// Գրառումը մնում է տապանաքարով, հաջորդ մաքրումը կշարունակի այստեղից
+		return err
+	}
+	invalidatePresignedURL(image.ObjectKey)
+
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return deleteImageRows(tx, image.ID)
+	})
+}
+
+// purgeImages - Ընդմիշտ ջնջել մի քանի նկար՝ օբյեկտները ջնջելով մեկ DeleteObjects հարցումով.
+// Վերադարձնում է չջնջված նկարների սխալները ըստ ID-ի
+func purgeImages(ctx context.Context, images []Image) map[string]error {
+	failed := make(map[string]error)
+	if len(images) == 0 {
+		return failed
+	}
+
+	// Տարբերակավորված bucket-ում յուրաքանչյուր օբյեկտի բոլոր տարբերակները ջնջվում են առանձին
+	if versioningEnabled() {
+		for i := range images {
+			if err := purgeImage(ctx, &images[i]); err != nil {
+				failed[images[i].ID] = err
+			}
+		}
+		return failed
+	}
+
+	ids := make([]string, 0, len(images))
+	objects := make([]types.ObjectIdentifier, 0, len(images))
+	for _, image := range images {
+		ids = append(ids, image.ID)
+		objects = append(objects, types.ObjectIdentifier{Key: aws.String(image.ObjectKey)})
+	}
+
+	if err := db.WithContext(ctx).Unscoped().Model(&Image{}).Where("id IN ?", ids).Update("status", imageStatusDeleting).Error; err != nil {
+		for _, id := range ids {
+			failed[id] = err
+		}
+		return failed
+	}
+
+	// DeleteObjects-ը ընդունում է առավելագույնը 1000 բանալի
+	objectErrors := make(map[string]error)
+	for start := 0; start < len(objects); start += 1000 {
+		end := min(start+1000, len(objects))
+		result, err := s3Client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(cfg.Storage.Bucket),
+			Delete: &types.Delete{Objects: objects[start:end], Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			for _, object := range objects[start:end] {
+				objectErrors[aws.ToString(object.Key)] = err
+			}
+			continue
+		}
+		for _, objectError := range result.Errors {
+			objectErrors[aws.ToString(objectError.Key)] = errors.New(aws.ToString(objectError.Message))
+		}
+	}
+
+	// Չջնջված օբյեկտներով նկարները մնում են տապանաքարով, հաջորդ մաքրումը կշարունակի
+	var deleted []string
+	for _, image := range images {
+		if err, ok := objectErrors[image.ObjectKey]; ok {
+			failed[image.ID] = err
+			continue
+		}
+		invalidatePresignedURL(image.ObjectKey)
+		deleted = append(deleted, image.ID)
+	}
+
+	if len(deleted) > 0 {
+		err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			return deleteImageRows(tx, deleted...)
+		})
+		if err != nil {
+			for _, id := range deleted {
+				failed[id] = err
+			}
+		}
+	}
+	return failed
+}
+
+// deleteImageRows - Ջնջել նկարների գրառումները և դրանց բոլոր կապերը
+func deleteImageRows(tx *gorm.DB, imageIDs ...string) error {
+	for _, model := range []interface{}{&ImageTag{}, &ImageVersion{}, &ImageAttribute{}, &AlbumImage{}, &Job{}} {
+		if err := tx.Where("image_id IN ?", imageIDs).Delete(model).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Unscoped().Where("id IN ?", imageIDs).Delete(&Image{}).Error
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSagaCompensatesInReverseOrderAfterCancel(t *testing.T) {
+	var order []int
+	s := newSaga("test")
+	for i := 1; i <= 3; i++ {
+		s.onFailure(func(ctx context.Context) error {
+			if ctx.Err() != nil {
+				t.Errorf("փոխհատուցումը ստացել է չեղարկված context")
+			}
+			order = append(order, i)
+			return errInjected
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s.compensate(ctx)
+
+	if !slices.Equal(order, []int{3, 2, 1}) {
+		t.Fatalf("հերթականություն %v, սպասվում էր [3 2 1]", order)
+	}
+}
+
+func TestUploadImageDeletesObjectWhenDBWriteFails(t *testing.T) {
+	fake := setupTest(t)
+	failDBWrites(t, "create", "images")
+
+	body, contentType := multipartImage(t, "cat.png", testPNG)
+	response := serve(http.MethodPost, "/api/images", "/api/images", uploadImageHandler, body, contentType)
+
+	if response.Code < 500 {
+		t.Fatalf("կոդ %d, սպասվում էր 5xx: %s", response.Code, response.Body)
+	}
+	if strings.Contains(response.Body.String(), `"success"`) {
+		t.Fatalf("ձախողված վերբեռնումը վերադարձրել է success: %s", response.Body)
+	}
+
+	calls := fake.callLog()
+	if len(calls) != 2 || !strings.HasPrefix(calls[0], "PUT ") || calls[1] != "DELETE "+strings.TrimPrefix(calls[0], "PUT ") {
+		t.Fatalf("S3 գործողություններ %v, սպասվում էր PUT, ապա նույն բանալու DELETE", calls)
+	}
+	if keys := fake.keys(); len(keys) != 0 {
+		t.Fatalf("օբյեկտները մնացել են bucket-ում: %v", keys)
+	}
+
+	var count int64
+	db.Model(&Image{}).Unscoped().Count(&count)
+	if count != 0 {
+		t.Fatalf("բազայում մնացել է %d նկար", count)
+	}
+}
+
+func TestUploadImageRollsBackWhenJobEnqueueFails(t *testing.T) {
+	fake := setupTest(t)
+	failDBWrites(t, "create", "jobs")
+
+	body, contentType := multipartImage(t, "cat.png", testPNG)
+	response := serve(http.MethodPost, "/api/images", "/api/images", uploadImageHandler, body, contentType)
+
+	if response.Code < 500 {
+		t.Fatalf("կոդ %d, սպասվում էր 5xx: %s", response.Code, response.Body)
+	}
+	if keys := fake.keys(); len(keys) != 0 {
+		t.Fatalf("օբյեկտները մնացել են bucket-ում: %v", keys)
+	}
+	// Նկարի գրառումը ստեղծվել էր նույն տրանզակցիայում և պետք է հետ գլորվի
+	var count int64
+	db.Model(&Image{}).Unscoped().Count(&count)
+	if count != 0 {
+		t.Fatalf("բազայում մնացել է %d նկար", count)
+	}
+}
+
+func TestUploadImageFailsWhenPutObjectFails(t *testing.T) {
+	fake := setupTest(t)
+	fake.failOn("PUT")
+
+	body, contentType := multipartImage(t, "cat.png", testPNG)
+	response := serve(http.MethodPost, "/api/images", "/api/images", uploadImageHandler, body, contentType)
+
+	if response.Code < 500 {
+		t.Fatalf("կոդ %d, սպասվում էր 5xx: %s", response.Code, response.Body)
+	}
+	if strings.Contains(response.Body.String(), `"success"`) {
+		t.Fatalf("ձախողված վերբեռնումը վերադարձրել է success: %s", response.Body)
+	}
+
+	var count int64
+	db.Model(&Image{}).Unscoped().Count(&count)
+	if count != 0 {
+		t.Fatalf("բազայում ստեղծվել է %d նկար առանց օբյեկտի", count)
+	}
+}
+
+func TestPurgeImageTombstonesBeforeDeletingObject(t *testing.T) {
+	fake := setupTest(t)
+	image := insertImage(t, fake, "img-1")
+
+	var statusAtDelete string
+	fake.before = func(op, key string) {
+		if op == "DELETE" && key == image.ObjectKey {
+			var current Image
+			db.Unscoped().First(&current, "id = ?", image.ID)
+			statusAtDelete = current.Status
+		}
+	}
+
+	if err := purgeImage(context.Background(), &image); err != nil {
+		t.Fatalf("purgeImage: %v", err)
+	}
+
+	if statusAtDelete != imageStatusDeleting {
+		t.Fatalf("օբյեկտը ջնջելիս գրառման վիճակը %q էր, սպասվում էր %q", statusAtDelete, imageStatusDeleting)
+	}
+	if fake.has(image.ObjectKey) {
+		t.Fatal("օբյեկտը չի ջնջվել")
+	}
+	var count int64
+	db.Model(&Image{}).Unscoped().Where("id = ?", image.ID).Count(&count)
+	if count != 0 {
+		t.Fatal("գրառումը չի ջնջվել")
+	}
+}
+
+func TestPurgeImageKeepsTombstoneWhenDeleteObjectFails(t *testing.T) {
+	fake := setupTest(t)
+	image := insertImage(t, fake, "img-1")
+	fake.failOn("DELETE")
+
+	if err := purgeImage(context.Background(), &image); err == nil {
+		t.Fatal("purgeImage-ը պետք է վերադարձնի սխալ")
+	}
+
+	var current Image
+	if err := db.Unscoped().First(&current, "id = ?", image.ID).Error; err != nil {
+		t.Fatalf("գրառումը ջնջվել է, չնայած օբյեկտը մնացել է: %v", err)
+	}
+	if current.Status != imageStatusDeleting {
+		t.Fatalf("վիճակ %q, սպասվում էր %q", current.Status, imageStatusDeleting)
+	}
+	// Տապանաքարով նկարը չպետք է երևա ցանկերում
+	var visible int64
+	db.Model(&Image{}).Scopes(activeImages).Count(&visible)
+	if visible != 0 {
+		t.Fatal("տապանաքարով նկարը երևում է ցանկում")
+	}
+}
+
+func TestPurgeImageKeepsObjectWhenTombstoneFails(t *testing.T) {
+	fake := setupTest(t)
+	image := insertImage(t, fake, "img-1")
+	failDBWrites(t, "update", "images")
+
+	if err := purgeImage(context.Background(), &image); !errors.Is(err, errInjected) {
+		t.Fatalf("սխալ %v, սպասվում էր %v", err, errInjected)
+	}
+	if !fake.has(image.ObjectKey) {
+		t.Fatal("օբյեկտը ջնջվել է առանց տապանաքարի")
+	}
+	for _, call := range fake.callLog() {
+		if strings.HasPrefix(call, "DELETE") {
+			t.Fatalf("անսպասելի S3 գործողություն %q", call)
+		}
+	}
+}
+
+func TestPurgeImagesTombstonesBeforeDeletingObjects(t *testing.T) {
+	fake := setupTest(t)
+	images := []Image{insertImage(t, fake, "img-1"), insertImage(t, fake, "img-2")}
+
+	var activeAtDelete int64 = -1
+	fake.before = func(op, key string) {
+		if op == "DELETE_OBJECTS" {
+			db.Model(&Image{}).Scopes(activeImages).Count(&activeAtDelete)
+		}
+	}
+
+	if failed := purgeImages(context.Background(), images); len(failed) != 0 {
+		t.Fatalf("purgeImages: %v", failed)
+	}
+	if activeAtDelete != 0 {
+		t.Fatalf("օբյեկտները ջնջելիս %d նկար դեռ ակտիվ էր", activeAtDelete)
+	}
+	if keys := fake.keys(); len(keys) != 0 {
+		t.Fatalf("օբյեկտները մնացել են bucket-ում: %v", keys)
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"gorm.io/gorm"
+)
+
+const (
+	// imageStatusScanning - նկարը դեռ ստուգվում է և թաքցված է ցանկերից
+	imageStatusScanning = "scanning"
+	// imageStatusQuarantined - սկաները հայտնաբերել է վտանգավոր բովանդակություն
+	imageStatusQuarantined = "quarantined"
+	// imageStatusScanFailed - ստուգման բոլոր փորձերը ձախողվել են, նկարը կարելի է նորից ուղարկել ստուգման
+	imageStatusScanFailed = "scan_failed"
+)
+
+const (
+	defaultQuarantinePrefix = "quarantine/"
+	defaultClamdTimeout     = 30 * time.Second
+	// clamdChunkSize - INSTREAM պրոտոկոլով ուղարկվող մեկ հատվածի չափը
+	clamdChunkSize = 64 << 10
+	// scanHeaderSize - չափերի որոշման համար պահվող սկզբնական բայթերը
+	scanHeaderSize = 1 << 20
+)
+
+// scanResult - Սկանավորման արդյունքը
+type scanResult struct {
+	Clean     bool
+	Signature string
+}
+
+// imageScanner - Բովանդակության ստուգիչ, որը կանչվում է յուրաքանչյուր վերբեռնումից հետո
+type imageScanner interface {
+	// Scan - պետք է կարդա r-ը մինչև վերջ
+	Scan(ctx context.Context, r io.Reader) (scanResult, error)
+}
+
+// scanner - ընթացիկ սկաները, nil է, եթե ստուգումն անջատված է
+var scanner imageScanner
+
+// initScanner - Կարգավորել սկաները CLAMD_ADDRESS փոփոխականից (օր.՝ "localhost:3310")
+func initScanner() {
+	address := cfg.Scanner.ClamdAddress
+	if address == "" {
+		return
+	}
+	scanner = &clamdScanner{
+		address: address,
+		timeout: cfg.Scanner.ClamdTimeout,
+	}
+}
+
+// initialImageStatus - Նոր նկարը տեսանելի է դառնում միայն ստուգումից հետո, եթե սկաները միացված է
+func initialImageStatus() string {
+	if scanner != nil {
+		return imageStatusScanning
+	}
+	return imageStatusActive
+}
+
+// clamdScanner - ClamAV-համատեղելի daemon TCP INSTREAM պրոտոկոլով
+type clamdScanner struct {
+	address string
+	timeout time.Duration
+}
+
+// Scan - Ուղարկել բովանդակությունը clamd-ին հատվածներով և կարդալ պատասխանը
+func (s *clamdScanner) Scan(ctx context.Context, r io.Reader) (scanResult, error) {
+	dialer := net.Dialer{Timeout: s.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", s.address)
+	if err != nil {
+		return scanResult{}, err
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(s.timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	conn.SetDeadline(deadline)
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return scanResult{}, err
+	}
+
+	// Յուրաքանչյուր հատված՝ 4 բայթ երկարություն (big-endian) + տվյալներ, վերջում՝ զրոյական երկարություն
+	chunk := make([]byte, clamdChunkSize)
+	size := make([]byte, 4)
+	for {
+		n, readErr := io.ReadFull(r, chunk)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size, uint32(n))
+			if _, err := conn.Write(size); err != nil {
+				return scanResult{}, err
+			}
+			if _, err := conn.Write(chunk[:n]); err != nil {
+				return scanResult{}, err
+			}
+		}
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			break
+		}
+		if readErr != nil {
+			return scanResult{}, readErr
+		}
+	}
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return scanResult{}, err
+	}
+
+	reply, err := io.ReadAll(conn)
+	if err != nil {
+		return scanResult{}, err
+	}
+	return parseClamdReply(string(reply))
+}
+
+// parseClamdReply - "stream: OK", "stream: <signature> FOUND" կամ "... ERROR"
+func parseClamdReply(reply string) (scanResult, error) {
+	reply = strings.TrimSpace(strings.TrimRight(reply, "\x00"))
+	reply = strings.TrimPrefix(reply, "stream: ")
+
+	switch {
+	case reply == "OK":
+		return scanResult{Clean: true}, nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return scanResult{Signature: strings.TrimSuffix(reply, " FOUND")}, nil
+	default:
+		return scanResult{}, fmt.Errorf("clamd: %s", reply)
+	}
+}
+
+// prefixBuffer - Պահել հոսքի միայն առաջին limit բայթերը
+type prefixBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *prefixBuffer) Write(p []byte) (int, error) {
+	if remaining := b.limit - b.Len(); remaining > 0 {
+		b.Buffer.Write(p[:min(len(p), remaining)])
+	}
+	return len(p), nil
+}
+
+// quarantineImage - Տեղափոխել վտանգավոր օբյեկտը առանձին նախածանցի տակ և նշել նկարը որպես մեկուսացված
+func quarantineImage(ctx context.Context, image *Image, signature string) error {
+	bucketName := cfg.Storage.Bucket
+	oldKey := image.ObjectKey
+	newKey := cfg.Storage.QuarantinePrefix + path.Base(oldKey)
+
+	quarantine := newSaga("quarantine")
+	_, err := s3Client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String(bucketName),
+		Key:        aws.String(newKey),
+		CopySource: aws.String(copySource(bucketName, oldKey)),
+	})
+	if err != nil {
+		return err
+	}
+	quarantine.onFailure(deleteObjectCompensation(newKey))
+
+	err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return tx.Model(image).Updates(map[string]interface{}{
+			"object_key":  newKey,
+			"status":      imageStatusQuarantined,
+			"scan_result": signature,
+		}).Error
+	})
+	if err != nil {
+		quarantine.compensate(ctx)
+		return err
+	}
+
+	// Բնօրինակը ջնջվում է միայն այն բանից հետո, երբ գրառումն արդեն ցույց է տալիս մեկուսացված պատճենը.
+	// Ձախողման դեպքում մնացած օբյեկտը կհայտնաբերի reconcile-ը
+	if err := deleteObjectCompensation(oldKey)(ctx); err != nil {
+		slog.ErrorContext(ctx, "Սխալ մեկուսացված նկարի բնօրինակը ջնջելիս", "image_id", image.ID, "key", oldKey, "error", err)
+	}
+	invalidatePresignedURL(oldKey)
+	return nil
+}
@@ -0,0 +1,388 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// clamdStub - INSTREAM պրոտոկոլով աշխատող clamd-ի իմիտացիա
+type clamdStub struct {
+	address string
+	// reply - պատասխանը բովանդակությունը ստանալուց հետո, դատարկի դեպքում կապը փակվում է առանց պատասխանի
+	reply string
+
+	mu       sync.Mutex
+	received []byte
+	command  string
+	done     chan struct{}
+}
+
+// newClamdStub - Գործարկել clamd-ի իմիտացիան պատահական պորտում
+func newClamdStub(t *testing.T, reply string) *clamdStub {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	stub := &clamdStub{address: listener.Addr().String(), reply: reply, done: make(chan struct{}, 16)}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go stub.handle(conn)
+		}
+	}()
+	return stub
+}
+
+func (s *clamdStub) handle(conn net.Conn) {
+	defer func() { s.done <- struct{}{} }()
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	command := make([]byte, len("zINSTREAM\x00"))
+	if _, err := io.ReadFull(conn, command); err != nil {
+		return
+	}
+
+	// Հատվածներ՝ 4 բայթ երկարություն + տվյալներ, մինչև զրոյական երկարությունը
+	var received bytes.Buffer
+	size := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(conn, size); err != nil {
+			return
+		}
+		n := binary.BigEndian.Uint32(size)
+		if n == 0 {
+			break
+		}
+		if _, err := io.CopyN(&received, conn, int64(n)); err != nil {
+			return
+		}
+	}
+
+	s.mu.Lock()
+	s.command = string(command)
+	s.received = received.Bytes()
+	s.mu.Unlock()
+
+	if s.reply != "" {
+		conn.Write([]byte(s.reply + "\x00"))
+	}
+}
+
+// scanner - clamdScanner դեպի իմիտացիան
+func (s *clamdStub) scanner() *clamdScanner {
+	return &clamdScanner{address: s.address, timeout: 5 * time.Second}
+}
+
+func TestClamdScannerReplies(t *testing.T) {
+	// Մի քանի հատվածից բաղկացած բովանդակություն
+	content := bytes.Repeat([]byte("erku"), clamdChunkSize/2+123)
+
+	tests := []struct {
+		name      string
+		reply     string
+		want      scanResult
+		wantError bool
+	}{
+		{name: "clean", reply: "stream: OK", want: scanResult{Clean: true}},
+		{name: "found", reply: "stream: Eicar-Test-Signature FOUND", want: scanResult{Signature: "Eicar-Test-Signature"}},
+		{name: "error", reply: "INSTREAM size limit exceeded. ERROR", wantError: true},
+		{name: "dropped connection", reply: "", wantError: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stub := newClamdStub(t, test.reply)
+
+			result, err := stub.scanner().Scan(context.Background(), bytes.NewReader(content))
+			if test.wantError {
+				if err == nil {
+					t.Fatalf("սպասվում էր սխալ, ստացվել է %+v", result)
+				}
+			} else if err != nil {
+				t.Fatalf("Scan: %v", err)
+			}
+			if result != test.want {
+				t.Fatalf("արդյունք %+v, սպասվում էր %+v", result, test.want)
+			}
+
+			<-stub.done
+			stub.mu.Lock()
+			defer stub.mu.Unlock()
+			if stub.command != "zINSTREAM\x00" {
+				t.Fatalf("հրաման %q", stub.command)
+			}
+			if !bytes.Equal(stub.received, content) {
+				t.Fatalf("clamd-ը ստացել է %d բայթ, սպասվում էր %d", len(stub.received), len(content))
+			}
+		})
+	}
+}
+
+func TestClamdScannerConnectionClosedBeforeStream(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			conn.Close()
+		}
+	}()
+
+	scanner := &clamdScanner{address: listener.Addr().String(), timeout: 5 * time.Second}
+	if _, err := scanner.Scan(context.Background(), strings.NewReader("data")); err == nil {
+		t.Fatal("խզված կապը պետք է վերադարձնի սխալ")
+	}
+}
+
+func TestParseClamdReply(t *testing.T) {
+	tests := []struct {
+		reply     string
+		want      scanResult
+		wantError bool
+	}{
+		{reply: "stream: OK\x00", want: scanResult{Clean: true}},
+		{reply: "stream: OK\n", want: scanResult{Clean: true}},
+		{reply: "stream: Win.Test.EICAR_HDB-1 FOUND\x00", want: scanResult{Signature: "Win.Test.EICAR_HDB-1"}},
+		{reply: "INSTREAM size limit exceeded. ERROR\x00", wantError: true},
+		{reply: "", wantError: true},
+	}
+	for _, test := range tests {
+		result, err := parseClamdReply(test.reply)
+		if (err != nil) != test.wantError || result != test.want {
+			t.Errorf("parseClamdReply(%q) = %+v, %v", test.reply, result, err)
+		}
+	}
+}
+
+// scanningImage - Նկար, որը սպասում է ստուգման, ինչպես վերբեռնումից անմիջապես հետո
+func scanningImage(t *testing.T, fake *fakeS3, id string) Image {
+	t.Helper()
+	image := insertImage(t, fake, id)
+	db.Model(&image).Updates(map[string]interface{}{"status": imageStatusScanning, "processing_status": processingStatusPending})
+	image.Status = imageStatusScanning
+	return image
+}
+
+// listedImageIDs - GET /api/images-ի վերադարձրած ID-ները
+func listedImageIDs(t *testing.T) []string {
+	t.Helper()
+	response := serve(http.MethodGet, "/api/images", "/api/images", getAllImagesHandler, nil, "")
+	if response.Code != http.StatusOK {
+		t.Fatalf("GET /api/images: %d %s", response.Code, response.Body)
+	}
+	var images []ImageResponse
+	if err := json.Unmarshal(response.Body.Bytes(), &images); err != nil {
+		t.Fatalf("պատասխան: %v", err)
+	}
+	ids := make([]string, 0, len(images))
+	for _, image := range images {
+		ids = append(ids, image.ID)
+	}
+	return ids
+}
+
+func TestProcessImageJobQuarantinesInfectedImage(t *testing.T) {
+	fake := setupTest(t)
+	scanner = newClamdStub(t, "stream: Eicar-Test-Signature FOUND").scanner()
+	image := scanningImage(t, fake, "img-1")
+
+	if ids := listedImageIDs(t); len(ids) != 0 {
+		t.Fatalf("ստուգվող նկարը երևում է ցանկում: %v", ids)
+	}
+
+	if err := processImageJob(context.Background(), &Job{ImageID: image.ID}); err != nil {
+		t.Fatalf("processImageJob: %v", err)
+	}
+
+	var current Image
+	db.First(&current, "id = ?", image.ID)
+	wantKey := cfg.Storage.QuarantinePrefix + "img-1.png"
+	if current.Status != imageStatusQuarantined || current.ObjectKey != wantKey || current.ScanResult != "Eicar-Test-Signature" {
+		t.Fatalf("գրառում status=%q key=%q scan=%q", current.Status, current.ObjectKey, current.ScanResult)
+	}
+	if fake.has(image.ObjectKey) {
+		t.Fatal("վտանգավոր օբյեկտը մնացել է հանրային նախածանցի տակ")
+	}
+	if !fake.has(wantKey) {
+		t.Fatalf("օբյեկտը չի տեղափոխվել %s", wantKey)
+	}
+	if ids := listedImageIDs(t); len(ids) != 0 {
+		t.Fatalf("մեկուսացված նկարը երևում է ցանկում: %v", ids)
+	}
+}
+
+func TestProcessImageJobActivatesCleanImage(t *testing.T) {
+	fake := setupTest(t)
+	scanner = newClamdStub(t, "stream: OK").scanner()
+	image := scanningImage(t, fake, "img-1")
+
+	if err := processImageJob(context.Background(), &Job{ImageID: image.ID}); err != nil {
+		t.Fatalf("processImageJob: %v", err)
+	}
+
+	var current Image
+	db.First(&current, "id = ?", image.ID)
+	if current.Status != imageStatusActive || current.ObjectKey != image.ObjectKey {
+		t.Fatalf("գրառում status=%q key=%q", current.Status, current.ObjectKey)
+	}
+	if ids := listedImageIDs(t); len(ids) != 1 || ids[0] != image.ID {
+		t.Fatalf("ստուգված նկարը չի երևում ցանկում: %v", ids)
+	}
+}
+
+func TestProcessImageJobKeepsImageHiddenWhenScanFails(t *testing.T) {
+	replies := map[string]string{
+		"error":              "INSTREAM size limit exceeded. ERROR",
+		"dropped connection": "",
+	}
+	for name, reply := range replies {
+		t.Run(name, func(t *testing.T) {
+			fake := setupTest(t)
+			scanner = newClamdStub(t, reply).scanner()
+			image := scanningImage(t, fake, "img-1")
+
+			if err := processImageJob(context.Background(), &Job{ImageID: image.ID}); err == nil {
+				t.Fatal("սկաների սխալը պետք է վերադարձվի կրկնության համար")
+			}
+
+			var current Image
+			db.First(&current, "id = ?", image.ID)
+			if current.Status != imageStatusScanning {
+				t.Fatalf("վիճակ %q, սպասվում էր %q", current.Status, imageStatusScanning)
+			}
+			if !fake.has(image.ObjectKey) {
+				t.Fatal("օբյեկտը ջնջվել է")
+			}
+			if ids := listedImageIDs(t); len(ids) != 0 {
+				t.Fatalf("չստուգված նկարը երևում է ցանկում: %v", ids)
+			}
+		})
+	}
+}
+
+// runProcessImageJobs - Կատարել նկարի բոլոր սպասող մշակման աշխատանքները
+func runProcessImageJobs(t *testing.T, imageID string) {
+	t.Helper()
+	var jobs []Job
+	db.Where("type = ? AND image_id = ? AND status = ?", jobTypeProcessImage, imageID, jobStatusPending).Find(&jobs)
+	if len(jobs) == 0 {
+		t.Fatal("մշակման աշխատանք չկա")
+	}
+	for i := range jobs {
+		if err := processImageJob(context.Background(), &jobs[i]); err != nil {
+			t.Fatalf("processImageJob: %v", err)
+		}
+		db.Model(&jobs[i]).Update("status", jobStatusCompleted)
+	}
+}
+
+func TestImageCreatedEventWaitsForScan(t *testing.T) {
+	tests := []struct {
+		name   string
+		reply  string
+		events []string
+	}{
+		{name: "clean", reply: "stream: OK", events: []string{eventImageCreated}},
+		{name: "infected", reply: "stream: Eicar-Test-Signature FOUND", events: nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := setupTest(t)
+			scanner = newClamdStub(t, test.reply).scanner()
+			events := recordEvents(t)
+			db.Create(&WebhookSubscription{URL: "https://93.184.216.34/hook", Events: "*", Secret: "secret", Active: true})
+
+			image := Image{ID: "img-1", ObjectKey: "uploads/img-1.png", Name: "img-1.png", ContentType: "image/png", UploadedAt: time.Now()}
+			fake.put(image.ObjectKey, testPNG)
+			if err := createImageRecord(context.Background(), &image, []string{"cats"}); err != nil {
+				t.Fatalf("createImageRecord: %v", err)
+			}
+			if image.Status != imageStatusScanning {
+				t.Fatalf("վիճակ %q", image.Status)
+			}
+			var deliveries int64
+			db.Model(&WebhookDelivery{}).Count(&deliveries)
+			if got := events(); len(got) != 0 || deliveries != 0 {
+				t.Fatalf("ստուգումից առաջ հրապարակվել է %v, առաքումներ՝ %d", got, deliveries)
+			}
+
+			runProcessImageJobs(t, image.ID)
+
+			db.Model(&WebhookDelivery{}).Count(&deliveries)
+			if got := events(); !slices.Equal(got, test.events) || deliveries != int64(len(test.events)) {
+				t.Fatalf("իրադարձություններ %v, առաքումներ %d, սպասվում էր %v", got, deliveries, test.events)
+			}
+		})
+	}
+}
+
+func TestReplacedImageScanDoesNotAnnounceCreation(t *testing.T) {
+	fake := setupTest(t)
+	scanner = newClamdStub(t, "stream: OK").scanner()
+	image := insertImage(t, fake, "img-1")
+	events := recordEvents(t)
+
+	if err := enqueueImageProcessing(db, &image); err != nil {
+		t.Fatalf("enqueueImageProcessing: %v", err)
+	}
+	runProcessImageJobs(t, image.ID)
+
+	if got := events(); len(got) != 0 {
+		t.Fatalf("փոխարինված նկարի համար հրապարակվել է %v", got)
+	}
+}
+
+func TestCompleteUploadHidesURLWhileScanning(t *testing.T) {
+	fake := setupTest(t)
+	scanner = newClamdStub(t, "stream: OK").scanner()
+	events := recordEvents(t)
+
+	upload := Upload{
+		ID:          "img-1",
+		ObjectKey:   "uploads/img-1.png",
+		Name:        "cat.png",
+		ContentType: "image/png",
+		Length:      int64(len(testPNG)),
+		Status:      uploadStatusPresigned,
+	}
+	db.Create(&upload)
+	fake.putTyped(upload.ObjectKey, "image/png", testPNG)
+
+	response := serve(http.MethodPost, "/api/uploads/:id/complete", "/api/uploads/img-1/complete", completeUploadHandler, nil, "")
+	if response.Code != http.StatusAccepted {
+		t.Fatalf("կոդ %d, սպասվում էր 202: %s", response.Code, response.Body)
+	}
+	var body map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &body)
+	if _, ok := body["url"]; ok || body["status"] != imageStatusScanning {
+		t.Fatalf("պատասխան %s", response.Body)
+	}
+	if got := events(); len(got) != 0 {
+		t.Fatalf("ստուգումից առաջ հրապարակվել է %v", got)
+	}
+
+	runProcessImageJobs(t, upload.ID)
+	if got := events(); !slices.Equal(got, []string{eventImageCreated}) {
+		t.Fatalf("իրադարձություններ %v", got)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// HTTP սերվերի լռելյայն ժամանակային սահմանները, փոփոխելի միջավայրի փոփոխականներով
+const (
+	defaultReadHeaderTimeout = 10 * time.Second
+	defaultReadTimeout       = 10 * time.Minute
+	defaultWriteTimeout      = 10 * time.Minute
+	defaultIdleTimeout       = 2 * time.Minute
+	defaultMaxHeaderBytes    = 1 << 20
+	defaultShutdownTimeout   = 30 * time.Second
+)
+
+// background - Ֆոնային աշխատանքները (հերթի աշխատողներ, աղբամանի մաքրում, զանգվածային գործողություններ),
+// որոնց ավարտին սպասում է graceful shutdown-ը
+var background sync.WaitGroup
+
+// newHTTPServer - http.Server կարգավորելի ժամանակային սահմաններով և վերնագրերի առավելագույն չափով
+func newHTTPServer(handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              cfg.HTTP.Addr,
+		Handler:           handler,
+		ReadHeaderTimeout: cfg.HTTP.ReadHeaderTimeout,
+		ReadTimeout:       cfg.HTTP.ReadTimeout,
+		WriteTimeout:      cfg.HTTP.WriteTimeout,
+		IdleTimeout:       cfg.HTTP.IdleTimeout,
+		MaxHeaderBytes:    cfg.HTTP.MaxHeaderBytes,
+		ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+	}
+}
+
+// serveUntilShutdown - Սպասարկել հարցումները, մինչև ctx-ը չեղարկվի, այնուհետև SHUTDOWN_TIMEOUT-ի ընթացքում
+// ավարտին հասցնել ընթացիկ վերբեռնումները և ֆոնային աշխատանքները, ապա փակել բազան
+func serveUntilShutdown(ctx context.Context, stop context.CancelFunc, server *http.Server) error {
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe()
+	}()
+	slog.Info("API սերվերը գործարկվել է", "addr", server.Addr)
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+	// Վերականգնել ազդանշանների լռելյայն վարքը, որպեսզի կրկնակի Ctrl+C-ն անմիջապես դադարեցնի պրոցեսը
+	stop()
+
+	timeout := cfg.HTTP.ShutdownTimeout
+	slog.Info("Սերվերը դադարեցվում է", "timeout", timeout.String())
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// SSE հոսքերը երբեք չեն ավարտվում ինքնուրույն, ուստի դրանք փակվում են առանձին
+	server.RegisterOnShutdown(bus.closeSubscribers)
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("Ոչ բոլոր հարցումներն ավարտվեցին ժամանակին", "error", err)
+		server.Close()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		background.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-shutdownCtx.Done():
+		// Կիսատ աշխատանքները կվերցվեն կրկին jobLockTimeout-ից հետո
+		slog.Warn("Ոչ բոլոր ֆոնային աշխատանքներն ավարտվեցին ժամանակին")
+	}
+
+	closeDB()
+	slog.Info("Սերվերը դադարեցված է")
+	return nil
+}
+
+// closeDB - Փակել բազայի կապակցումների pool-ը
+func closeDB() {
+	if !dbAvailable() {
+		return
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return
+	}
+	if err := sqlDB.Close(); err != nil {
+		slog.Error("Սխալ բազայի կապակցումները փակելիս", "error", err)
+	}
+}
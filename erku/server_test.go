@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// freeAddr - Ազատ TCP հասցե loopback-ի վրա
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	return listener.Addr().String()
+}
+
+// waitFor - Սպասել, մինչև պայմանը բավարարվի, կամ ձախողել թեստը
+func waitFor(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("չսպասվեց՝ %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// shutdownServer - serveUntilShutdown-ը գործարկված ֆոնում և դրա դադարեցման միջոցները
+type shutdownServer struct {
+	addr    string
+	cancel  context.CancelFunc
+	done    chan error
+	stopped atomic.Bool
+}
+
+// startShutdownServer - Գործարկել serveUntilShutdown-ը handler-ով և սպասել, մինչև այն ընդունի կապակցումներ
+func startShutdownServer(t *testing.T, handler http.Handler) *shutdownServer {
+	t.Helper()
+	cfg.HTTP.Addr = freeAddr(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	s := &shutdownServer{addr: cfg.HTTP.Addr, cancel: cancel, done: make(chan error, 1)}
+	go func() {
+		s.done <- serveUntilShutdown(ctx, func() { s.stopped.Store(true) }, newHTTPServer(handler))
+	}()
+	waitFor(t, "սերվերի գործարկումը", func() bool {
+		conn, err := net.Dial("tcp", s.addr)
+		if err == nil {
+			conn.Close()
+		}
+		return err == nil
+	})
+	return s
+}
+
+// blockingHandler - /slow հարցումը սպասում է release-ին, entered-ը փակվում է, երբ այն սկսվում է
+func blockingHandler() (http.Handler, chan struct{}, chan struct{}) {
+	entered, release := make(chan struct{}), make(chan struct{})
+	var once sync.Once
+	mux := http.NewServeMux()
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		once.Do(func() { close(entered) })
+		<-release
+		io.WriteString(w, "done")
+	})
+	return mux, entered, release
+}
+
+// getAsync - GET հարցում ֆոնում, արդյունքը՝ պատասխանի մարմինը կամ սխալը
+func getAsync(url string) chan string {
+	result := make(chan string, 1)
+	go func() {
+		response, err := http.Get(url)
+		if err != nil {
+			result <- "error: " + err.Error()
+			return
+		}
+		defer response.Body.Close()
+		body, _ := io.ReadAll(response.Body)
+		result <- string(body)
+	}()
+	return result
+}
+
+func TestServeUntilShutdownDrainsRequestsAndJobs(t *testing.T) {
+	setupTest(t)
+	b := newTestBus(t)
+	subscriber, _, _ := b.subscribe("")
+	cfg.HTTP.ShutdownTimeout = 5 * time.Second
+
+	handler, entered, release := blockingHandler()
+	// Ֆոնային աշխատանք, որն ավարտվում է ընթացիկ հարցումից հետո
+	var jobFinished atomic.Bool
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		<-release
+		time.Sleep(20 * time.Millisecond)
+		jobFinished.Store(true)
+	}()
+
+	server := startShutdownServer(t, handler)
+	response := getAsync("http://" + server.addr + "/slow")
+	<-entered
+	server.cancel()
+
+	// Նոր կապակցումները մերժվում են, SSE հոսքերը փակվում են, բայց ընթացիկ հարցումը դեռ սպասարկվում է
+	waitFor(t, "լսողի փակումը", func() bool {
+		conn, err := net.Dial("tcp", server.addr)
+		if err == nil {
+			conn.Close()
+		}
+		return err != nil
+	})
+	select {
+	case _, ok := <-subscriber:
+		if ok {
+			t.Error("SSE բաժանորդը ստացել է իրադարձություն փակվելու փոխարեն")
+		}
+	case <-time.After(time.Second):
+		t.Error("SSE բաժանորդը չի փակվել")
+	}
+	select {
+	case err := <-server.done:
+		t.Fatalf("serveUntilShutdown-ը վերադարձել է ընթացիկ հարցումից առաջ: %v", err)
+	default:
+	}
+	if !server.stopped.Load() {
+		t.Error("stop-ը չի կանչվել, կրկնակի ազդանշանը չի դադարեցնի պրոցեսը")
+	}
+
+	close(release)
+	if body := <-response; body != "done" {
+		t.Errorf("ընթացիկ հարցում: %s", body)
+	}
+	if err := <-server.done; err != nil {
+		t.Fatalf("serveUntilShutdown: %v", err)
+	}
+	if !jobFinished.Load() {
+		t.Error("serveUntilShutdown-ը չի սպասել ֆոնային աշխատանքին")
+	}
+	sqlDB, _ := db.DB()
+	if err := sqlDB.Ping(); err == nil {
+		t.Error("բազան փակված չէ")
+	}
+}
+
+func TestServeUntilShutdownGivesUpAfterTimeout(t *testing.T) {
+	setupTest(t)
+	newTestBus(t)
+	cfg.HTTP.ShutdownTimeout = 100 * time.Millisecond
+
+	handler, entered, release := blockingHandler()
+	background.Add(1)
+	t.Cleanup(func() {
+		close(release)
+		background.Done()
+	})
+
+	server := startShutdownServer(t, handler)
+	response := getAsync("http://" + server.addr + "/slow")
+	<-entered
+	started := time.Now()
+	server.cancel()
+
+	select {
+	case err := <-server.done:
+		if err != nil {
+			t.Fatalf("serveUntilShutdown: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("serveUntilShutdown-ը չի ավարտվել SHUTDOWN_TIMEOUT-ից հետո")
+	}
+	if elapsed := time.Since(started); elapsed < cfg.HTTP.ShutdownTimeout {
+		t.Errorf("ավարտվել է %s-ում, SHUTDOWN_TIMEOUT-ից շուտ", elapsed)
+	}
+	// Չավարտված կապակցումները փակվում են
+	select {
+	case body := <-response:
+		if body == "done" {
+			t.Error("հարցումը պետք է ընդհատվեր")
+		}
+	case <-time.After(time.Second):
+		t.Error("կապակցումը չի փակվել")
+	}
+}
+
+func TestServeUntilShutdownReportsListenError(t *testing.T) {
+	setupTest(t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	cfg.HTTP.Addr = listener.Addr().String()
+
+	err = serveUntilShutdown(context.Background(), func() {}, newHTTPServer(http.NotFoundHandler()))
+	if err == nil {
+		t.Fatal("զբաղված հասցեն պետք է վերադարձնի սխալ")
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// eventHistorySize - Last-Event-ID-ով վերամիացման համար պահվող վերջին իրադարձությունները
+	eventHistorySize = 1000
+	// eventSubscriberBuffer - դանդաղ հաճախորդը անջատվում է, եթե այսքան իրադարձություն է կուտակվել
+	eventSubscriberBuffer = 64
+	sseHeartbeatInterval  = 25 * time.Second
+	sseRetryMillis        = 3000
+)
+
+// eventStreamReset - ուղարկվում է, երբ պահանջված իրադարձություններն արդեն դուրս են մնացել պատմությունից
+const eventStreamReset = "stream.reset"
+
+// busEvent - Իրադարձությունը հոսքի հերթական համարով
+type busEvent struct {
+	Seq   uint64
+	Event event
+}
+
+// eventBus - Ներքին իրադարձությունների ալիք SSE բաժանորդների համար
+type eventBus struct {
+	mu sync.Mutex
+	// epoch - պրոցեսի գործարկման նշան, որպեսզի վերագործարկումից առաջ ստացված ID-ները չշփոթվեն նորերի հետ
+	epoch       string
+	seq         uint64
+	history     []busEvent
+	subscribers map[chan busEvent]struct{}
+}
+
+var bus = &eventBus{
+	epoch:       strconv.FormatInt(time.Now().UnixNano(), 36),
+	subscribers: make(map[chan busEvent]struct{}),
+}
+
+// publish - Ավելացնել իրադարձությունը պատմությանը և ուղարկել բոլոր բաժանորդներին
+func (b *eventBus) publish(e event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	item := busEvent{Seq: b.seq, Event: e}
+	b.history = append(b.history, item)
+	if len(b.history) > eventHistorySize {
+		b.history = b.history[len(b.history)-eventHistorySize:]
+	}
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- item:
+		default:
+			// Չսպասել դանդաղ հաճախորդին. նա կվերամիանա Last-Event-ID-ով
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// eventID - SSE իրադարձության ID-ն "<epoch>-<seq>" ձևաչափով
+func (b *eventBus) eventID(seq uint64) string {
+	return b.epoch + "-" + strconv.FormatUint(seq, 10)
+}
+
+// subscribe - Բաժանորդագրվել նոր իրադարձություններին: lastID-ից հետո բաց թողնվածները վերադարձվում են
+// missed-ում, իսկ complete-ը false է, եթե դրանց մի մասն արդեն չկա պատմությունում կամ ID-ն անծանոթ է
+func (b *eventBus) subscribe(lastID string) (ch chan busEvent, missed []busEvent, complete bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch = make(chan busEvent, eventSubscriberBuffer)
+	b.subscribers[ch] = struct{}{}
+
+	if lastID == "" {
+		return ch, nil, true
+	}
+	epoch, seqPart, _ := strings.Cut(lastID, "-")
+	lastSeq, err := strconv.ParseUint(seqPart, 10, 64)
+	if err != nil || epoch != b.epoch || lastSeq > b.seq {
+		return ch, nil, false
+	}
+
+	complete = lastSeq == b.seq || b.history[0].Seq <= lastSeq+1
+	for _, item := range b.history {
+		if item.Seq > lastSeq {
+			missed = append(missed, item)
+		}
+	}
+	return ch, missed, complete
+}
+
+// unsubscribe - Հեռացնել բաժանորդին, եթե այն դեռ չի անջատվել publish-ի կողմից
+func (b *eventBus) unsubscribe(ch chan busEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subscribers[ch]; ok {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+}
+
+// closeSubscribers - Փակել բոլոր բաժանորդների ալիքները սերվերի դադարեցման ժամանակ
+func (b *eventBus) closeSubscribers() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+}
+
+// streamEventsHandler - Պատկերասրահի փոփոխությունների ուղիղ հոսք Server-Sent Events-ով
+func streamEventsHandler(c *gin.Context) {
+	// EventSource-ը վերամիանալիս ինքն է ուղարկում Last-Event-ID վերնագիրը, իսկ lastEventId-ն՝ ձեռքով միանալու համար
+	lastID := c.GetHeader("Last-Event-ID")
+	if lastID == "" {
+		lastID = c.Query("lastEventId")
+	}
+
+	types := make(map[string]bool)
+	for _, eventType := range strings.Split(c.Query("types"), ",") {
+		if eventType = strings.TrimSpace(eventType); eventType != "" {
+			types[eventType] = true
+		}
+	}
+
+	ch, missed, complete := bus.subscribe(lastID)
+	defer bus.unsubscribe(ch)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	// Թույլ չտալ nginx-ին բուֆերացնել հոսքը
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	// Հոսքը կարող է բաց մնալ HTTP_WRITE_TIMEOUT-ից երկար
+	_ = http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+
+	w := c.Writer
+	fmt.Fprintf(w, "retry: %d\n\n", sseRetryMillis)
+	if !complete {
+		// Հաճախորդը պետք է նորից բեռնի ցանկը, քանի որ որոշ իրադարձություններ կորել են
+		fmt.Fprintf(w, "event: %s\ndata: {}\n\n", eventStreamReset)
+	}
+	for _, item := range missed {
+		if err := writeServerSentEvent(w, item, types); err != nil {
+			return
+		}
+	}
+	w.Flush()
+
+	heartbeat := time.NewTicker(sseHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case item, ok := <-ch:
+			if !ok {
+				return
+			}
+			if err := writeServerSentEvent(w, item, types); err != nil {
+				return
+			}
+			w.Flush()
+		case <-heartbeat.C:
+			// Մեկնաբանությունը պահում է կապը բաց պրոքսիների միջով
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			w.Flush()
+		}
+	}
+}
+
+// writeServerSentEvent - Գրել իրադարձությունը SSE ձևաչափով, եթե այն համապատասխանում է ֆիլտրին
+func writeServerSentEvent(w gin.ResponseWriter, item busEvent, types map[string]bool) error {
+	if len(types) > 0 && !types[item.Event.Type] {
+		return nil
+	}
+
+	data, err := json.Marshal(item.Event)
+	if err != nil {
+		return nil
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", bus.eventID(item.Seq), item.Event.Type, data)
+	return err
+}
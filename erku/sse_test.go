@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newTestBus - Փոխարինել իրադարձությունների ալիքը հայտնի epoch-ով նորով
+func newTestBus(t *testing.T) *eventBus {
+	t.Helper()
+	previous := bus
+	bus = &eventBus{epoch: "test", subscribers: make(map[chan busEvent]struct{})}
+	t.Cleanup(func() { bus = previous })
+	return bus
+}
+
+// publishEvents - Հրապարակել n իրադարձություն տրված տեսակներով հերթով
+func publishEvents(b *eventBus, n int, types ...string) {
+	for i := 0; i < n; i++ {
+		b.publish(event{ID: strconv.Itoa(i), Type: types[i%len(types)]})
+	}
+}
+
+// missedSeqs - Բաց թողնված իրադարձությունների հերթական համարները
+func missedSeqs(items []busEvent) []uint64 {
+	seqs := make([]uint64, 0, len(items))
+	for _, item := range items {
+		seqs = append(seqs, item.Seq)
+	}
+	return seqs
+}
+
+func TestEventBusResume(t *testing.T) {
+	b := newTestBus(t)
+	publishEvents(b, 3, eventImageCreated)
+
+	tests := []struct {
+		lastID   string
+		missed   int
+		complete bool
+	}{
+		{"", 0, true},
+		{"test-1", 2, true},
+		{"test-3", 0, true},
+		{"test-0", 3, true},
+		// Այլ պրոցեսի կամ ապագայի ID-ն չի կարող վերականգնվել
+		{"previous-1", 0, false},
+		{"test-9", 0, false},
+		{"garbage", 0, false},
+	}
+	for _, tt := range tests {
+		ch, missed, complete := b.subscribe(tt.lastID)
+		b.unsubscribe(ch)
+		if len(missed) != tt.missed || complete != tt.complete {
+			t.Errorf("%q: բաց թողնված %v, ամբողջական %v, սպասվում էր %d և %v", tt.lastID, missedSeqs(missed), complete, tt.missed, tt.complete)
+		}
+	}
+}
+
+func TestEventBusResumeBeyondHistory(t *testing.T) {
+	b := newTestBus(t)
+	publishEvents(b, eventHistorySize+5, eventImageCreated)
+
+	// 1-6 իրադարձությունները դուրս են մնացել պատմությունից
+	ch, missed, complete := b.subscribe("test-1")
+	b.unsubscribe(ch)
+	if complete || len(missed) != eventHistorySize || missed[0].Seq != 6 {
+		t.Errorf("test-1: ամբողջական %v, %d իրադարձություն", complete, len(missed))
+	}
+	ch, missed, complete = b.subscribe("test-5")
+	b.unsubscribe(ch)
+	if !complete || len(missed) != eventHistorySize {
+		t.Errorf("test-5: ամբողջական %v, %d իրադարձություն", complete, len(missed))
+	}
+}
+
+func TestEventBusDropsSlowSubscriber(t *testing.T) {
+	b := newTestBus(t)
+	ch, _, _ := b.subscribe("")
+	publishEvents(b, eventSubscriberBuffer+1, eventImageCreated)
+
+	received := 0
+	for range ch {
+		received++
+	}
+	if received != eventSubscriberBuffer {
+		t.Errorf("ստացվել է %d, սպասվում էր %d, իսկ հետո ալիքը պետք է փակվի", received, eventSubscriberBuffer)
+	}
+	b.unsubscribe(ch)
+}
+
+// streamEvents - GET /api/events արդեն չեղարկված context-ով, որպեսզի հանդլերը վերադառնա բաց թողնվածները գրելուց հետո
+func streamEvents(target, lastEventID string) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.GET("/api/events", streamEventsHandler)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	request := httptest.NewRequest(http.MethodGet, target, nil).WithContext(ctx)
+	if lastEventID != "" {
+		request.Header.Set("Last-Event-ID", lastEventID)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestStreamEventsReplaysAfterLastEventID(t *testing.T) {
+	b := newTestBus(t)
+	publishEvents(b, 4, eventImageCreated, eventImageTagged)
+
+	response := streamEvents("/api/events", "test-1")
+	body := response.Body.String()
+	if response.Header().Get("Content-Type") != "text/event-stream" {
+		t.Errorf("Content-Type %q", response.Header().Get("Content-Type"))
+	}
+	for _, want := range []string{"retry: 3000\n\n", "id: test-2\nevent: image.tagged\n", "id: test-3\nevent: image.created\n", "id: test-4\nevent: image.tagged\n"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("հոսքում բացակայում է %q:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "id: test-1\n") || strings.Contains(body, eventStreamReset) {
+		t.Errorf("հոսքը պետք է սկսվի test-1-ից հետո առանց reset-ի:\n%s", body)
+	}
+
+	// Ձեռքով միացումը lastEventId պարամետրով և տեսակների ֆիլտրով
+	body = streamEvents("/api/events?lastEventId=test-1&types=image.created", "").Body.String()
+	if strings.Count(body, "id: ") != 1 || !strings.Contains(body, "id: test-3\nevent: image.created\n") {
+		t.Errorf("ֆիլտրված հոսք:\n%s", body)
+	}
+}
+
+func TestStreamEventsResetsUnknownLastEventID(t *testing.T) {
+	b := newTestBus(t)
+	publishEvents(b, 2, eventImageCreated)
+
+	body := streamEvents("/api/events", "previous-7").Body.String()
+	if !strings.Contains(body, "event: "+eventStreamReset+"\n") || strings.Contains(body, "id: ") {
+		t.Errorf("սպասվում էր միայն %s:\n%s", eventStreamReset, body)
+	}
+}
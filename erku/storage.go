@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// Bucket-ի հասցեավորման եղանակները storage.addressing_style-ի համար
+const (
+	// addressingStyleAuto - path-style, եթե endpoint-ը տրված է (MinIO), հակառակ դեպքում virtual-hosted (AWS S3)
+	addressingStyleAuto    = "auto"
+	addressingStylePath    = "path"
+	addressingStyleVirtual = "virtual"
+)
+
+// defaultS3Region - Ռեգիոնը custom endpoint-ի համար, եթե այն տրված չէ ոչ կարգավորումներում, ոչ AWS պրոֆիլում.
+// MinIO-ն լռելյայն աշխատում է հենց այս ռեգիոնով
+const defaultS3Region = "us-east-1"
+
+// newS3Client - S3 հաճախորդ AWS SDK-ի լռելյայն կարգավորումներով (միջավայրի փոփոխականներ, shared config/credentials
+// ֆայլեր, web identity, ECS/EC2 IMDS): storage բաժնի ռեգիոնը և բանալիները, եթե տրված են, գերակայում են դրանց
+func newS3Client(ctx context.Context) (*s3.Client, error) {
+	options := []func(*config.LoadOptions) error{
+		config.WithRetryMaxAttempts(3),
+	}
+	if cfg.Storage.Region != "" {
+		options = append(options, config.WithRegion(cfg.Storage.Region))
+	}
+	if cfg.Storage.AccessKey != "" {
+		options = append(options, config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(
+			cfg.Storage.AccessKey, cfg.Storage.SecretKey, cfg.Storage.SessionToken,
+		)))
+	}
+
+	awsConfig, err := config.LoadDefaultConfig(ctx, options...)
+	if err != nil {
+		return nil, fmt.Errorf("AWS կարգավորումները չեն բեռնվում: %w", err)
+	}
+	if awsConfig.Region == "" {
+		if cfg.Storage.Endpoint == "" {
+			return nil, errors.New("AWS S3-ի համար ռեգիոնը պարտադիր է: կարգավորեք storage.region կամ AWS_REGION")
+		}
+		awsConfig.Region = defaultS3Region
+	}
+
+	// MinIO-ն virtual-hosted հասցեները հասկանում է միայն MINIO_DOMAIN-ի դեպքում, ուստի լռելյայն օգտագործվում է path-style
+	usePathStyle := cfg.Storage.AddressingStyle == addressingStylePath ||
+		(cfg.Storage.AddressingStyle == addressingStyleAuto && cfg.Storage.Endpoint != "")
+
+	return s3.NewFromConfig(awsConfig, func(o *s3.Options) {
+		if cfg.Storage.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Storage.Endpoint)
+		}
+		o.UsePathStyle = usePathStyle
+	}, withS3Tracing, withS3Metrics), nil
+}
+
+// createBucket - Ստեղծել bucket-ը հաճախորդի ռեգիոնում
+func createBucket(ctx context.Context, bucketName string) error {
+	input := &s3.CreateBucketInput{Bucket: aws.String(bucketName)}
+	// us-east-1-ից դուրս AWS S3-ը պահանջում է ռեգիոնը բացահայտ նշել
+	if region := s3Client.Options().Region; region != defaultS3Region {
+		input.CreateBucketConfiguration = &types.CreateBucketConfiguration{
+			LocationConstraint: types.BucketLocationConstraint(region),
+		}
+	}
+	_, err := s3Client.CreateBucket(ctx, input)
+	return err
+}
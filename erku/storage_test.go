@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// isolateAWSEnv - Անջատել մեքենայի AWS կարգավորումները. shared ֆայլերը՝ տրված բովանդակությամբ, IMDS-ը՝ անջատված
+func isolateAWSEnv(t *testing.T, sharedConfig, sharedCredentials string) {
+	t.Helper()
+	for _, key := range []string{
+		"AWS_REGION", "AWS_DEFAULT_REGION", "AWS_PROFILE", "AWS_DEFAULT_PROFILE",
+		"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN",
+		"AWS_WEB_IDENTITY_TOKEN_FILE", "AWS_ROLE_ARN", "AWS_CONTAINER_CREDENTIALS_RELATIVE_URI",
+		"AWS_CONTAINER_CREDENTIALS_FULL_URI", "AWS_ENDPOINT_URL", "AWS_ENDPOINT_URL_S3",
+	} {
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+
+	dir := t.TempDir()
+	for env, content := range map[string]string{"AWS_CONFIG_FILE": sharedConfig, "AWS_SHARED_CREDENTIALS_FILE": sharedCredentials} {
+		path := filepath.Join(dir, env)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv(env, path)
+	}
+}
+
+func TestNewS3Client(t *testing.T) {
+	const sharedConfig = "[profile prod]\nregion = eu-west-1\n"
+	const sharedCredentials = "[prod]\naws_access_key_id = profile-key\naws_secret_access_key = profile-secret\n"
+
+	tests := []struct {
+		name     string
+		env      map[string]string
+		storage  StorageConfig
+		err      bool
+		region   string
+		endpoint string
+		path     bool
+		key      string
+	}{
+		{
+			name:     "MinIO լռելյայններով",
+			storage:  StorageConfig{Endpoint: "http://minio:9000", AccessKey: "minio", SecretKey: "secret"},
+			region:   defaultS3Region,
+			endpoint: "http://minio:9000",
+			path:     true,
+			key:      "minio",
+		},
+		{
+			name:    "AWS S3 առանց ռեգիոնի",
+			storage: StorageConfig{AccessKey: "key", SecretKey: "secret"},
+			err:     true,
+		},
+		{
+			name:    "AWS պրոֆիլ",
+			env:     map[string]string{"AWS_PROFILE": "prod"},
+			storage: StorageConfig{},
+			region:  "eu-west-1",
+			key:     "profile-key",
+		},
+		{
+			name:    "միջավայրի credential-ներ",
+			env:     map[string]string{"AWS_REGION": "ap-south-1", "AWS_ACCESS_KEY_ID": "env-key", "AWS_SECRET_ACCESS_KEY": "env-secret"},
+			storage: StorageConfig{},
+			region:  "ap-south-1",
+			key:     "env-key",
+		},
+		{
+			// storage բաժինը գերակայում է պրոֆիլի նկատմամբ
+			name:    "կարգավորումները պրոֆիլի փոխարեն",
+			env:     map[string]string{"AWS_PROFILE": "prod"},
+			storage: StorageConfig{Region: "us-west-2", AccessKey: "config-key", SecretKey: "config-secret"},
+			region:  "us-west-2",
+			key:     "config-key",
+		},
+		{
+			name:     "MinIO MINIO_DOMAIN-ով",
+			storage:  StorageConfig{Endpoint: "http://minio:9000", AddressingStyle: addressingStyleVirtual, AccessKey: "minio", SecretKey: "secret"},
+			region:   defaultS3Region,
+			endpoint: "http://minio:9000",
+			key:      "minio",
+		},
+		{
+			name:    "AWS S3 path-style",
+			storage: StorageConfig{Region: "eu-central-1", AddressingStyle: addressingStylePath, AccessKey: "key", SecretKey: "secret"},
+			region:  "eu-central-1",
+			path:    true,
+			key:     "key",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isolateAWSEnv(t, sharedConfig, sharedCredentials)
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			setupTest(t)
+			if tt.storage.AddressingStyle == "" {
+				tt.storage.AddressingStyle = addressingStyleAuto
+			}
+			cfg.Storage = tt.storage
+
+			client, err := newS3Client(context.Background())
+			if tt.err {
+				if err == nil {
+					t.Fatal("սպասվում էր սխալ")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			options := client.Options()
+			endpoint := ""
+			if options.BaseEndpoint != nil {
+				endpoint = *options.BaseEndpoint
+			}
+			if options.Region != tt.region || endpoint != tt.endpoint || options.UsePathStyle != tt.path {
+				t.Errorf("ռեգիոն %q, endpoint %q, path-style %v, սպասվում էր %q, %q, %v", options.Region, endpoint, options.UsePathStyle, tt.region, tt.endpoint, tt.path)
+			}
+			credentials, err := options.Credentials.Retrieve(context.Background())
+			if err != nil || credentials.AccessKeyID != tt.key {
+				t.Errorf("access key %q (%v), սպասվում էր %q", credentials.AccessKeyID, err, tt.key)
+			}
+		})
+	}
+}
+
+func TestCreateBucketLocationConstraint(t *testing.T) {
+	for region, constraint := range map[string]bool{defaultS3Region: false, "eu-west-1": true} {
+		t.Run(region, func(t *testing.T) {
+			isolateAWSEnv(t, "", "")
+			var path, body string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				data, _ := io.ReadAll(r.Body)
+				path, body = r.URL.Path, string(data)
+			}))
+			defer server.Close()
+
+			setupTest(t)
+			cfg.Storage = StorageConfig{Endpoint: server.URL, Region: region, AddressingStyle: addressingStyleAuto, AccessKey: "key", SecretKey: "secret"}
+			client, err := newS3Client(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			s3Client = client
+
+			if err := createBucket(context.Background(), "gallery"); err != nil {
+				t.Fatal(err)
+			}
+			if path != "/gallery" {
+				t.Errorf("ուղի %q, սպասվում էր path-style /gallery", path)
+			}
+			if got := strings.Contains(body, "<LocationConstraint>"+region+"</LocationConstraint>"); got != constraint {
+				t.Errorf("LocationConstraint %v, սպասվում էր %v: %q", got, constraint, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go/middleware"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/aws/aws-sdk-go-v2/otelaws"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/embedded"
+	"gorm.io/gorm"
+	"gorm.io/plugin/opentelemetry/tracing"
+)
+
+// tracerName - erku-ի սեփական span-երի (օր.՝ հերթի աշխատանքների) instrumentation անունը
+const tracerName = "github.com/VaheMuradyan/aws/erku"
+
+var tracer = otel.Tracer(tracerName)
+
+// initTracing - Կարգավորել OpenTelemetry-ն OTEL_TRACES_EXPORTER փոփոխականից.
+// "otlp" - OTLP/HTTP (OTEL_EXPORTER_OTLP_* փոփոխականներով), "console" - stdout, "none" - անջատված.
+// Լռելյայն՝ otlp, եթե OTEL_EXPORTER_OTLP_ENDPOINT-ը տրված է, հակառակ դեպքում none.
+// W3C traceparent-ը տարածվում է նույնիսկ անջատված արտահանման դեպքում
+func initTracing(ctx context.Context) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	defaultExporter := "none"
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != "" {
+		defaultExporter = "otlp"
+	}
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	name := strings.ToLower(os.Getenv("OTEL_TRACES_EXPORTER"))
+	if name == "" {
+		name = defaultExporter
+	}
+	switch name {
+	case "none":
+		return func(context.Context) error { return nil }, nil
+	case "otlp":
+		exporter, err = otlptracehttp.New(ctx)
+	case "console", "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	default:
+		return nil, fmt.Errorf("անհայտ OTEL_TRACES_EXPORTER %q (otlp, console կամ none)", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// OTEL_SERVICE_NAME և OTEL_RESOURCE_ATTRIBUTES փոփոխականները գերակայում են լռելյայն անվան նկատմամբ
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", "erku")),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sampler-ը կարդացվում է OTEL_TRACES_SAMPLER փոփոխականից
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	slog.Info("OpenTelemetry tracing-ը միացված է", "exporter", name)
+	return provider.Shutdown, nil
+}
+
+// withS3Tracing - S3 գործողությունների span-եր և traceparent վերնագրի փոխանցում
+func withS3Tracing(o *s3.Options) {
+	var tracing []func(*middleware.Stack) error
+	// Ինչպես GORM-ի դեպքում, span-եր միայն HTTP հարցման կամ աշխատանքի ներսում (օր.՝ ոչ readiness ստուգումների համար)
+	otelaws.AppendMiddlewares(&tracing, otelaws.WithTracerProvider(childSpanTracerProvider{}))
+
+	o.APIOptions = append(o.APIOptions, func(stack *middleware.Stack) error {
+		// Ստորագրված URL-ի ստեղծումը հարցում չէ և span չի պահանջում
+		if isPresignStack(stack) {
+			return nil
+		}
+		for _, add := range tracing {
+			if err := add(stack); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// registerDBTracing - GORM հարցումների span-եր առանց պարամետրերի արժեքների
+func registerDBTracing(db *gorm.DB) error {
+	return db.Use(tracing.NewPlugin(
+		tracing.WithTracerProvider(childSpanTracerProvider{}),
+		tracing.WithoutQueryVariables(),
+		tracing.WithoutMetrics(),
+	))
+}
+
+// childSpanTracerProvider - Ստեղծել span-եր միայն գոյություն ունեցող trace-ի ներսում (HTTP հարցում կամ աշխատանք),
+// որպեսզի հերթի պարբերական հարցումները չստեղծեն հազարավոր առանձին trace-եր
+type childSpanTracerProvider struct {
+	embedded.TracerProvider
+}
+
+func (childSpanTracerProvider) Tracer(name string, opts ...trace.TracerOption) trace.Tracer {
+	return childSpanTracer{tracer: otel.GetTracerProvider().Tracer(name, opts...)}
+}
+
+type childSpanTracer struct {
+	embedded.Tracer
+	tracer trace.Tracer
+}
+
+func (t childSpanTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx, trace.SpanFromContext(ctx)
+	}
+	return t.tracer.Start(ctx, name, opts...)
+}
+
+// startJobSpan - Ստեղծել span հերթի աշխատանքի համար
+func startJobSpan(ctx context.Context, job *Job) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "job "+job.Type,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.Int64("erku.job.id", int64(job.ID)),
+			attribute.String("erku.job.type", job.Type),
+			attribute.Int("erku.job.attempt", job.Attempts),
+			attribute.String("erku.image.id", job.ImageID),
+		))
+}
+
+// endSpan - Ավարտել span-ը՝ գրանցելով սխալը, եթե այն կա
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
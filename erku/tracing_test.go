@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recordSpans - Գրանցել ավարտված span-երը թեստի ընթացքում
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previousProvider, previousPropagator, previousTracer := otel.GetTracerProvider(), otel.GetTextMapPropagator(), tracer
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	// Գլոբալ tracer-ը կապվում է միայն առաջին provider-ին, ուստի փոխարինվում է ուղղակիորեն
+	tracer = provider.Tracer(tracerName)
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+		tracer = previousTracer
+	})
+	return recorder
+}
+
+func TestRequestSpansCoverS3AndDB(t *testing.T) {
+	fake := setupTest(t)
+	insertImage(t, fake, "img-1")
+	recorder := recordSpans(t)
+	client := s3.New(s3Client.Options(), withS3Tracing)
+	if err := registerDBTracing(db); err != nil {
+		t.Fatal(err)
+	}
+
+	router := gin.New()
+	router.Use(otelgin.Middleware("erku"))
+	router.GET("/api/images/:id", func(c *gin.Context) {
+		ctx := c.Request.Context()
+		var image Image
+		db.WithContext(ctx).First(&image, "id = ?", c.Param("id"))
+		client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String("test"), Key: aws.String(image.ObjectKey)})
+		// Ստորագրված URL-ը հարցում չէ և span չի ստեղծում
+		s3.NewPresignClient(client).PresignGetObject(ctx, &s3.GetObjectInput{Bucket: aws.String("test"), Key: aws.String(image.ObjectKey)})
+		c.Status(http.StatusOK)
+	})
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	request := httptest.NewRequest(http.MethodGet, "/api/images/img-1", nil)
+	request.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	router.ServeHTTP(httptest.NewRecorder(), request)
+
+	var server sdktrace.ReadOnlySpan
+	kinds := make(map[trace.SpanKind]int)
+	for _, span := range recorder.Ended() {
+		if span.SpanContext().TraceID().String() != traceID {
+			t.Errorf("%s: trace %s, սպասվում էր traceparent-ի %s", span.Name(), span.SpanContext().TraceID(), traceID)
+		}
+		kinds[span.SpanKind()]++
+		if span.SpanKind() == trace.SpanKindServer {
+			server = span
+		}
+	}
+	if server == nil || kinds[trace.SpanKindServer] != 1 || kinds[trace.SpanKindClient] != 2 {
+		t.Fatalf("span-եր ըստ տեսակի %v, սպասվում էր 1 server (HTTP) և 2 client (GORM, S3)", kinds)
+	}
+	for _, span := range recorder.Ended() {
+		if span.SpanKind() == trace.SpanKindClient && span.Parent().SpanID() != server.SpanContext().SpanID() {
+			t.Errorf("%s-ը HTTP span-ի զավակը չէ", span.Name())
+		}
+	}
+}
+
+func TestNoSpansOutsideRequestsAndJobs(t *testing.T) {
+	fake := setupTest(t)
+	insertImage(t, fake, "img-1")
+	recorder := recordSpans(t)
+	client := s3.New(s3Client.Options(), withS3Tracing)
+	if err := registerDBTracing(db); err != nil {
+		t.Fatal(err)
+	}
+
+	// Օր.՝ հերթի պարբերական հարցումը կամ readiness ստուգումը
+	ctx := context.Background()
+	var image Image
+	db.WithContext(ctx).First(&image, "id = ?", "img-1")
+	client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String("test"), Key: aws.String(image.ObjectKey)})
+
+	if spans := recorder.Ended(); len(spans) != 0 {
+		t.Errorf("%d span ստեղծվել է առանց ծնող trace-ի", len(spans))
+	}
+}
+
+func TestJobSpanRecordsFailure(t *testing.T) {
+	fake := setupTest(t)
+	insertImage(t, fake, "img-1")
+	recorder := recordSpans(t)
+	if err := registerDBTracing(db); err != nil {
+		t.Fatal(err)
+	}
+	job := &Job{ID: 7, Type: "process_image", ImageID: "img-1", Attempts: 2}
+
+	ctx, span := startJobSpan(context.Background(), job)
+	var image Image
+	db.WithContext(ctx).First(&image, "id = ?", job.ImageID)
+	endSpan(span, errors.New("boom"))
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("%d span, սպասվում էր աշխատանքի և GORM-ի", len(spans))
+	}
+	query, jobSpan := spans[0], spans[1]
+	if jobSpan.Name() != "job process_image" || jobSpan.SpanKind() != trace.SpanKindConsumer {
+		t.Errorf("span %s (%s)", jobSpan.Name(), jobSpan.SpanKind())
+	}
+	if query.Parent().SpanID() != jobSpan.SpanContext().SpanID() {
+		t.Errorf("%s-ը աշխատանքի span-ի զավակը չէ", query.Name())
+	}
+	attributes := make(map[attribute.Key]attribute.Value)
+	for _, kv := range jobSpan.Attributes() {
+		attributes[kv.Key] = kv.Value
+	}
+	if attributes["erku.job.id"].AsInt64() != 7 || attributes["erku.job.attempt"].AsInt64() != 2 || attributes["erku.image.id"].AsString() != "img-1" {
+		t.Errorf("ատրիբուտներ %v", jobSpan.Attributes())
+	}
+	if jobSpan.Status().Code != codes.Error || jobSpan.Status().Description != "boom" || len(jobSpan.Events()) != 1 {
+		t.Errorf("սխալը չի գրանցվել: %+v, %d իրադարձություն", jobSpan.Status(), len(jobSpan.Events()))
+	}
+}
+
+func TestInitTracingExporterSelection(t *testing.T) {
+	for _, key := range []string{"OTEL_EXPORTER_OTLP_ENDPOINT", "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"} {
+		t.Setenv(key, "")
+	}
+	previousPropagator := otel.GetTextMapPropagator()
+	t.Cleanup(func() { otel.SetTextMapPropagator(previousPropagator) })
+
+	for exporter, ok := range map[string]bool{"": true, "none": true, "zipkin": false} {
+		t.Setenv("OTEL_TRACES_EXPORTER", exporter)
+		shutdown, err := initTracing(context.Background())
+		if (err == nil) != ok {
+			t.Errorf("%q: սխալ %v", exporter, err)
+			continue
+		}
+		if ok {
+			shutdown(context.Background())
+		}
+	}
+}
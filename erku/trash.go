@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	// defaultTrashRetention - որքան ժամանակ է ջնջված նկարը մնում աղբամանում
+	defaultTrashRetention = 30 * 24 * time.Hour
+	// defaultTrashPurgeInterval - աղբամանի մաքրման հաճախականությունը
+	defaultTrashPurgeInterval = 1 * time.Hour
+	// trashPurgeBatchSize - մեկ անցումում ընդմիշտ ջնջվող նկարների քանակը
+	trashPurgeBatchSize = 100
+)
+
+// trashRetention - Աղբամանի պահպանման ժամկետը TRASH_RETENTION փոփոխականից
+func trashRetention() time.Duration {
+	return cfg.Trash.Retention
+}
+
+// getTrashHandler - Աղբամանում գտնվող նկարների ցանկի ստացում
+func getTrashHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	if !dbAvailable() {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Տվյալների բազան հասանելի չէ"})
+		return
+	}
+
+	var images []Image
+	err := db.WithContext(ctx).Unscoped().Scopes(activeImages).
+		Where("deleted_at IS NOT NULL").
+		Order("deleted_at DESC").
+		Find(&images).Error
+	if err != nil {
+		slog.ErrorContext(ctx, "Սխալ աղբամանի ցանկը ստանալիս", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Չհաջողվեց ստանալ աղբամանի ցանկը"})
+		return
+	}
+
+	retention := trashRetention()
+	result := []gin.H{}
+	for _, image := range images {
+		response, err := createImageResponse(ctx, image)
+		if err != nil {
+			continue
+		}
+		result = append(result, gin.H{
+			"image":   response,
+			"purgeAt": image.DeletedAt.Time.Add(retention),
+		})
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// restoreImageHandler - Նկարի վերականգնում աղբամանից
+func restoreImageHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	id := c.Param("id")
+
+	if !dbAvailable() {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Տվյալների բազան հասանելի չէ"})
+		return
+	}
+
+	var image Image
+	err := db.WithContext(ctx).Unscoped().Scopes(activeImages).
+		Where("deleted_at IS NOT NULL").
+		First(&image, "id = ?", id).Error
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Նկարը աղբամանում չի գտնվել"})
+		return
+	}
+
+	if err := db.WithContext(ctx).Unscoped().Model(&image).Update("deleted_at", nil).Error; err != nil {
+		slog.ErrorContext(ctx, "Սխալ նկարը վերականգնելիս", "image_id", id, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Չհաջողվեց վերականգնել նկարը"})
+		return
+	}
+
+	image.DeletedAt = gorm.DeletedAt{}
+	// Ջնջումը հրապարակվել էր image.deleted-ով, բաժանորդները պետք է իմանան, որ նկարը վերադարձել է
+	tags, err := imageTagNames(db.WithContext(ctx), image.ID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Սխալ նկարի թեգերը ստանալիս", "image_id", id, "error", err)
+	}
+	emitEvent(ctx, eventImageRestored, newImageEventData(image, tags))
+
+	response, err := createImageResponse(ctx, image)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Չհաջողվեց ստեղծել նկարի URL"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// startTrashPurger - Գործարկել ֆոնային մաքրիչը, որը ընդմիշտ ջնջում է ժամկետանց նկարները
+func startTrashPurger(ctx context.Context) {
+	interval := cfg.Trash.PurgeInterval
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			// Մինչև բազային կապակցվելը մաքրումը բաց է թողնվում
+			if dbAvailable() {
+				purgeTrash(context.Background())
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// purgeTrash - Ընդմիշտ ջնջել պահպանման ժամկետն անցած և կիսատ ջնջված նկարները
+func purgeTrash(ctx context.Context) {
+	cutoff := time.Now().Add(-trashRetention())
+
+	for {
+		var images []Image
+		err := db.WithContext(ctx).Unscoped().
+			Where("(deleted_at IS NOT NULL AND deleted_at < ?) OR status = ?", cutoff, imageStatusDeleting).
+			Limit(trashPurgeBatchSize).
+			Find(&images).Error
+		if err != nil {
+			slog.ErrorContext(ctx, "Սխալ աղբամանը մաքրելիս", "error", err)
+			return
+		}
+		if len(images) == 0 {
+			return
+		}
+
+		failed := purgeImages(ctx, images)
+		for id, err := range failed {
+			slog.ErrorContext(ctx, "Սխալ նկարը ընդմիշտ ջնջելիս", "image_id", id, "error", err)
+		}
+		purged := len(images) - len(failed)
+		slog.InfoContext(ctx, "Աղբամանը մաքրվեց", "purged", purged)
+
+		// Եթե ոչինչ չհաջողվեց ջնջել, չկրկնել նույն խմբաքանակը անվերջ
+		if purged == 0 || len(images) < trashPurgeBatchSize {
+			return
+		}
+	}
+}
+
+// deletedAt - Վերադարձնել ջնջման ժամանակը API-ի համար, եթե նկարը աղբամանում է
+func deletedAt(image Image) *time.Time {
+	if !image.DeletedAt.Valid {
+		return nil
+	}
+	return &image.DeletedAt.Time
+}
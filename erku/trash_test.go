@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+)
+
+// trashedImageIDs - GET /api/trash-ի նկարների ID-ները
+func trashedImageIDs(t *testing.T) []string {
+	t.Helper()
+	response := serve(http.MethodGet, "/api/trash", "/api/trash", getTrashHandler, nil, "")
+	if response.Code != http.StatusOK {
+		t.Fatalf("աղբաման: կոդ %d", response.Code)
+	}
+	var items []struct {
+		Image   ImageResponse `json:"image"`
+		PurgeAt time.Time     `json:"purgeAt"`
+	}
+	json.Unmarshal(response.Body.Bytes(), &items)
+	ids := make([]string, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.Image.ID)
+	}
+	return ids
+}
+
+func TestDeleteMovesImageToTrashAndRestoreBringsItBack(t *testing.T) {
+	fake := setupTest(t)
+	image := insertImage(t, fake, "img-1")
+	attachTags(db, image.ID, []string{"cat"})
+	events := recordEventLog(t)
+
+	response := serve(http.MethodDelete, "/api/images/:id", "/api/images/img-1", deleteImageHandler, nil, "")
+	if response.Code != http.StatusOK {
+		t.Fatalf("ջնջում: կոդ %d: %s", response.Code, response.Body)
+	}
+	if !fake.has(image.ObjectKey) {
+		t.Fatal("օբյեկտը ջնջվել է մինչև պահպանման ժամկետի ավարտը")
+	}
+	if code, ids := listImageIDs(t, ""); code != http.StatusOK || len(ids) != 0 {
+		t.Fatalf("ջնջված նկարը երևում է ցանկում: %v", ids)
+	}
+	if ids := trashedImageIDs(t); !slices.Equal(ids, []string{image.ID}) {
+		t.Fatalf("աղբաման %v", ids)
+	}
+
+	response = serve(http.MethodPost, "/api/images/:id/restore", "/api/images/img-1/restore", restoreImageHandler, nil, "")
+	if response.Code != http.StatusOK {
+		t.Fatalf("վերականգնում: կոդ %d: %s", response.Code, response.Body)
+	}
+	if _, ids := listImageIDs(t, ""); !slices.Equal(ids, []string{image.ID}) {
+		t.Fatalf("վերականգնված նկարը չի երևում ցանկում: %v", ids)
+	}
+	if ids := trashedImageIDs(t); len(ids) != 0 {
+		t.Fatalf("վերականգնված նկարը մնացել է աղբամանում: %v", ids)
+	}
+
+	got := events()
+	if len(got) != 2 || got[0].Type != eventImageDeleted || got[1].Type != eventImageRestored {
+		t.Fatalf("իրադարձություններ %+v", got)
+	}
+	if data := got[1].Data.(imageEventData); data.ImageID != image.ID || !slices.Equal(data.Tags, []string{"cat"}) {
+		t.Fatalf("վերականգնման տվյալներ %+v", data)
+	}
+
+	// Աղբամանում չգտնվող նկարը չի վերականգնվում
+	response = serve(http.MethodPost, "/api/images/:id/restore", "/api/images/img-1/restore", restoreImageHandler, nil, "")
+	if response.Code != http.StatusNotFound {
+		t.Fatalf("կրկնակի վերականգնում: կոդ %d, սպասվում էր 404", response.Code)
+	}
+}
+
+func TestPurgeTrashDeletesOnlyExpiredImages(t *testing.T) {
+	fake := setupTest(t)
+	expired := insertImage(t, fake, "expired")
+	recent := insertImage(t, fake, "recent")
+	db.Delete(&expired)
+	db.Delete(&recent)
+	db.Unscoped().Model(&expired).Update("deleted_at", time.Now().Add(-trashRetention()-time.Hour))
+
+	purgeTrash(context.Background())
+
+	if fake.has(expired.ObjectKey) {
+		t.Fatal("ժամկետանց նկարի օբյեկտը չի ջնջվել")
+	}
+	var count int64
+	db.Unscoped().Model(&Image{}).Where("id = ?", expired.ID).Count(&count)
+	if count != 0 {
+		t.Fatal("ժամկետանց նկարի գրառումը չի ջնջվել")
+	}
+	if !fake.has(recent.ObjectKey) {
+		t.Fatal("պահպանման ժամկետում գտնվող նկարի օբյեկտը ջնջվել է")
+	}
+	if ids := trashedImageIDs(t); !slices.Equal(ids, []string{recent.ID}) {
+		t.Fatalf("աղբաման %v", ids)
+	}
+}
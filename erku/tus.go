@@ -0,0 +1,683 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	tusVersion    = "1.0.0"
+	tusExtensions = "creation,termination,expiration"
+	// tusMaxSize - մեկ վերբեռնման առավելագույն չափը
+	tusMaxSize = 10 << 30
+	// tusPartSize - S3 multipart մասի չափը (S3-ը պահանջում է առնվազն 5 MiB, բացի վերջինից)
+	tusPartSize = 8 << 20
+	// tusPendingPrefix - 5 MiB-ից փոքր չավարտված մասերի պահման նախածանցը bucket-ում
+	tusPendingPrefix = "tus-pending/"
+	// defaultUploadExpiry - անգործուն tus վերբեռնումը ջնջվում է այսքան ժամանակ անց
+	defaultUploadExpiry = 24 * time.Hour
+	// defaultUploadSweepInterval - լքված վերբեռնումների մաքրման հաճախականությունը
+	defaultUploadSweepInterval = 15 * time.Minute
+	// uploadSweepBatchSize - մեկ անցումում մաքրվող վերբեռնումների առավելագույն քանակը
+	uploadSweepBatchSize = 100
+)
+
+const (
+	uploadStatusInProgress = "in_progress"
+	uploadStatusPresigned  = "presigned"
+	uploadStatusCompleted  = "completed"
+	uploadStatusFailed     = "failed"
+	// uploadStatusExpired - ժամկետանց վերբեռնում, որի S3 մնացորդները դեռ մաքրվում են
+	uploadStatusExpired = "expired"
+)
+
+// errUploadExpired - վերբեռնումը նշվել է որպես ժամկետանց, մինչ այն ավարտվում էր
+var errUploadExpired = errors.New("վերբեռնման ժամկետն անցել է")
+
+// pendingUploadStatuses - Վերբեռնումներ, որոնց օբյեկտը կարող է արդեն լինել bucket-ում, բայց նկարը դեռ գրանցված չէ
+var pendingUploadStatuses = []string{uploadStatusInProgress, uploadStatusPresigned, uploadStatusExpired}
+
+// Upload - Ընդհատվող (tus) կամ ուղիղ bucket վերբեռնման վիճակը, որը պահպանվում է սերվերի վերագործարկումներից հետո
+type Upload struct {
+	ID          string    `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	ObjectKey   string    `json:"objectKey" gorm:"type:varchar(255);not null"`
+	S3UploadID  string    `json:"-" gorm:"type:varchar(1024);not null"`
+	Name        string    `json:"name" gorm:"type:varchar(255);not null"`
+	ContentType string    `json:"contentType" gorm:"type:varchar(100);not null"`
+	Tags        string    `json:"tags" gorm:"type:varchar(1024)"`
+	Length      int64     `json:"length" gorm:"type:bigint;not null"`
+	Offset      int64     `json:"offset" gorm:"type:bigint;not null"`
+	PendingSize int64     `json:"-" gorm:"type:bigint;not null"`
+	Status      string    `json:"status" gorm:"type:varchar(20);not null"`
+	CreatedAt   time.Time `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+}
+
+// UploadPart - S3 multipart վերբեռնման արդեն ուղարկված մասը
+type UploadPart struct {
+	UploadID   string `json:"uploadId" gorm:"type:varchar(36);primaryKey"`
+	PartNumber int32  `json:"partNumber" gorm:"primaryKey"`
+	ETag       string `json:"etag" gorm:"type:varchar(255);not null"`
+	Size       int64  `json:"size" gorm:"type:bigint;not null"`
+}
+
+// pendingKey - Չավարտված մասի օբյեկտի բանալին
+func (u *Upload) pendingKey() string {
+	return tusPendingPrefix + u.ID
+}
+
+// uploadLocks - Միաժամանակյա PATCH հարցումներից պաշտպանություն նույն վերբեռնման համար
+var uploadLocks sync.Map
+
+// lockUpload - Փորձել կողպել վերբեռնումը, վերադարձնել բացման ֆունկցիան
+func lockUpload(id string) (func(), bool) {
+	value, _ := uploadLocks.LoadOrStore(id, &sync.Mutex{})
+	mu := value.(*sync.Mutex)
+	if !mu.TryLock() {
+		return nil, false
+	}
+	return mu.Unlock, true
+}
+
+// uploadExpiresAt - tus expiration. Ժամկետը հաշվվում է վերջին ստացված հատվածից
+func uploadExpiresAt(lastActivity time.Time) time.Time {
+	return lastActivity.Add(cfg.Uploads.Expiry)
+}
+
+// setUploadExpires - Upload-Expires վերնագիրը չավարտված վերբեռնման համար
+func setUploadExpires(c *gin.Context, lastActivity time.Time) {
+	c.Header("Upload-Expires", uploadExpiresAt(lastActivity).UTC().Format(http.TimeFormat))
+}
+
+// uploadExpired - Վերբեռնումը ժամկետանց է, բայց մաքրիչը դեռ չի հասցրել ջնջել այն
+func uploadExpired(upload *Upload) bool {
+	return upload.Status == uploadStatusExpired ||
+		(upload.Status == uploadStatusInProgress && time.Now().After(uploadExpiresAt(upload.UpdatedAt)))
+}
+
+// tusHeaders - Յուրաքանչյուր tus պատասխանի պարտադիր վերնագրերը
+func tusHeaders(c *gin.Context) {
+	c.Header("Tus-Resumable", tusVersion)
+	c.Header("Cache-Control", "no-store")
+}
+
+// requireTusResumable - Մերժել հարցումները, որոնք չեն աջակցում tus 1.0.0
+func requireTusResumable() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tusHeaders(c)
+		if c.Request.Method != http.MethodOptions && c.GetHeader("Tus-Resumable") != tusVersion {
+			c.Header("Tus-Version", tusVersion)
+			c.AbortWithStatus(http.StatusPreconditionFailed)
+			return
+		}
+		if !dbAvailable() {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Տվյալների բազան հասանելի չէ"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// tusOptionsHandler - Սերվերի tus հնարավորությունների հայտարարում
+func tusOptionsHandler(c *gin.Context) {
+	c.Header("Tus-Version", tusVersion)
+	c.Header("Tus-Extension", tusExtensions)
+	c.Header("Tus-Max-Size", strconv.FormatInt(tusMaxSize, 10))
+	c.Status(http.StatusNoContent)
+}
+
+// createTusUploadHandler - Նոր ընդհատվող վերբեռնման ստեղծում (tus creation)
+func createTusUploadHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	length, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Անվավեր կամ բացակայող Upload-Length"})
+		return
+	}
+	if length > tusMaxSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Ֆայլը չափազանց մեծ է"})
+		return
+	}
+
+	metadata := parseTusMetadata(c.GetHeader("Upload-Metadata"))
+	filename := metadata["filename"]
+	if filename == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload-Metadata-ում բացակայում է filename"})
+		return
+	}
+
+	extension := filepath.Ext(filename)
+	contentType := metadata["filetype"]
+	if contentType == "" {
+		contentType = getContentType(extension)
+	}
+
+	uploadID := newImageID()
+	uploadDir := cfg.Storage.UploadDir
+	objectKey := filepath.Join(uploadDir, uploadID+extension)
+	bucketName := cfg.Storage.Bucket
+
+	multipart, err := s3Client.CreateMultipartUpload(c.Request.Context(), &s3.CreateMultipartUploadInput{
+		Bucket:      aws.String(bucketName),
+		Key:         aws.String(objectKey),
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Սխալ multipart վերբեռնումը սկսելիս", "image_id", uploadID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Չհաջողվեց սկսել վերբեռնումը"})
+		return
+	}
+
+	upload := Upload{
+		ID:          uploadID,
+		ObjectKey:   objectKey,
+		S3UploadID:  aws.ToString(multipart.UploadId),
+		Name:        filename,
+		ContentType: contentType,
+		Tags:        metadata["tags"],
+		Length:      length,
+		Status:      uploadStatusInProgress,
+	}
+	if err := db.WithContext(ctx).Create(&upload).Error; err != nil {
+		slog.ErrorContext(ctx, "Սխալ վերբեռնման վիճակը պահելիս", "image_id", uploadID, "error", err)
+		abortMultipart(c.Request.Context(), &upload)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Չհաջողվեց սկսել վերբեռնումը"})
+		return
+	}
+
+	c.Header("Location", c.Request.URL.Path+"/"+upload.ID)
+	setUploadExpires(c, upload.UpdatedAt)
+	c.Status(http.StatusCreated)
+}
+
+// headTusUploadHandler - Վերբեռնման ընթացիկ offset-ի ստացում
+func headTusUploadHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	var upload Upload
+	if err := db.WithContext(ctx).First(&upload, "id = ?", c.Param("id")).Error; err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	if uploadExpired(&upload) {
+		c.Status(http.StatusGone)
+		return
+	}
+
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	if upload.Status == uploadStatusCompleted {
+		c.Header("X-Image-Id", upload.ID)
+	} else if upload.Status == uploadStatusInProgress {
+		setUploadExpires(c, upload.UpdatedAt)
+	}
+	c.Status(http.StatusOK)
+}
+
+// patchTusUploadHandler - Տվյալների հաջորդ հատվածի ընդունում
+func patchTusUploadHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	if c.ContentType() != "application/offset+octet-stream" {
+		c.Status(http.StatusUnsupportedMediaType)
+		return
+	}
+
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Անվավեր Upload-Offset"})
+		return
+	}
+
+	id := c.Param("id")
+	unlock, ok := lockUpload(id)
+	if !ok {
+		c.JSON(http.StatusLocked, gin.H{"error": "Վերբեռնումն արդեն ընթացքի մեջ է"})
+		return
+	}
+	defer unlock()
+
+	var upload Upload
+	if err := db.WithContext(ctx).First(&upload, "id = ?", id).Error; err != nil {
+		uploadLocks.Delete(id)
+		c.Status(http.StatusNotFound)
+		return
+	}
+	if uploadExpired(&upload) {
+		c.Status(http.StatusGone)
+		return
+	}
+	if offset != upload.Offset || upload.Status != uploadStatusInProgress {
+		c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+		c.Status(http.StatusConflict)
+		return
+	}
+
+	// Հարցումը կարող է ընդհատվել, բայց արդեն ստացված բայթերը պետք է պահպանել
+	ctx = context.WithoutCancel(ctx)
+	if err := writeTusChunk(ctx, &upload, c.Request.Body); err != nil {
+		slog.ErrorContext(ctx, "Սխալ վերբեռնման հատվածը պահելիս", "image_id", upload.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Չհաջողվեց պահել վերբեռնման հատվածը"})
+		return
+	}
+
+	if upload.Offset == upload.Length {
+		err := completeTusUpload(ctx, &upload)
+		// Ավարտված կամ ձախողված վերբեռնումն այլևս PATCH չի ընդունում, կողպեքը պետք չէ
+		uploadLocks.Delete(id)
+		if err != nil {
+			slog.ErrorContext(ctx, "Սխալ վերբեռնումն ավարտելիս", "image_id", upload.ID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Չհաջողվեց ավարտել վերբեռնումը"})
+			return
+		}
+		c.Header("X-Image-Id", upload.ID)
+	} else {
+		setUploadExpires(c, time.Now())
+	}
+
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	c.Status(http.StatusNoContent)
+}
+
+// deleteTusUploadHandler - Չավարտված վերբեռնման չեղարկում (tus termination)
+func deleteTusUploadHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	id := c.Param("id")
+	unlock, ok := lockUpload(id)
+	if !ok {
+		c.JSON(http.StatusLocked, gin.H{"error": "Վերբեռնումն արդեն ընթացքի մեջ է"})
+		return
+	}
+	defer unlock()
+
+	var upload Upload
+	if err := db.WithContext(ctx).First(&upload, "id = ?", id).Error; err != nil || upload.Status != uploadStatusInProgress {
+		uploadLocks.Delete(id)
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	abortMultipart(c.Request.Context(), &upload)
+
+	if err := deleteUploadRecord(db.WithContext(ctx), &upload); err != nil {
+		slog.ErrorContext(ctx, "Սխալ վերբեռնման վիճակը ջնջելիս", "image_id", upload.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Չհաջողվեց չեղարկել վերբեռնումը"})
+		return
+	}
+	uploadLocks.Delete(id)
+
+	c.Status(http.StatusNoContent)
+}
+
+// writeTusChunk - Կարդալ հարցման մարմինը, ուղարկել ամբողջական մասերը S3 և պահել մնացորդը
+func writeTusChunk(ctx context.Context, upload *Upload, body io.Reader) error {
+	bucketName := cfg.Storage.Bucket
+	hadPending := upload.PendingSize > 0
+
+	buffer := make([]byte, tusPartSize)
+	filled := 0
+
+	// Նախորդ հարցումից մնացած բայթերը դառնում են հաջորդ մասի սկիզբը
+	if hadPending {
+		pending, err := s3Client.GetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String(upload.pendingKey()),
+		})
+		if err != nil {
+			return err
+		}
+		filled, err = io.ReadFull(pending.Body, buffer[:upload.PendingSize])
+		pending.Body.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	committed := upload.Offset - upload.PendingSize
+	reader := io.LimitReader(body, upload.Length-upload.Offset)
+
+	for {
+		n, readErr := io.ReadFull(reader, buffer[filled:])
+		filled += n
+
+		if filled == len(buffer) {
+			if err := commitTusPart(ctx, upload, buffer[:filled]); err != nil {
+				return err
+			}
+			committed += int64(filled)
+			filled = 0
+		}
+
+		if readErr != nil {
+			// Կապի խզման դեպքում պահպանել այն, ինչ հասցրել ենք ստանալ
+			if !errors.Is(readErr, io.EOF) && !errors.Is(readErr, io.ErrUnexpectedEOF) {
+				slog.WarnContext(ctx, "Վերբեռնման հոսքն ընդհատվեց", "image_id", upload.ID, "offset", upload.Offset, "error", readErr)
+			}
+			break
+		}
+	}
+
+	// Վերջին մասը կարող է լինել 5 MiB-ից փոքր
+	if committed+int64(filled) == upload.Length {
+		var partCount int64
+		if err := db.WithContext(ctx).Model(&UploadPart{}).Where("upload_id = ?", upload.ID).Count(&partCount).Error; err != nil {
+			return err
+		}
+		if filled > 0 || partCount == 0 {
+			if err := commitTusPart(ctx, upload, buffer[:filled]); err != nil {
+				return err
+			}
+		}
+		if hadPending {
+			deletePendingObject(ctx, upload)
+		}
+		return nil
+	}
+
+	if filled == 0 {
+		if hadPending {
+			deletePendingObject(ctx, upload)
+		}
+		return nil
+	}
+
+	_, err := s3Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(upload.pendingKey()),
+		Body:   bytes.NewReader(buffer[:filled]),
+	})
+	if err != nil {
+		return err
+	}
+
+	upload.PendingSize = int64(filled)
+	upload.Offset = committed + int64(filled)
+	return db.WithContext(ctx).Model(upload).Updates(map[string]interface{}{
+		"pending_size": upload.PendingSize,
+		"offset":       upload.Offset,
+	}).Error
+}
+
+// commitTusPart - Ուղարկել մեկ մաս S3 և գրանցել այն բազայում
+func commitTusPart(ctx context.Context, upload *Upload, data []byte) error {
+	var partNumber int32
+	if err := db.WithContext(ctx).Model(&UploadPart{}).Where("upload_id = ?", upload.ID).
+		Select("COALESCE(MAX(part_number), 0)").Scan(&partNumber).Error; err != nil {
+		return err
+	}
+	partNumber++
+
+	result, err := s3Client.UploadPart(ctx, &s3.UploadPartInput{
+		Bucket:        aws.String(cfg.Storage.Bucket),
+		Key:           aws.String(upload.ObjectKey),
+		UploadId:      aws.String(upload.S3UploadID),
+		PartNumber:    aws.Int32(partNumber),
+		Body:          bytes.NewReader(data),
+		ContentLength: aws.Int64(int64(len(data))),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Մասը և նոր offset-ը պահպանվում են միասին, որպեսզի վիճակը մնա համաձայնեցված
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		part := UploadPart{
+			UploadID:   upload.ID,
+			PartNumber: partNumber,
+			ETag:       aws.ToString(result.ETag),
+			Size:       int64(len(data)),
+		}
+		if err := tx.Create(&part).Error; err != nil {
+			return err
+		}
+
+		upload.Offset = upload.Offset - upload.PendingSize + part.Size
+		upload.PendingSize = 0
+		return tx.Model(upload).Updates(map[string]interface{}{
+			"pending_size": upload.PendingSize,
+			"offset":       upload.Offset,
+		}).Error
+	})
+}
+
+// completeTusUpload - Ավարտել multipart վերբեռնումը և ստեղծել նկարի գրառումը
+func completeTusUpload(ctx context.Context, upload *Upload) error {
+	var parts []UploadPart
+	if err := db.WithContext(ctx).Where("upload_id = ?", upload.ID).Order("part_number").Find(&parts).Error; err != nil {
+		return err
+	}
+
+	completed := make([]types.CompletedPart, 0, len(parts))
+	for _, part := range parts {
+		completed = append(completed, types.CompletedPart{
+			ETag:       aws.String(part.ETag),
+			PartNumber: aws.Int32(part.PartNumber),
+		})
+	}
+
+	_, err := s3Client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(cfg.Storage.Bucket),
+		Key:             aws.String(upload.ObjectKey),
+		UploadId:        aws.String(upload.S3UploadID),
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: completed},
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := finishUpload(ctx, upload, upload.Length); err != nil {
+		// Multipart-ն արդեն ավարտված է, ուստի վերբեռնումը չի կարող շարունակվել, ջնջել օբյեկտը
+		complete := newSaga("tus complete")
+		complete.onFailure(deleteObjectCompensation(upload.ObjectKey))
+		complete.compensate(ctx)
+		db.WithContext(ctx).Model(upload).Where("status = ?", uploadStatusInProgress).Update("status", uploadStatusFailed)
+		return err
+	}
+	observeUpload(uploadMethodTus, upload.Length, upload.CreatedAt)
+	return nil
+}
+
+// finishUpload - Ստեղծել նկարի գրառումը ավարտված վերբեռնումից և կապել թեգերը մեկ տրանզակցիայում
+func finishUpload(ctx context.Context, upload *Upload, size int64) (Image, error) {
+	image := Image{
+		ID:          upload.ID,
+		ObjectKey:   upload.ObjectKey,
+		Name:        upload.Name,
+		Size:        size,
+		ContentType: upload.ContentType,
+		UploadedAt:  time.Now(),
+		// Հեշը և չափերը կհաշվի մշակման աշխատանքը
+		Status:           initialImageStatus(),
+		ProcessingStatus: processingStatusPending,
+	}
+
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&image).Error; err != nil {
+			return err
+		}
+		if _, err := attachTags(tx, image.ID, parseTagList(upload.Tags)); err != nil {
+			return err
+		}
+		if err := enqueueNewImageProcessing(tx, &image); err != nil {
+			return err
+		}
+		if err := tx.Where("upload_id = ?", upload.ID).Delete(&UploadPart{}).Error; err != nil {
+			return err
+		}
+		// Մաքրիչը կարող էր արդեն վերցնել վերբեռնումը, այդ դեպքում նկարը չի գրանցվում
+		result := tx.Model(upload).Where("status = ?", upload.Status).Update("status", uploadStatusCompleted)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errUploadExpired
+		}
+		return nil
+	})
+	if err != nil {
+		return Image{}, err
+	}
+
+	upload.Status = uploadStatusCompleted
+	announceCreatedImage(ctx, image, parseTagList(upload.Tags))
+	return image, nil
+}
+
+// abortMultipart - Չեղարկել S3 multipart վերբեռնումը և ջնջել չավարտված մասը
+func abortMultipart(ctx context.Context, upload *Upload) error {
+	_, err := s3Client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(cfg.Storage.Bucket),
+		Key:      aws.String(upload.ObjectKey),
+		UploadId: aws.String(upload.S3UploadID),
+	})
+	// Նախորդ փորձով արդեն չեղարկված multipart-ը սխալ չէ
+	var noSuchUpload *types.NoSuchUpload
+	if errors.As(err, &noSuchUpload) {
+		err = nil
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Սխալ multipart վերբեռնումը չեղարկելիս", "image_id", upload.ID, "error", err)
+	}
+
+	if upload.PendingSize > 0 {
+		return errors.Join(err, deletePendingObject(ctx, upload))
+	}
+	return err
+}
+
+// deletePendingObject - Ջնջել չավարտված մասի ժամանակավոր օբյեկտը
+func deletePendingObject(ctx context.Context, upload *Upload) error {
+	_, err := s3Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(cfg.Storage.Bucket),
+		Key:    aws.String(upload.pendingKey()),
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Սխալ ժամանակավոր մասը ջնջելիս", "image_id", upload.ID, "error", err)
+	}
+	return err
+}
+
+// deleteUploadRecord - Ջնջել վերբեռնման գրառումը և դրա մասերը մեկ տրանզակցիայում
+func deleteUploadRecord(tx *gorm.DB, upload *Upload) error {
+	return tx.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("upload_id = ?", upload.ID).Delete(&UploadPart{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(upload).Error
+	})
+}
+
+// releaseUploadStorage - Ջնջել վերբեռնման S3 մնացորդները. Ուղիղ վերբեռնումը multipart չունի,
+// բայց հաճախորդը կարող էր արդեն PUT անել օբյեկտը
+func releaseUploadStorage(ctx context.Context, upload *Upload) error {
+	if upload.S3UploadID == "" {
+		return deletePresignedObject(ctx, upload)
+	}
+	return abortMultipart(ctx, upload)
+}
+
+// startUploadSweeper - Գործարկել ֆոնային մաքրիչը, որը ջնջում է լքված վերբեռնումները
+func startUploadSweeper(ctx context.Context) {
+	interval := cfg.Uploads.SweepInterval
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			// Մինչև բազային կապակցվելը մաքրումը բաց է թողնվում
+			if dbAvailable() {
+				sweepExpiredUploads(context.Background())
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// sweepExpiredUploads - Ջնջել ժամկետանց tus և ուղիղ վերբեռնումների S3 մնացորդները և գրառումները
+func sweepExpiredUploads(ctx context.Context) {
+	cutoff := time.Now().Add(-cfg.Uploads.Expiry)
+
+	// Նախ նշել ժամկետանցները, որպեսզի ուշացած PATCH-ը կամ complete-ը այլևս չընդունվի, նույնիսկ մեկ այլ օրինակում
+	err := db.WithContext(ctx).Model(&Upload{}).
+		Where("status = ? AND updated_at < ?", uploadStatusInProgress, cutoff).
+		Update("status", uploadStatusExpired).Error
+	if err == nil {
+		err = expirePresignedUploads(ctx)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Սխալ ժամկետանց վերբեռնումները նշելիս", "error", err)
+		return
+	}
+
+	var uploads []Upload
+	if err := db.WithContext(ctx).Where("status = ?", uploadStatusExpired).Limit(uploadSweepBatchSize).Find(&uploads).Error; err != nil {
+		slog.ErrorContext(ctx, "Սխալ ժամկետանց վերբեռնումները ստանալիս", "error", err)
+		return
+	}
+
+	swept := 0
+	for i := range uploads {
+		upload := &uploads[i]
+		unlock, ok := lockUpload(upload.ID)
+		if !ok {
+			continue
+		}
+		// S3-ի մնացորդները չջնջվելու դեպքում գրառումը մնում է հաջորդ անցման համար
+		if err := releaseUploadStorage(ctx, upload); err == nil {
+			if err := deleteUploadRecord(db.WithContext(ctx), upload); err != nil {
+				slog.ErrorContext(ctx, "Սխալ ժամկետանց վերբեռնումը ջնջելիս", "image_id", upload.ID, "error", err)
+			} else {
+				swept++
+			}
+		}
+		unlock()
+		uploadLocks.Delete(upload.ID)
+	}
+	if swept > 0 {
+		slog.InfoContext(ctx, "Ժամկետանց վերբեռնումները մաքրվեցին", "swept", swept)
+	}
+}
+
+// parseTusMetadata - Upload-Metadata վերնագրի վերլուծություն («key base64value,...»)
+func parseTusMetadata(header string) map[string]string {
+	metadata := make(map[string]string)
+
+	for _, pair := range strings.Split(header, ",") {
+		fields := strings.Fields(pair)
+		if len(fields) == 0 {
+			continue
+		}
+
+		value := ""
+		if len(fields) > 1 {
+			decoded, err := base64.StdEncoding.DecodeString(fields[1])
+			if err != nil {
+				continue
+			}
+			value = string(decoded)
+		}
+		metadata[fields[0]] = value
+	}
+
+	return metadata
+}
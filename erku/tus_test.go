@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// serveTus - Կատարել tus հարցում /api/uploads/:id մարշրուտով
+func serveTus(method, target string, handler gin.HandlerFunc, contentType string, offset int64) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.Handle(method, "/api/uploads/:id", handler)
+	request := httptest.NewRequest(method, target, strings.NewReader("data"))
+	request.Header.Set("Tus-Resumable", tusVersion)
+	request.Header.Set("Upload-Offset", strconv.FormatInt(offset, 10))
+	if contentType != "" {
+		request.Header.Set("Content-Type", contentType)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+// insertUpload - tus վերբեռնում, որի վերջին հատվածը ստացվել է lastActivity-ին
+func insertUpload(t *testing.T, fake *fakeS3, id string, lastActivity time.Time) Upload {
+	t.Helper()
+	upload := Upload{
+		ID:          id,
+		ObjectKey:   "uploads/" + id + ".png",
+		S3UploadID:  "multipart-" + id,
+		Name:        id + ".png",
+		ContentType: "image/png",
+		Length:      tusPartSize * 2,
+		Offset:      tusPartSize + 10,
+		PendingSize: 10,
+		Status:      uploadStatusInProgress,
+	}
+	if err := db.Create(&upload).Error; err != nil {
+		t.Fatalf("վերբեռնում: %v", err)
+	}
+	db.Create(&UploadPart{UploadID: id, PartNumber: 1, ETag: `"etag"`, Size: tusPartSize})
+	db.Model(&Upload{}).Where("id = ?", id).UpdateColumn("updated_at", lastActivity)
+	upload.UpdatedAt = lastActivity
+	fake.put(upload.pendingKey(), make([]byte, 10))
+	return upload
+}
+
+func TestSweepExpiredUploadsRemovesAbandonedUploads(t *testing.T) {
+	fake := setupTest(t)
+	abandoned := insertUpload(t, fake, "abandoned", time.Now().Add(-cfg.Uploads.Expiry-time.Minute))
+	active := insertUpload(t, fake, "active", time.Now())
+	uploadLocks.Store(abandoned.ID, &sync.Mutex{})
+
+	sweepExpiredUploads(context.Background())
+
+	var remaining []string
+	db.Model(&Upload{}).Pluck("id", &remaining)
+	if !slices.Equal(remaining, []string{active.ID}) {
+		t.Fatalf("մնացած վերբեռնումներ %v, սպասվում էր [%s]", remaining, active.ID)
+	}
+	var parts int64
+	db.Model(&UploadPart{}).Where("upload_id = ?", abandoned.ID).Count(&parts)
+	if parts != 0 {
+		t.Fatalf("մնացել է %d մաս", parts)
+	}
+	if fake.has(abandoned.pendingKey()) {
+		t.Fatal("ժամանակավոր մասը չի ջնջվել")
+	}
+	if !fake.has(active.pendingKey()) {
+		t.Fatal("ակտիվ վերբեռնման ժամանակավոր մասը ջնջվել է")
+	}
+	if !slices.Contains(fake.callLog(), "ABORT "+abandoned.ObjectKey) {
+		t.Fatalf("multipart-ը չի չեղարկվել: %v", fake.callLog())
+	}
+	if _, ok := uploadLocks.Load(abandoned.ID); ok {
+		t.Fatal("կողպեքը մնացել է uploadLocks-ում")
+	}
+}
+
+func TestSweepExpiredUploadsRetriesWhenAbortFails(t *testing.T) {
+	fake := setupTest(t)
+	upload := insertUpload(t, fake, "abandoned", time.Now().Add(-cfg.Uploads.Expiry-time.Minute))
+	fake.failOn("ABORT")
+
+	sweepExpiredUploads(context.Background())
+
+	var current Upload
+	if err := db.First(&current, "id = ?", upload.ID).Error; err != nil {
+		t.Fatalf("գրառումը ջնջվել է, չնայած multipart-ը մնացել է: %v", err)
+	}
+	if current.Status != uploadStatusExpired {
+		t.Fatalf("վիճակ %q, սպասվում էր %q", current.Status, uploadStatusExpired)
+	}
+
+	// Ժամկետանց վերբեռնումը այլևս չի ընդունում հատվածներ
+	response := serveTus(http.MethodPatch, "/api/uploads/"+upload.ID, patchTusUploadHandler, "application/offset+octet-stream", current.Offset)
+	if response.Code != http.StatusGone {
+		t.Fatalf("PATCH կոդ %d, սպասվում էր 410", response.Code)
+	}
+	response = serveTus(http.MethodHead, "/api/uploads/"+upload.ID, headTusUploadHandler, "", 0)
+	if response.Code != http.StatusGone {
+		t.Fatalf("HEAD կոդ %d, սպասվում էր 410", response.Code)
+	}
+}
+
+func TestHeadTusUploadReportsExpiry(t *testing.T) {
+	fake := setupTest(t)
+	lastActivity := time.Now().Add(-time.Hour).Truncate(time.Second)
+	upload := insertUpload(t, fake, "active", lastActivity)
+
+	response := serveTus(http.MethodHead, "/api/uploads/"+upload.ID, headTusUploadHandler, "", 0)
+	if response.Code != http.StatusOK {
+		t.Fatalf("կոդ %d", response.Code)
+	}
+	expires, err := http.ParseTime(response.Header().Get("Upload-Expires"))
+	if err != nil || !expires.Equal(lastActivity.Add(cfg.Uploads.Expiry)) {
+		t.Fatalf("Upload-Expires %q, սպասվում էր %s", response.Header().Get("Upload-Expires"), lastActivity.Add(cfg.Uploads.Expiry))
+	}
+
+	response = serveTus(http.MethodOptions, "/api/uploads/"+upload.ID, tusOptionsHandler, "", 0)
+	if !strings.Contains(response.Header().Get("Tus-Extension"), "expiration") {
+		t.Fatalf("Tus-Extension %q", response.Header().Get("Tus-Extension"))
+	}
+}
+
+func TestTerminateTusUploadReleasesLock(t *testing.T) {
+	fake := setupTest(t)
+	upload := insertUpload(t, fake, "active", time.Now())
+
+	response := serveTus(http.MethodDelete, "/api/uploads/"+upload.ID, deleteTusUploadHandler, "", 0)
+	if response.Code != http.StatusNoContent {
+		t.Fatalf("կոդ %d: %s", response.Code, response.Body)
+	}
+	if _, ok := uploadLocks.Load(upload.ID); ok {
+		t.Fatal("կողպեքը մնացել է uploadLocks-ում")
+	}
+	if fake.has(upload.pendingKey()) {
+		t.Fatal("ժամանակավոր մասը չի ջնջվել")
+	}
+
+	// Գոյություն չունեցող ID-ները նույնպես չպետք է կուտակվեն
+	serveTus(http.MethodDelete, "/api/uploads/missing", deleteTusUploadHandler, "", 0)
+	if _, ok := uploadLocks.Load("missing"); ok {
+		t.Fatal("անհայտ ID-ի կողպեքը մնացել է uploadLocks-ում")
+	}
+}
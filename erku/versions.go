@@ -0,0 +1,347 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ImageVersion - Նկարի բովանդակության մեկ տարբերակ (S3 VersionId)
+type ImageVersion struct {
+	ID          uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	ImageID     string    `json:"imageId" gorm:"type:varchar(36);index;not null"`
+	VersionID   string    `json:"versionId" gorm:"type:varchar(255);not null"`
+	Size        int64     `json:"size" gorm:"type:bigint;not null"`
+	ContentType string    `json:"contentType" gorm:"type:varchar(100);not null"`
+	Hash        string    `json:"hash" gorm:"type:varchar(64)"`
+	Width       int       `json:"width"`
+	Height      int       `json:"height"`
+	CreatedAt   time.Time `json:"createdAt" gorm:"autoCreateTime"`
+}
+
+// ImageVersionResponse - Տարբերակի արձագանքի կառուցվածք API-ի համար
+type ImageVersionResponse struct {
+	ImageVersion
+	URL     string `json:"url"`
+	Current bool   `json:"current"`
+}
+
+// versioningEnabled - Արդյոք bucket-ի տարբերակավորումը միացված է BUCKET_VERSIONING-ով
+func versioningEnabled() bool {
+	return cfg.Storage.Versioning
+}
+
+// enableBucketVersioning - Միացնել bucket-ի տարբերակավորումը, եթե այն կարգավորված է
+func enableBucketVersioning(ctx context.Context, bucketName string) {
+	if !versioningEnabled() {
+		return
+	}
+
+	_, err := s3Client.PutBucketVersioning(ctx, &s3.PutBucketVersioningInput{
+		Bucket: aws.String(bucketName),
+		VersioningConfiguration: &types.VersioningConfiguration{
+			Status: types.BucketVersioningStatusEnabled,
+		},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Չհաջողվեց միացնել bucket-ի տարբերակավորումը", "bucket", bucketName, "error", err)
+		return
+	}
+	slog.InfoContext(ctx, "Bucket-ի տարբերակավորումը միացված է", "bucket", bucketName)
+}
+
+// getImageVersionsHandler - Նկարի տարբերակների ցանկի ստացում
+func getImageVersionsHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	image, ok := findVersionedImage(c)
+	if !ok {
+		return
+	}
+
+	var versions []ImageVersion
+	if err := db.WithContext(ctx).Where("image_id = ?", image.ID).Order("id DESC").Find(&versions).Error; err != nil {
+		slog.ErrorContext(ctx, "Սխալ տարբերակների ցանկը ստանալիս", "image_id", image.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Չհաջողվեց ստանալ տարբերակների ցանկը"})
+		return
+	}
+
+	result := []ImageVersionResponse{}
+	for i, version := range versions {
+		response, err := createVersionResponse(c.Request.Context(), image, version, i == 0)
+		if err != nil {
+			continue
+		}
+		result = append(result, response)
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// getImageVersionHandler - Կոնկրետ տարբերակի ստացում
+func getImageVersionHandler(c *gin.Context) {
+	image, ok := findVersionedImage(c)
+	if !ok {
+		return
+	}
+
+	version, current, ok := findImageVersion(c, image)
+	if !ok {
+		return
+	}
+
+	response, err := createVersionResponse(c.Request.Context(), image, version, current)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Չհաջողվեց ստեղծել տարբերակի URL"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// restoreImageVersionHandler - Նախորդ տարբերակի վերականգնում որպես նոր ընթացիկ տարբերակ
+func restoreImageVersionHandler(c *gin.Context) {
+	image, ok := findVersionedImage(c)
+	if !ok {
+		return
+	}
+
+	version, current, ok := findImageVersion(c, image)
+	if !ok {
+		return
+	}
+	if current {
+		c.JSON(http.StatusConflict, gin.H{"error": "Տարբերակն արդեն ընթացիկն է"})
+		return
+	}
+	if version.VersionID == "" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Bucket-ի տարբերակավորումը միացված չէ"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	bucketName := cfg.Storage.Bucket
+	restore := newSaga("restore version")
+
+	// Հին տարբերակի պատճենումը նույն բանալու վրա ստեղծում է նոր ընթացիկ տարբերակ
+	result, err := s3Client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String(bucketName),
+		Key:        aws.String(image.ObjectKey),
+		CopySource: aws.String(versionCopySource(bucketName, image.ObjectKey, version.VersionID)),
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Սխալ տարբերակը վերականգնելիս", "image_id", image.ID, "version_id", version.VersionID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Չհաջողվեց վերականգնել տարբերակը"})
+		return
+	}
+	if result.VersionId != nil {
+		restore.onFailure(deleteVersionCompensation(image.ObjectKey, *result.VersionId))
+	}
+
+	restored := ImageVersion{
+		ImageID:     image.ID,
+		VersionID:   aws.ToString(result.VersionId),
+		Size:        version.Size,
+		ContentType: version.ContentType,
+		Hash:        version.Hash,
+		Width:       version.Width,
+		Height:      version.Height,
+	}
+	if err := applyImageVersion(ctx, &image, restored); err != nil {
+		slog.ErrorContext(ctx, "Սխալ նկարի տվյալները թարմացնելիս", "image_id", image.ID, "error", err)
+		restore.compensate(ctx)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Չհաջողվեց թարմացնել նկարի տվյալները"})
+		return
+	}
+	imageContentChanged(ctx, &image)
+
+	response, err := createVersionResponse(ctx, image, restored, true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Չհաջողվեց ստեղծել տարբերակի URL"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// findVersionedImage - Ստանալ :id պարամետրով նկարը կամ պատասխանել սխալով
+func findVersionedImage(c *gin.Context) (Image, bool) {
+	ctx := c.Request.Context()
+	if !dbAvailable() {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Տվյալների բազան հասանելի չէ"})
+		return Image{}, false
+	}
+
+	var image Image
+	if err := db.WithContext(ctx).Scopes(activeImages).First(&image, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Նկարը չի գտնվել"})
+		return Image{}, false
+	}
+	return image, true
+}
+
+// findImageVersion - Ստանալ :versionId պարամետրով տարբերակը և արդյոք այն ընթացիկն է
+func findImageVersion(c *gin.Context, image Image) (ImageVersion, bool, bool) {
+	ctx := c.Request.Context()
+	var version ImageVersion
+	err := db.WithContext(ctx).Where("image_id = ? AND version_id = ?", image.ID, c.Param("versionId")).
+		Order("id DESC").First(&version).Error
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Տարբերակը չի գտնվել"})
+		return ImageVersion{}, false, false
+	}
+
+	var latest ImageVersion
+	if err := db.WithContext(ctx).Where("image_id = ?", image.ID).Order("id DESC").First(&latest).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Չհաջողվեց ստանալ տարբերակների ցանկը"})
+		return ImageVersion{}, false, false
+	}
+
+	return version, latest.ID == version.ID, true
+}
+
+// recordCurrentVersion - Առաջին փոխարինումից առաջ գրանցել սկզբնական տարբերակը
+func recordCurrentVersion(ctx context.Context, image *Image) error {
+	var count int64
+	if err := db.WithContext(ctx).Model(&ImageVersion{}).Where("image_id = ?", image.ID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	head, err := s3Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(cfg.Storage.Bucket),
+		Key:    aws.String(image.ObjectKey),
+	})
+	if err != nil {
+		return err
+	}
+
+	return db.WithContext(ctx).Create(&ImageVersion{
+		ImageID:     image.ID,
+		VersionID:   aws.ToString(head.VersionId),
+		Size:        image.Size,
+		ContentType: image.ContentType,
+		Hash:        image.Hash,
+		Width:       image.Width,
+		Height:      image.Height,
+	}).Error
+}
+
+// applyImageVersion - Գրանցել նոր տարբերակը և թարմացնել նկարի տվյալները մեկ տրանզակցիայում
+func applyImageVersion(ctx context.Context, image *Image, version ImageVersion) error {
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&version).Error; err != nil {
+			return err
+		}
+		return updateImageContent(tx, image, image.ObjectKey, version)
+	})
+}
+
+// updateImageContent - Թարմացնել նկարի գրառումը նոր բովանդակության տվյալներով
+func updateImageContent(tx *gorm.DB, image *Image, objectKey string, version ImageVersion) error {
+	image.ObjectKey = objectKey
+	image.Size = version.Size
+	image.ContentType = version.ContentType
+	image.Hash = version.Hash
+	image.Width = version.Width
+	image.Height = version.Height
+	return tx.Model(image).Updates(map[string]interface{}{
+		"object_key":   image.ObjectKey,
+		"size":         image.Size,
+		"content_type": image.ContentType,
+		"hash":         image.Hash,
+		"width":        image.Width,
+		"height":       image.Height,
+	}).Error
+}
+
+// createVersionResponse - Տարբերակի արձագանքը իր նախապես ստորագրված URL-ով
+func createVersionResponse(ctx context.Context, image Image, version ImageVersion, current bool) (ImageVersionResponse, error) {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(cfg.Storage.Bucket),
+		Key:    aws.String(image.ObjectKey),
+	}
+	if version.VersionID != "" && version.VersionID != "null" {
+		input.VersionId = aws.String(version.VersionID)
+	}
+
+	presignedURL, err := presignClient.PresignGetObject(ctx, input, func(opts *s3.PresignOptions) {
+		opts.Expires = presignExpiry
+	})
+	if err != nil {
+		return ImageVersionResponse{}, err
+	}
+
+	return ImageVersionResponse{
+		ImageVersion: version,
+		URL:          presignedURL.URL,
+		Current:      current,
+	}, nil
+}
+
+// deleteVersionCompensation - Փոխհատուցում, որը ջնջում է օբյեկտի կոնկրետ տարբերակը
+func deleteVersionCompensation(objectKey, versionID string) func(context.Context) error {
+	return func(ctx context.Context) error {
+		_, err := s3Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+			Bucket:    aws.String(cfg.Storage.Bucket),
+			Key:       aws.String(objectKey),
+			VersionId: aws.String(versionID),
+		})
+		return err
+	}
+}
+
+// deleteAllVersions - Ջնջել օբյեկտի բոլոր տարբերակները և ջնջման նշիչները
+func deleteAllVersions(ctx context.Context, objectKey string) error {
+	bucketName := cfg.Storage.Bucket
+	paginator := s3.NewListObjectVersionsPaginator(s3Client, &s3.ListObjectVersionsInput{
+		Bucket: aws.String(bucketName),
+		Prefix: aws.String(objectKey),
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+
+		var versionIDs []*string
+		for _, version := range page.Versions {
+			if aws.ToString(version.Key) == objectKey {
+				versionIDs = append(versionIDs, version.VersionId)
+			}
+		}
+		for _, marker := range page.DeleteMarkers {
+			if aws.ToString(marker.Key) == objectKey {
+				versionIDs = append(versionIDs, marker.VersionId)
+			}
+		}
+
+		for _, versionID := range versionIDs {
+			if err := deleteVersionCompensation(objectKey, aws.ToString(versionID))(ctx); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// versionCopySource - CopySource արժեքը օբյեկտի կոնկրետ տարբերակի համար
+func versionCopySource(bucketName, objectKey, versionID string) string {
+	return copySource(bucketName, objectKey) + "?versionId=" + url.QueryEscape(versionID)
+}
+
+// copySource - CopyObject-ի աղբյուրը URL-կոդավորված տեսքով
+func copySource(bucketName, objectKey string) string {
+	return (&url.URL{Path: bucketName + "/" + objectKey}).EscapedPath()
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// setupVersionedTest - Թեստային միջավայր տարբերակավորված bucket-ով և մեկ փոխարինված նկարով (v1 -> v2)
+func setupVersionedTest(t *testing.T) (*fakeS3, Image, []byte) {
+	t.Helper()
+	fake := setupTest(t)
+	fake.versioned = true
+	cfg.Storage.Versioning = true
+	image := insertImage(t, fake, "img-1")
+
+	// PNG-ից հետո լրացուցիչ բայթերը փոխում են բովանդակությունը, բայց ոչ տեսակը
+	replaced := append(append([]byte(nil), testPNG...), "v2"...)
+	response := serve(http.MethodPut, "/api/images/:id/content", "/api/images/img-1/content", replaceImageContentHandler, bytes.NewBuffer(replaced), "image/png")
+	if response.Code != http.StatusOK {
+		t.Fatalf("փոխարինում: կոդ %d: %s", response.Code, response.Body)
+	}
+	return fake, image, replaced
+}
+
+// listVersions - GET /api/images/:id/versions
+func listVersions(t *testing.T, id string) []ImageVersionResponse {
+	t.Helper()
+	response := serve(http.MethodGet, "/api/images/:id/versions", "/api/images/"+id+"/versions", getImageVersionsHandler, nil, "")
+	if response.Code != http.StatusOK {
+		t.Fatalf("տարբերակներ: կոդ %d: %s", response.Code, response.Body)
+	}
+	var versions []ImageVersionResponse
+	if err := json.Unmarshal(response.Body.Bytes(), &versions); err != nil {
+		t.Fatal(err)
+	}
+	return versions
+}
+
+// restoreVersion - POST /api/images/:id/versions/:versionId/restore
+func restoreVersion(id, versionID string) (int, ImageVersionResponse) {
+	response := serve(http.MethodPost, "/api/images/:id/versions/:versionId/restore",
+		"/api/images/"+id+"/versions/"+versionID+"/restore", restoreImageVersionHandler, nil, "")
+	var version ImageVersionResponse
+	json.Unmarshal(response.Body.Bytes(), &version)
+	return response.Code, version
+}
+
+func TestReplaceRecordsVersions(t *testing.T) {
+	fake, image, replaced := setupVersionedTest(t)
+
+	var current Image
+	db.First(&current, "id = ?", image.ID)
+	if current.ObjectKey != image.ObjectKey || current.Size != int64(len(replaced)) {
+		t.Fatalf("տարբերակավորված փոխարինումը պետք է պահի բանալին: %s, չափ %d", current.ObjectKey, current.Size)
+	}
+	if ids := fake.versionIDs(image.ObjectKey); !slices.Equal(ids, []string{"v1", "v2"}) {
+		t.Fatalf("S3 տարբերակներ %v", ids)
+	}
+
+	versions := listVersions(t, image.ID)
+	if len(versions) != 2 {
+		t.Fatalf("%d տարբերակ, սպասվում էր 2", len(versions))
+	}
+	if versions[0].VersionID != "v2" || !versions[0].Current || versions[0].Size != int64(len(replaced)) {
+		t.Errorf("ընթացիկ տարբերակ %+v", versions[0])
+	}
+	if versions[1].VersionID != "v1" || versions[1].Current || versions[1].Size != int64(len(testPNG)) {
+		t.Errorf("նախորդ տարբերակ %+v", versions[1])
+	}
+	if !strings.Contains(versions[1].URL, "versionId=v1") {
+		t.Errorf("URL-ը չի հղում տարբերակին: %s", versions[1].URL)
+	}
+
+	response := serve(http.MethodGet, "/api/images/:id/versions/:versionId", "/api/images/img-1/versions/v1", getImageVersionHandler, nil, "")
+	if response.Code != http.StatusOK || !strings.Contains(response.Body.String(), `"current":false`) {
+		t.Errorf("GET v1: կոդ %d: %s", response.Code, response.Body)
+	}
+	response = serve(http.MethodGet, "/api/images/:id/versions/:versionId", "/api/images/img-1/versions/v9", getImageVersionHandler, nil, "")
+	if response.Code != http.StatusNotFound {
+		t.Errorf("GET v9: կոդ %d, սպասվում էր 404", response.Code)
+	}
+}
+
+func TestRestoreVersion(t *testing.T) {
+	fake, image, _ := setupVersionedTest(t)
+
+	code, restored := restoreVersion(image.ID, "v1")
+	if code != http.StatusOK {
+		t.Fatalf("վերականգնում: կոդ %d", code)
+	}
+	// Վերականգնումը ստեղծում է նոր ընթացիկ տարբերակ, պատմությունը չի վերագրվում
+	if restored.VersionID != "v3" || !restored.Current || restored.Size != int64(len(testPNG)) {
+		t.Errorf("վերականգնված տարբերակ %+v", restored)
+	}
+	if !bytes.Equal(fake.current(image.ObjectKey), testPNG) {
+		t.Error("ընթացիկ օբյեկտը v1-ի բովանդակությունը չէ")
+	}
+	var current Image
+	db.First(&current, "id = ?", image.ID)
+	if current.Size != int64(len(testPNG)) {
+		t.Errorf("նկարի չափ %d, սպասվում էր %d", current.Size, len(testPNG))
+	}
+	if versions := listVersions(t, image.ID); len(versions) != 3 || versions[0].VersionID != "v3" {
+		t.Errorf("տարբերակներ %+v", versions)
+	}
+
+	for versionID, want := range map[string]int{"v3": http.StatusConflict, "v9": http.StatusNotFound} {
+		if code, _ := restoreVersion(image.ID, versionID); code != want {
+			t.Errorf("%s: կոդ %d, սպասվում էր %d", versionID, code, want)
+		}
+	}
+	if code, _ := restoreVersion("missing", "v1"); code != http.StatusNotFound {
+		t.Errorf("անհայտ նկար: կոդ %d, սպասվում էր 404", code)
+	}
+}
+
+func TestRestoreVersionRollsBackObjectOnDBFailure(t *testing.T) {
+	fake, image, replaced := setupVersionedTest(t)
+	failDBWrites(t, "create", "image_versions")
+
+	if code, _ := restoreVersion(image.ID, "v1"); code != http.StatusInternalServerError {
+		t.Fatalf("կոդ %d, սպասվում էր 500", code)
+	}
+	// Նոր տարբերակը ջնջվում է, և ընթացիկ է մնում փոխարինված բովանդակությունը
+	if ids := fake.versionIDs(image.ObjectKey); !slices.Equal(ids, []string{"v1", "v2"}) {
+		t.Errorf("S3 տարբերակներ %v, սպասվում էր [v1 v2]", ids)
+	}
+	if !bytes.Equal(fake.current(image.ObjectKey), replaced) {
+		t.Error("ընթացիկ օբյեկտը փոխվել է")
+	}
+	var current Image
+	db.First(&current, "id = ?", image.ID)
+	if current.Size != int64(len(replaced)) {
+		t.Errorf("նկարի չափ %d, սպասվում էր %d", current.Size, len(replaced))
+	}
+}
@@ -0,0 +1,474 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"gorm.io/gorm"
+)
+
+const (
+	deliveryStatusPending   = "pending"
+	deliveryStatusDelivered = "delivered"
+	deliveryStatusFailed    = "failed"
+	// deliveryStatusDead - բոլոր կրկնությունները ձախողվել են, հնարավոր է միայն ձեռքով վերաուղարկում
+	deliveryStatusDead = "dead"
+)
+
+// jobTypeDeliverWebhook - մեկ webhook առաքման ուղարկում
+const jobTypeDeliverWebhook = "deliver_webhook"
+
+const (
+	webhookTimeout = 10 * time.Second
+	// webhookResponseLimit - առաքման մատյանում պահվող պատասխանի առավելագույն չափը
+	webhookResponseLimit = 1024
+)
+
+// webhookEventTypes - իրադարձություններ, որոնց կարելի է բաժանորդագրվել
+var webhookEventTypes = []string{eventImageCreated, eventImageDeleted, eventImageTagged, eventImageUntagged, eventImageRestored}
+
+// webhookClient - Հաճախորդ, որը կապակցվելու պահին կրկին ստուգում է հասցեն, որպեսզի DNS-ի փոփոխությունը
+// կամ վերահղումը չտանի ներքին ցանց
+var webhookClient = &http.Client{
+	Timeout: webhookTimeout,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: webhookTimeout,
+			Control: webhookDialControl,
+		}).DialContext,
+		TLSHandshakeTimeout: webhookTimeout,
+		MaxIdleConns:        100,
+		IdleConnTimeout:     90 * time.Second,
+	},
+}
+
+// errWebhookAddressForbidden - հասցեն պատկանում է loopback, մասնավոր կամ link-local ցանցին
+var errWebhookAddressForbidden = errors.New("webhook-ի հասցեն ներքին ցանցում է")
+
+// webhookAddressAllowed - Արդյոք կարելի է ուղարկել տրված IP-ին. ներքին ցանցերը, այդ թվում
+// ամպային metadata ծառայությունը (169.254.169.254), արգելված են
+var webhookAddressAllowed = func(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	// 100.64.0.0/10 (carrier-grade NAT), որտեղ են որոշ ամպերի metadata ծառայությունները
+	if ip4 := ip.To4(); ip4 != nil && ip4[0] == 100 && ip4[1]&0xc0 == 64 {
+		return false
+	}
+	return true
+}
+
+// webhookDialControl - Արգելել կապակցումը ներքին հասցեներին
+func webhookDialControl(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !webhookAddressAllowed(ip) {
+		return fmt.Errorf("%w: %s", errWebhookAddressForbidden, host)
+	}
+	return nil
+}
+
+// validateWebhookURL - Թույլատրել միայն http(s) URL-ներ, որոնց բոլոր հասցեները հանրային են
+func validateWebhookURL(ctx context.Context, raw string) error {
+	target, err := url.Parse(raw)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Hostname() == "" {
+		return errors.New("Անվավեր URL")
+	}
+	if target.User != nil {
+		return errors.New("URL-ը չպետք է պարունակի մուտքի տվյալներ")
+	}
+
+	addresses, err := net.DefaultResolver.LookupIPAddr(ctx, target.Hostname())
+	if err != nil {
+		return fmt.Errorf("Չհաջողվեց որոշել %s հասցեն", target.Hostname())
+	}
+	for _, address := range addresses {
+		if !webhookAddressAllowed(address.IP) {
+			return fmt.Errorf("%w: %s", errWebhookAddressForbidden, address.IP)
+		}
+	}
+	return nil
+}
+
+// WebhookSubscription - Webhook բաժանորդագրության մոդելը GORM-ի համար
+type WebhookSubscription struct {
+	ID  uint   `json:"id" gorm:"primaryKey;autoIncrement"`
+	URL string `json:"url" gorm:"type:varchar(2048);not null"`
+	// Events - ստորակետով բաժանված իրադարձություններ, "*"՝ բոլորը
+	Events    string    `json:"events" gorm:"type:varchar(255);not null"`
+	Secret    string    `json:"-" gorm:"type:varchar(128);not null"`
+	Active    bool      `json:"active" gorm:"not null;default:true"`
+	CreatedAt time.Time `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+}
+
+// WebhookDelivery - Մեկ իրադարձության առաքումը մեկ բաժանորդին
+type WebhookDelivery struct {
+	ID             uint       `json:"id" gorm:"primaryKey;autoIncrement"`
+	SubscriptionID uint       `json:"subscriptionId" gorm:"index;not null"`
+	EventID        string     `json:"eventId" gorm:"type:varchar(36);not null"`
+	EventType      string     `json:"eventType" gorm:"type:varchar(50);not null"`
+	Payload        string     `json:"payload" gorm:"type:mediumtext;not null"`
+	Status         string     `json:"status" gorm:"type:varchar(20);not null;default:pending"`
+	Attempts       int        `json:"attempts" gorm:"not null;default:0"`
+	ResponseStatus int        `json:"responseStatus,omitempty"`
+	ResponseBody   string     `json:"responseBody,omitempty" gorm:"type:text"`
+	LastError      string     `json:"lastError,omitempty" gorm:"type:text"`
+	DeliveredAt    *time.Time `json:"deliveredAt,omitempty" gorm:"type:datetime"`
+	CreatedAt      time.Time  `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt      time.Time  `json:"updatedAt" gorm:"autoUpdateTime"`
+}
+
+// createWebhookHandler - Նոր webhook բաժանորդագրության ստեղծում
+func createWebhookHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	if !dbAvailable() {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Տվյալների բազան հասանելի չէ"})
+		return
+	}
+
+	var input struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+		Secret string   `json:"secret"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Անվավեր հարցում"})
+		return
+	}
+
+	if err := validateWebhookURL(ctx, input.URL); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	events, ok := normalizeWebhookEvents(input.Events)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Անհայտ իրադարձության տեսակ", "supported": webhookEventTypes})
+		return
+	}
+
+	// Գաղտնի բանալին գեներացվում է, եթե տրված չէ, և վերադարձվում է միայն ստեղծման ժամանակ
+	if input.Secret == "" {
+		input.Secret = randomSecret()
+	}
+
+	subscription := WebhookSubscription{URL: input.URL, Events: events, Secret: input.Secret, Active: true}
+	if err := db.WithContext(ctx).Create(&subscription).Error; err != nil {
+		slog.ErrorContext(ctx, "Սխալ webhook-ը պահելիս", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Չհաջողվեց ստեղծել webhook-ը"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"subscription": subscription,
+		"secret":       subscription.Secret,
+	})
+}
+
+// getWebhooksHandler - Բաժանորդագրությունների ցանկի ստացում
+func getWebhooksHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	if !dbAvailable() {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Տվյալների բազան հասանելի չէ"})
+		return
+	}
+
+	var subscriptions []WebhookSubscription
+	if err := db.WithContext(ctx).Find(&subscriptions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Չհաջողվեց ստանալ webhook-ների ցանկը"})
+		return
+	}
+
+	c.JSON(http.StatusOK, subscriptions)
+}
+
+// deleteWebhookHandler - Բաժանորդագրության ջնջում
+func deleteWebhookHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	if !dbAvailable() {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Տվյալների բազան հասանելի չէ"})
+		return
+	}
+
+	result := db.WithContext(ctx).Delete(&WebhookSubscription{}, "id = ?", c.Param("id"))
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Չհաջողվեց ջնջել webhook-ը"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook-ը չի գտնվել"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+// getWebhookDeliveriesHandler - Բաժանորդագրության առաքումների մատյան
+func getWebhookDeliveriesHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	if !dbAvailable() {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Տվյալների բազան հասանելի չէ"})
+		return
+	}
+
+	limit := 50
+	if value, err := strconv.Atoi(c.Query("limit")); err == nil && value > 0 && value <= 500 {
+		limit = value
+	}
+
+	var deliveries []WebhookDelivery
+	query := db.WithContext(ctx).Where("subscription_id = ?", c.Param("id")).Order("id DESC").Limit(limit)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Find(&deliveries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Չհաջողվեց ստանալ առաքումների մատյանը"})
+		return
+	}
+
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// redeliverWebhookHandler - Առաքման կրկնակի ուղարկում նույն բովանդակությամբ
+func redeliverWebhookHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	if !dbAvailable() {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Տվյալների բազան հասանելի չէ"})
+		return
+	}
+
+	var delivery WebhookDelivery
+	err := db.WithContext(ctx).Where("subscription_id = ?", c.Param("id")).First(&delivery, "id = ?", c.Param("deliveryId")).Error
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Առաքումը չի գտնվել"})
+		return
+	}
+
+	var job *Job
+	err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&delivery).Update("status", deliveryStatusPending).Error; err != nil {
+			return err
+		}
+		job, err = enqueueDeliveryJob(tx, delivery.ID)
+		return err
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Սխալ առաքումը վերահերթագրելիս", "delivery_id", delivery.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Չհաջողվեց վերաուղարկել"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"deliveryId": delivery.ID, "jobId": job.ID})
+}
+
+// enqueueWebhookDeliveries - Ստեղծել առաքում և աշխատանք յուրաքանչյուր համապատասխան բաժանորդի համար
+func enqueueWebhookDeliveries(ctx context.Context, e event) {
+	if !dbAvailable() {
+		return
+	}
+
+	var subscriptions []WebhookSubscription
+	if err := db.WithContext(ctx).Where("active = ?", true).Find(&subscriptions).Error; err != nil {
+		slog.ErrorContext(ctx, "Սխալ webhook-ները ստանալիս", "error", err)
+		return
+	}
+
+	var payload []byte
+	for _, subscription := range subscriptions {
+		if !subscription.subscribes(e.Type) {
+			continue
+		}
+
+		if payload == nil {
+			var err error
+			if payload, err = json.Marshal(e); err != nil {
+				slog.ErrorContext(ctx, "Սխալ իրադարձությունը սերիալիզացնելիս", "event_type", e.Type, "error", err)
+				return
+			}
+		}
+
+		delivery := WebhookDelivery{
+			SubscriptionID: subscription.ID,
+			EventID:        e.ID,
+			EventType:      e.Type,
+			Payload:        string(payload),
+			Status:         deliveryStatusPending,
+		}
+		err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&delivery).Error; err != nil {
+				return err
+			}
+			_, err := enqueueDeliveryJob(tx, delivery.ID)
+			return err
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "Սխալ webhook առաքումը հերթագրելիս", "subscription_id", subscription.ID, "error", err)
+		}
+	}
+}
+
+// enqueueDeliveryJob - Հերթագրել առաքման ուղարկումը
+func enqueueDeliveryJob(tx *gorm.DB, deliveryID uint) (*Job, error) {
+	job := &Job{
+		Type:        jobTypeDeliverWebhook,
+		Payload:     strconv.FormatUint(uint64(deliveryID), 10),
+		Status:      jobStatusPending,
+		MaxAttempts: cfg.Jobs.WebhookMaxAttempts,
+		RunAt:       time.Now(),
+	}
+	if err := tx.Create(job).Error; err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// deliverWebhookJob - Ուղարկել ստորագրված իրադարձությունը բաժանորդին
+func deliverWebhookJob(ctx context.Context, job *Job) error {
+	var delivery WebhookDelivery
+	if err := db.WithContext(ctx).First(&delivery, "id = ?", job.Payload).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	var subscription WebhookSubscription
+	if err := db.WithContext(ctx).First(&subscription, "id = ?", delivery.SubscriptionID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Բաժանորդագրությունը ջնջվել է, ուղարկելու տեղ չկա
+			return nil
+		}
+		return err
+	}
+
+	status, body, sendErr := sendWebhook(ctx, subscription, delivery)
+
+	updates := map[string]interface{}{
+		"attempts":        gorm.Expr("attempts + 1"),
+		"response_status": status,
+		"response_body":   body,
+	}
+	switch {
+	case sendErr == nil:
+		now := time.Now()
+		updates["status"] = deliveryStatusDelivered
+		updates["last_error"] = ""
+		updates["delivered_at"] = &now
+	case job.Attempts >= job.MaxAttempts:
+		updates["status"] = deliveryStatusDead
+		updates["last_error"] = sendErr.Error()
+	default:
+		updates["status"] = deliveryStatusFailed
+		updates["last_error"] = sendErr.Error()
+	}
+	if err := db.WithContext(ctx).Model(&delivery).Updates(updates).Error; err != nil {
+		slog.ErrorContext(ctx, "Սխալ առաքման մատյանը թարմացնելիս", "delivery_id", delivery.ID, "error", err)
+	}
+
+	return sendErr
+}
+
+// sendWebhook - POST հարցում HMAC-SHA256 ստորագրությամբ
+func sendWebhook(ctx context.Context, subscription WebhookSubscription, delivery WebhookDelivery) (int, string, error) {
+	payload := []byte(delivery.Payload)
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, "", err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "erku-webhooks/1.0")
+	request.Header.Set("X-Erku-Event", delivery.EventType)
+	request.Header.Set("X-Erku-Delivery", strconv.FormatUint(uint64(delivery.ID), 10))
+	// Ժամանակը ստորագրության մաս է, որպեսզի բաժանորդը կարողանա մերժել հին առաքումների կրկնումը
+	timestamp := time.Now().Unix()
+	request.Header.Set("X-Erku-Signature", fmt.Sprintf("t=%d,v1=%s", timestamp, signWebhookPayload(subscription.Secret, timestamp, payload)))
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(request.Header))
+
+	response, err := webhookClient.Do(request)
+	if err != nil {
+		return 0, "", err
+	}
+	defer response.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(response.Body, webhookResponseLimit))
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return response.StatusCode, string(body), fmt.Errorf("պատասխանի կոդ %d", response.StatusCode)
+	}
+	return response.StatusCode, string(body), nil
+}
+
+// signWebhookPayload - «<timestamp>.<payload>» տողի HMAC-SHA256 ստորագրությունը hex տեսքով
+func signWebhookPayload(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// subscribes - Արդյոք բաժանորդագրությունը ներառում է տրված իրադարձությունը
+func (s WebhookSubscription) subscribes(eventType string) bool {
+	for _, value := range strings.Split(s.Events, ",") {
+		if value == "*" || value == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeWebhookEvents - Ստուգել իրադարձությունների ցանկը, դատարկ ցանկը նշանակում է բոլորը
+func normalizeWebhookEvents(events []string) (string, bool) {
+	if len(events) == 0 {
+		return "*", true
+	}
+
+	var result []string
+	for _, value := range events {
+		value = strings.TrimSpace(value)
+		if value == "*" {
+			return "*", true
+		}
+		known := false
+		for _, eventType := range webhookEventTypes {
+			if value == eventType {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return "", false
+		}
+		result = append(result, value)
+	}
+	return strings.Join(result, ","), true
+}
+
+// randomSecret - Պատահական գաղտնի բանալի webhook-ի ստորագրության համար
+func randomSecret() string {
+	buffer := make([]byte, 32)
+	rand.Read(buffer)
+	return hex.EncodeToString(buffer)
+}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// cfg holds the settings loaded at startup.
+var cfg = defaultConfig()
+
+// Config is the full suti configuration. Later sources override earlier
+// ones: defaults, the file given by -config or CONFIG_FILE, environment
+// variables, then command-line flags.
+type Config struct {
+	HTTP    HTTPConfig    `config:"http"`
+	Log     LogConfig     `config:"log"`
+	Storage StorageConfig `config:"storage"`
+	Auth    AuthConfig    `config:"auth"`
+	Health  HealthConfig  `config:"health"`
+}
+
+type HTTPConfig struct {
+	Addr              string        `config:"addr" env:"HTTP_ADDR" help:"listen address"`
+	ReadHeaderTimeout time.Duration `config:"read_header_timeout" env:"HTTP_READ_HEADER_TIMEOUT" help:"time allowed to read request headers"`
+	ReadTimeout       time.Duration `config:"read_timeout" env:"HTTP_READ_TIMEOUT" help:"time allowed to read the whole request"`
+	WriteTimeout      time.Duration `config:"write_timeout" env:"HTTP_WRITE_TIMEOUT" help:"time allowed to write the response"`
+	IdleTimeout       time.Duration `config:"idle_timeout" env:"HTTP_IDLE_TIMEOUT" help:"keep-alive idle timeout"`
+	MaxHeaderBytes    int           `config:"max_header_bytes" env:"HTTP_MAX_HEADER_BYTES" help:"maximum size of request headers"`
+	ShutdownTimeout   time.Duration `config:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" help:"graceful shutdown deadline"`
+}
+
+type LogConfig struct {
+	Level string `config:"level" env:"LOG_LEVEL" help:"log level (debug, info, warn, error)"`
+}
+
+type StorageConfig struct {
+	Endpoint  string `config:"endpoint" env:"MINIO_ENDPOINT" help:"MinIO URL, also used in image links"`
+	Region    string `config:"region" env:"AWS_REGION" help:"S3 region"`
+	Bucket    string `config:"bucket" env:"BUCKET_NAME" help:"bucket name"`
+	AccessKey string `config:"access_key" env:"AWS_ACCESS_KEY_ID" help:"S3 access key"`
+	SecretKey string `config:"secret_key" env:"AWS_SECRET_ACCESS_KEY" secret:"true" help:"S3 secret key"`
+}
+
+type AuthConfig struct {
+	APIToken string `config:"api_token" env:"API_TOKEN" secret:"true" help:"token for protected routes"`
+}
+
+type HealthConfig struct {
+	CheckTimeout time.Duration `config:"check_timeout" env:"HEALTH_CHECK_TIMEOUT" help:"readiness check deadline"`
+}
+
+func defaultConfig() Config {
+	// PORT is still honoured for compatibility with router.Run.
+	addr := ":8080"
+	if port := os.Getenv("PORT"); port != "" {
+		addr = ":" + port
+	}
+
+	return Config{
+		HTTP: HTTPConfig{
+			Addr:              addr,
+			ReadHeaderTimeout: defaultReadHeaderTimeout,
+			ReadTimeout:       defaultReadTimeout,
+			WriteTimeout:      defaultWriteTimeout,
+			IdleTimeout:       defaultIdleTimeout,
+			MaxHeaderBytes:    defaultMaxHeaderBytes,
+			ShutdownTimeout:   defaultShutdownTimeout,
+		},
+		Log: LogConfig{Level: "info"},
+		Storage: StorageConfig{
+			Endpoint: "http://localhost:9000",
+			Region:   "us-east-1",
+			Bucket:   "code",
+		},
+		Health: HealthConfig{CheckTimeout: defaultHealthCheckTimeout},
+	}
+}
+
+// configField is one setting with its file key, environment variable and
+// flag.
+type configField struct {
+	key    string // "storage.bucket"
+	env    string
+	help   string
+	secret bool
+	value  reflect.Value
+}
+
+// flagName turns "storage.access_key" into "storage-access-key".
+func (f configField) flagName() string {
+	return strings.NewReplacer(".", "-", "_", "-").Replace(f.key)
+}
+
+// configFields lists the settings of c in declaration order.
+func configFields(c *Config) []configField {
+	var fields []configField
+	sections := reflect.ValueOf(c).Elem()
+	for i := 0; i < sections.NumField(); i++ {
+		section := sections.Type().Field(i).Tag.Get("config")
+		values := sections.Field(i)
+		for j := 0; j < values.NumField(); j++ {
+			tag := values.Type().Field(j).Tag
+			fields = append(fields, configField{
+				key:    section + "." + tag.Get("config"),
+				env:    tag.Get("env"),
+				help:   tag.Get("help"),
+				secret: tag.Get("secret") == "true",
+				value:  values.Field(j),
+			})
+		}
+	}
+	return fields
+}
+
+func (f configField) set(raw string) error {
+	switch f.value.Interface().(type) {
+	case time.Duration:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("expected a duration such as 30s or 5m, got %q", raw)
+		}
+		f.value.SetInt(int64(d))
+	case int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("expected an integer, got %q", raw)
+		}
+		f.value.SetInt(int64(n))
+	default:
+		f.value.SetString(raw)
+	}
+	return nil
+}
+
+// display returns the value for config print with secrets redacted.
+func (f configField) display() any {
+	if f.secret && f.value.String() != "" {
+		return "REDACTED"
+	}
+	if d, ok := f.value.Interface().(time.Duration); ok {
+		return d.String()
+	}
+	return f.value.Interface()
+}
+
+// loadConfig merges every configuration source and returns the arguments
+// left after the flags. All parse errors are reported together.
+func loadConfig(args []string) (Config, []string, error) {
+	c := defaultConfig()
+	fields := configFields(&c)
+
+	flags := flag.NewFlagSet("suti", flag.ContinueOnError)
+	configPath := flags.String("config", os.Getenv("CONFIG_FILE"), "YAML or TOML configuration file")
+	flagValues := make(map[string]string)
+	for _, field := range fields {
+		flags.Func(field.flagName(), field.help+" ("+field.env+")", func(value string) error {
+			flagValues[field.key] = value
+			return nil
+		})
+	}
+	if err := flags.Parse(args); err != nil {
+		return c, nil, err
+	}
+
+	var errs []error
+	if *configPath != "" {
+		values, err := readConfigFile(*configPath)
+		if err != nil {
+			return c, nil, err
+		}
+		known := make(map[string]bool, len(fields))
+		for _, field := range fields {
+			known[field.key] = true
+			if raw, ok := values[field.key]; ok {
+				if err := field.set(raw); err != nil {
+					errs = append(errs, fmt.Errorf("%s: %s: %w", *configPath, field.key, err))
+				}
+			}
+		}
+		for key := range values {
+			if !known[key] {
+				errs = append(errs, fmt.Errorf("%s: unknown setting %q", *configPath, key))
+			}
+		}
+	}
+
+	// A variable set to "" still overrides the file, so a secret can be cleared.
+	for _, field := range fields {
+		if raw, ok := os.LookupEnv(field.env); ok {
+			if err := field.set(raw); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", field.env, err))
+			}
+		}
+	}
+
+	for _, field := range fields {
+		if raw, ok := flagValues[field.key]; ok {
+			if err := field.set(raw); err != nil {
+				errs = append(errs, fmt.Errorf("-%s: %w", field.flagName(), err))
+			}
+		}
+	}
+
+	return c, flags.Args(), errors.Join(errs...)
+}
+
+// readConfigFile reads a YAML or TOML file into "section.key" values.
+func readConfigFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config file: %w", err)
+	}
+
+	var document map[string]any
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &document)
+	case ".toml":
+		err = toml.Unmarshal(data, &document)
+	default:
+		return nil, fmt.Errorf("%s: unknown format, expected .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	values := make(map[string]string)
+	for section, content := range document {
+		entries, ok := content.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%s: section %q must be a table of settings", path, section)
+		}
+		for key, value := range entries {
+			values[section+"."+key] = fmt.Sprint(value)
+		}
+	}
+	return values, nil
+}
+
+func (c *Config) validate() error {
+	var errs []error
+	for _, field := range configFields(c) {
+		switch value := field.value.Interface().(type) {
+		case time.Duration:
+			if value <= 0 {
+				errs = append(errs, fmt.Errorf("%s: must be positive, got %s", field.key, value))
+			}
+		case int:
+			if value <= 0 {
+				errs = append(errs, fmt.Errorf("%s: must be positive, got %d", field.key, value))
+			}
+		}
+	}
+
+	if _, err := parseLogLevel(c.Log.Level); err != nil {
+		errs = append(errs, fmt.Errorf("log.level: %w", err))
+	}
+	if endpoint, err := url.Parse(c.Storage.Endpoint); err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		errs = append(errs, fmt.Errorf("storage.endpoint: expected an http(s) URL, got %q", c.Storage.Endpoint))
+	}
+	required := map[string]string{
+		"storage.region":     c.Storage.Region,
+		"storage.bucket":     c.Storage.Bucket,
+		"storage.access_key": c.Storage.AccessKey,
+		"storage.secret_key": c.Storage.SecretKey,
+	}
+	for _, field := range configFields(c) {
+		if value, ok := required[field.key]; ok && value == "" {
+			errs = append(errs, fmt.Errorf("%s: required (set %s)", field.key, field.env))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// runConfigCommand implements `suti config print [-format yaml|toml]` and
+// returns the exit code.
+func runConfigCommand(c Config, args []string, out io.Writer) int {
+	if len(args) == 0 || args[0] != "print" {
+		fmt.Fprintln(os.Stderr, "usage: suti [flags] config print [-format yaml|toml]")
+		return 2
+	}
+
+	flags := flag.NewFlagSet("config print", flag.ContinueOnError)
+	format := flags.String("format", "yaml", "output format (yaml or toml)")
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	// Keep sections and keys in struct order rather than map order.
+	var sections []string
+	values := make(map[string][]configField)
+	for _, field := range configFields(&c) {
+		section, _, _ := strings.Cut(field.key, ".")
+		if _, ok := values[section]; !ok {
+			sections = append(sections, section)
+		}
+		values[section] = append(values[section], field)
+	}
+
+	var err error
+	switch *format {
+	case "yaml":
+		document := &yaml.Node{Kind: yaml.MappingNode}
+		for _, section := range sections {
+			entries := &yaml.Node{Kind: yaml.MappingNode}
+			for _, field := range values[section] {
+				var value yaml.Node
+				if err := value.Encode(field.display()); err != nil {
+					fmt.Fprintf(os.Stderr, "Failed to print configuration: %v\n", err)
+					return 1
+				}
+				_, key, _ := strings.Cut(field.key, ".")
+				entries.Content = append(entries.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, &value)
+			}
+			document.Content = append(document.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: section}, entries)
+		}
+		encoder := yaml.NewEncoder(out)
+		encoder.SetIndent(2)
+		err = encoder.Encode(document)
+	case "toml":
+		for i, section := range sections {
+			if i > 0 {
+				fmt.Fprintln(out)
+			}
+			fmt.Fprintf(out, "[%s]\n", section)
+			for _, field := range values[section] {
+				value, err := toml.Marshal(map[string]any{"v": field.display()})
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Failed to print configuration: %v\n", err)
+					return 1
+				}
+				_, key, _ := strings.Cut(field.key, ".")
+				fmt.Fprintf(out, "%s = %s", key, strings.TrimPrefix(string(value), "v = "))
+			}
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "unknown format %q\n", *format)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to print configuration: %v\n", err)
+		return 1
+	}
+
+	if err := c.validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration:\n%v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// unsetEnv removes key for the duration of the test.
+func unsetEnv(t *testing.T, key string) {
+	t.Helper()
+	t.Setenv(key, "")
+	os.Unsetenv(key)
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "suti.yaml")
+	err := os.WriteFile(file, []byte("auth:\n  api_token: from-file\nstorage:\n  bucket: file-bucket\nhttp:\n  shutdown_timeout: 20s\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		env          map[string]string
+		flags        []string
+		token        string
+		bucket       string
+		shutdownWait time.Duration
+	}{
+		{
+			name:         "file",
+			token:        "from-file",
+			bucket:       "file-bucket",
+			shutdownWait: 20 * time.Second,
+		},
+		{
+			name:         "env overrides file",
+			env:          map[string]string{"API_TOKEN": "from-env", "BUCKET_NAME": "env-bucket", "SHUTDOWN_TIMEOUT": "40s"},
+			token:        "from-env",
+			bucket:       "env-bucket",
+			shutdownWait: 40 * time.Second,
+		},
+		{
+			name:         "flag overrides env",
+			env:          map[string]string{"API_TOKEN": "from-env", "BUCKET_NAME": "env-bucket"},
+			flags:        []string{"-auth-api-token", "from-flag", "-storage-bucket", "flag-bucket"},
+			token:        "from-flag",
+			bucket:       "flag-bucket",
+			shutdownWait: 20 * time.Second,
+		},
+		{
+			name:         "empty env clears a file secret",
+			env:          map[string]string{"API_TOKEN": ""},
+			token:        "",
+			bucket:       "file-bucket",
+			shutdownWait: 20 * time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"CONFIG_FILE", "PORT", "API_TOKEN", "BUCKET_NAME", "SHUTDOWN_TIMEOUT"} {
+				unsetEnv(t, key)
+			}
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			c, args, err := loadConfig(append([]string{"-config", file}, append(tt.flags, "config", "print")...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.Auth.APIToken != tt.token {
+				t.Errorf("auth.api_token = %q, want %q", c.Auth.APIToken, tt.token)
+			}
+			if c.Storage.Bucket != tt.bucket {
+				t.Errorf("storage.bucket = %q, want %q", c.Storage.Bucket, tt.bucket)
+			}
+			if c.HTTP.ShutdownTimeout != tt.shutdownWait {
+				t.Errorf("http.shutdown_timeout = %s, want %s", c.HTTP.ShutdownTimeout, tt.shutdownWait)
+			}
+			if strings.Join(args, " ") != "config print" {
+				t.Errorf("remaining args = %q", args)
+			}
+		})
+	}
+}
+
+func TestConfigPrintRedactsSecrets(t *testing.T) {
+	c := defaultConfig()
+	c.Storage.AccessKey = "access"
+	c.Storage.SecretKey = "s3-secret"
+	c.Auth.APIToken = "api-secret"
+
+	for _, format := range []string{"yaml", "toml"} {
+		t.Run(format, func(t *testing.T) {
+			var out bytes.Buffer
+			if code := runConfigCommand(c, []string{"print", "-format", format}, &out); code != 0 {
+				t.Fatalf("exit code %d", code)
+			}
+			printed := out.String()
+			for _, secret := range []string{"s3-secret", "api-secret"} {
+				if strings.Contains(printed, secret) {
+					t.Errorf("secret %q printed:\n%s", secret, printed)
+				}
+			}
+			if got := strings.Count(printed, "REDACTED"); got != 2 {
+				t.Errorf("REDACTED appears %d times, want 2:\n%s", got, printed)
+			}
+			if !strings.Contains(printed, "access") {
+				t.Errorf("access key missing:\n%s", printed)
+			}
+		})
+	}
+
+	t.Run("empty secret", func(t *testing.T) {
+		c := c
+		c.Auth.APIToken = ""
+		var out bytes.Buffer
+		runConfigCommand(c, []string{"print"}, &out)
+		if got := strings.Count(out.String(), "REDACTED"); got != 1 {
+			t.Errorf("REDACTED appears %d times, want 1:\n%s", got, out.String())
+		}
+	})
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		want   string // expected key in the error, empty when valid
+	}{
+		{name: "valid", modify: func(c *Config) {}},
+		{name: "no API token", modify: func(c *Config) { c.Auth.APIToken = "" }},
+		{name: "zero duration", modify: func(c *Config) { c.HTTP.ReadTimeout = 0 }, want: "http.read_timeout"},
+		{name: "negative int", modify: func(c *Config) { c.HTTP.MaxHeaderBytes = -1 }, want: "http.max_header_bytes"},
+		{name: "log level", modify: func(c *Config) { c.Log.Level = "loud" }, want: "log.level"},
+		{name: "endpoint scheme", modify: func(c *Config) { c.Storage.Endpoint = "localhost:9000" }, want: "storage.endpoint"},
+		{name: "region", modify: func(c *Config) { c.Storage.Region = "" }, want: "storage.region"},
+		{name: "secret key", modify: func(c *Config) { c.Storage.SecretKey = "" }, want: "storage.secret_key: required (set AWS_SECRET_ACCESS_KEY)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := defaultConfig()
+			c.Storage.AccessKey, c.Storage.SecretKey = "access", "secret"
+			tt.modify(&c)
+			err := c.validate()
+			switch {
+			case tt.want == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.want != "" && err == nil:
+				t.Errorf("no error, want %s", tt.want)
+			case tt.want != "" && !strings.Contains(err.Error(), tt.want):
+				t.Errorf("error %v, want %s", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/gin-gonic/gin"
+)
+
+const defaultHealthCheckTimeout = 2 * time.Second
+
+type dependencyStatus struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latencyMs"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// healthzHandler reports that the process is up.
+func healthzHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// readyzHandler checks the bucket with HeadBucket and answers 503 when it
+// is unreachable. health.check_timeout bounds the check.
+func readyzHandler(client *s3.Client, bucketName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), cfg.Health.CheckTimeout)
+		defer cancel()
+
+		started := time.Now()
+		_, err := client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(bucketName)})
+		storage := dependencyStatus{Status: "ok", LatencyMs: float64(time.Since(started).Microseconds()) / 1000}
+		if err != nil {
+			storage.Status = "fail"
+			storage.Error = err.Error()
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "checks": gin.H{"storage": storage}})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "ready", "checks": gin.H{"storage": storage}})
+	}
+}
+
+// isProbePath matches the metrics and health endpoints, which are not traced
+// and are only logged at debug level.
+func isProbePath(path string) bool {
+	return path == "/metrics" || path == "/healthz" || path == "/readyz"
+}
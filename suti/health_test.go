@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestReadyz(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	previous := cfg
+	t.Cleanup(func() { cfg = previous })
+	cfg.Health.CheckTimeout = 50 * time.Millisecond
+
+	tests := []struct {
+		name     string
+		s3Status int
+		s3Delay  time.Duration
+		want     int
+		status   string
+	}{
+		{name: "bucket reachable", s3Status: http.StatusOK, want: http.StatusOK, status: "ok"},
+		{name: "bucket missing", s3Status: http.StatusNotFound, want: http.StatusServiceUnavailable, status: "fail"},
+		{name: "access denied", s3Status: http.StatusForbidden, want: http.StatusServiceUnavailable, status: "fail"},
+		{name: "slow storage", s3Status: http.StatusOK, s3Delay: 500 * time.Millisecond, want: http.StatusServiceUnavailable, status: "fail"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodHead || r.URL.Path != "/images" {
+					t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+				}
+				time.Sleep(test.s3Delay)
+				w.WriteHeader(test.s3Status)
+			}))
+			defer storage.Close()
+
+			router := gin.New()
+			router.GET("/readyz", readyzHandler(newTestS3Client(storage.URL), "images"))
+			recorder := httptest.NewRecorder()
+			started := time.Now()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			if elapsed := time.Since(started); elapsed > 400*time.Millisecond {
+				t.Errorf("readyz took %s with a %s check timeout", elapsed, cfg.Health.CheckTimeout)
+			}
+			var body struct {
+				Checks map[string]dependencyStatus `json:"checks"`
+			}
+			json.Unmarshal(recorder.Body.Bytes(), &body)
+			if recorder.Code != test.want || body.Checks["storage"].Status != test.status {
+				t.Fatalf("status %d, storage %+v, want %d and %s", recorder.Code, body.Checks["storage"], test.want, test.status)
+			}
+		})
+	}
+}
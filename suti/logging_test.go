@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// captureLogs sends slog output to a buffer through the same handler as
+// initLogging.
+func captureLogs(t *testing.T, level slog.Level) *bytes.Buffer {
+	t.Helper()
+	var buffer bytes.Buffer
+	previousLogger, previousLevel := slog.Default(), logLevel.Level()
+	logLevel.Set(level)
+	slog.SetDefault(slog.New(contextHandler{slog.NewJSONHandler(&buffer, &slog.HandlerOptions{Level: logLevel})}))
+	t.Cleanup(func() {
+		slog.SetDefault(previousLogger)
+		logLevel.Set(previousLevel)
+	})
+	return &buffer
+}
+
+func logRecords(t *testing.T, buffer *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buffer.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("not a JSON record: %s", line)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// loggedRouter uses the logging middleware in the same order as main.
+func loggedRouter() *gin.Engine {
+	router := gin.New()
+	router.Use(recoveryMiddleware(), requestIDMiddleware(), accessLogMiddleware())
+	router.GET("/api/images/:filename", func(c *gin.Context) {
+		slog.InfoContext(c.Request.Context(), "streaming")
+		c.Status(http.StatusNotFound)
+	})
+	router.GET("/healthz", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/panic", func(c *gin.Context) { panic("boom") })
+	return router
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	captureLogs(t, slog.LevelInfo)
+	router := loggedRouter()
+
+	tests := []struct {
+		name, header string
+		keep         bool
+	}{
+		{"client ID", "abc-123", true},
+		{"missing", "", false},
+		{"space", "abc 123", false},
+		{"newline", "abc\n{\"level\":\"ERROR\"}", false},
+		{"too long", strings.Repeat("a", 129), false},
+	}
+	for _, tt := range tests {
+		request := httptest.NewRequest(http.MethodGet, "/api/images/a.png", nil)
+		if tt.header != "" {
+			request.Header.Set(requestIDHeader, tt.header)
+		}
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		got := recorder.Header().Get(requestIDHeader)
+		if tt.keep && got != tt.header {
+			t.Errorf("%s: ID = %q, want %q", tt.name, got, tt.header)
+		}
+		if !tt.keep && (got == tt.header || len(got) != 32) {
+			t.Errorf("%s: ID = %q, want a new 32 character ID", tt.name, got)
+		}
+	}
+}
+
+func TestRequestLogs(t *testing.T) {
+	buffer := captureLogs(t, slog.LevelInfo)
+	request := httptest.NewRequest(http.MethodGet, "/api/images/a.png", nil)
+	request.Header.Set(requestIDHeader, "req-1")
+	router := loggedRouter()
+	router.ServeHTTP(httptest.NewRecorder(), request)
+
+	records := logRecords(t, buffer)
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want the handler's and the access log", len(records))
+	}
+	for _, record := range records {
+		if record["request_id"] != "req-1" || record["route"] != "/api/images/:filename" {
+			t.Errorf("%v: request_id = %v, route = %v", record["msg"], record["request_id"], record["route"])
+		}
+	}
+	if access := records[1]; access["level"] != "WARN" || access["status"] != float64(http.StatusNotFound) || access["image_id"] != "a.png" {
+		t.Errorf("access log = %v", access)
+	}
+
+	// Probes only show up at debug level.
+	buffer.Reset()
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if buffer.Len() != 0 {
+		t.Errorf("probe logged at info level: %s", buffer)
+	}
+	logLevel.Set(slog.LevelDebug)
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if records := logRecords(t, buffer); len(records) != 1 || records[0]["level"] != "DEBUG" {
+		t.Errorf("probe records = %v", records)
+	}
+}
+
+func TestRecoveryLogsPanic(t *testing.T) {
+	buffer := captureLogs(t, slog.LevelInfo)
+	request := httptest.NewRequest(http.MethodGet, "/panic", nil)
+	request.Header.Set(requestIDHeader, "req-1")
+	recorder := httptest.NewRecorder()
+	loggedRouter().ServeHTTP(recorder, request)
+
+	if recorder.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", recorder.Code)
+	}
+	records := logRecords(t, buffer)
+	if len(records) == 0 || records[0]["error"] != "boom" || records[0]["request_id"] != "req-1" {
+		t.Fatalf("panic records = %v", records)
+	}
+	if stack, _ := records[0]["stack"].(string); !strings.Contains(stack, "logging_test.go") {
+		t.Error("stack trace does not include the panic site")
+	}
+}
+
+func TestSetLogLevel(t *testing.T) {
+	captureLogs(t, slog.LevelInfo)
+	router := gin.New()
+	router.PUT("/admin/log-level", setLogLevelHandler)
+	set := func(body string) int {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPut, "/admin/log-level", strings.NewReader(body)))
+		return recorder.Code
+	}
+
+	for _, body := range []string{`{}`, `{"level":"verbose"}`, `not json`} {
+		if code := set(body); code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", body, code)
+		}
+	}
+	if logLevel.Level() != slog.LevelInfo {
+		t.Fatalf("a rejected request changed the level to %s", logLevel.Level())
+	}
+	if code := set(`{"level":"warn"}`); code != http.StatusOK || logLevel.Level() != slog.LevelWarn {
+		t.Errorf("status = %d, level = %s", code, logLevel.Level())
+	}
+}
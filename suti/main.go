@@ -2,11 +2,15 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -18,80 +22,125 @@ import (
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
 func main() {
 
 	err := godotenv.Load()
+
+	// Configuration is loaded after .env so its variables take part.
+	loaded, args, configErr := loadConfig(os.Args[1:])
+	if errors.Is(configErr, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if configErr != nil {
+		fmt.Fprintf(os.Stderr, "Configuration error:\n%v\n", configErr)
+		os.Exit(2)
+	}
+	cfg = loaded
+	if len(args) > 0 && args[0] == "config" {
+		os.Exit(runConfigCommand(cfg, args[1:], os.Stdout))
+	}
+	if err := cfg.validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration:\n%v\n", err)
+		os.Exit(2)
+	}
+
+	initLogging()
 	if err != nil {
-		log.Fatal("Error loading .env file")
+		// The environment alone is enough, e.g. in containers.
+		slog.Warn("Could not load .env file, using the environment only", "error", err)
 	}
 
-	router := gin.Default()
+	shutdownTracing, err := initTracing(context.Background())
+	if err != nil {
+		slog.Error("Failed to initialize tracing", "error", err)
+		os.Exit(1)
+	}
+	defer shutdownTracing(context.Background())
+
+	// SIGINT/SIGTERM stop the server after in-flight requests finish.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	router := gin.New()
+	router.Use(recoveryMiddleware())
+	router.Use(otelgin.Middleware("suti", otelgin.WithFilter(func(r *http.Request) bool {
+		return !isProbePath(r.URL.Path)
+	})))
+	router.Use(metricsMiddleware())
+	router.Use(requestIDMiddleware(), accessLogMiddleware())
 
 	router.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost:3000"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "Range", "If-None-Match", "If-Modified-Since", "X-Request-ID"},
+		ExposeHeaders:    []string{"Content-Length", "Content-Range", "Content-Disposition", "Accept-Ranges", "ETag", "Last-Modified", "X-Request-ID"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
 	router.MaxMultipartMemory = 8 << 20
 
-	region := os.Getenv("AWS_REGION")
-	accessKey := os.Getenv("AWS_ACCESS_KEY_ID")
-	secretKey := os.Getenv("AWS_SECRET_ACCESS_KEY")
-
 	cutomerResolver := aws.EndpointResolverWithOptionsFunc(func(sevice, region string, options ...interface{}) (aws.Endpoint, error) {
 		return aws.Endpoint{
-			URL:               "http://localhost:9000",
+			URL:               cfg.Storage.Endpoint,
 			HostnameImmutable: true,
 			SigningRegion:     region,
 		}, nil
 	})
 
-	cfg, err := config.LoadDefaultConfig(context.TODO(),
-		config.WithRegion(region),
+	awsConfig, err := config.LoadDefaultConfig(ctx,
+		config.WithRegion(cfg.Storage.Region),
 		config.WithEndpointResolverWithOptions(cutomerResolver),
 		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(
-			accessKey,
-			secretKey,
+			cfg.Storage.AccessKey,
+			cfg.Storage.SecretKey,
 			"",
 		)),
 	)
 	if err != nil {
-		log.Printf("Error: %v", err)
+		slog.Error("Failed to load AWS config", "error", err)
 		return
 	}
 
-	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+	client := s3.NewFromConfig(awsConfig, func(o *s3.Options) {
 		o.UsePathStyle = true
-	})
+	}, withS3Tracing, withS3Metrics)
 	uploader := manager.NewUploader(client)
 
-	bucketName := "code"
-	_, err = client.HeadBucket(context.TODO(), &s3.HeadBucketInput{
+	bucketName := cfg.Storage.Bucket
+	_, err = client.HeadBucket(ctx, &s3.HeadBucketInput{
 		Bucket: aws.String(bucketName),
 	})
 
 	if err != nil {
-		log.Printf("Bucket does not exist, creating it: %v", err)
-		_, err = client.CreateBucket(context.TODO(), &s3.CreateBucketInput{
+		slog.Info("Bucket does not exist, creating it", "bucket", bucketName, "error", err)
+		_, err = client.CreateBucket(ctx, &s3.CreateBucketInput{
 			Bucket: aws.String(bucketName),
 		})
 		if err != nil {
-			log.Printf("Failed to create bucket: %v", err)
+			slog.Error("Failed to create bucket", "bucket", bucketName, "error", err)
 		} else {
-			log.Printf("Bucket created successfully")
+			slog.Info("Bucket created successfully", "bucket", bucketName)
 		}
 	}
 
+	prometheus.MustRegister(newBucketStatsCollector(client, bucketName))
+	router.GET("/metrics", requireToken(), gin.WrapH(promhttp.Handler()))
+	router.GET("/healthz", healthzHandler)
+	router.GET("/readyz", readyzHandler(client, bucketName))
+	router.GET("/admin/log-level", requireToken(), getLogLevelHandler)
+	router.PUT("/admin/log-level", requireToken(), setLogLevelHandler)
+
 	router.GET("/", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"message": "get"})
 	})
 
 	router.POST("/", func(c *gin.Context) {
+		started := time.Now()
 
 		file, err := c.FormFile("image")
 		if err != nil {
@@ -111,7 +160,7 @@ func main() {
 		fileExt := filepath.Ext(file.Filename)
 		fileName := fmt.Sprintf("%d%s", time.Now().Unix(), fileExt)
 
-		_, uploadError := uploader.Upload(context.TODO(), &s3.PutObjectInput{
+		_, uploadError := uploader.Upload(c.Request.Context(), &s3.PutObjectInput{
 			Bucket:      aws.String(bucketName),
 			Key:         aws.String(fileName),
 			Body:        f,
@@ -120,31 +169,33 @@ func main() {
 		})
 
 		if uploadError != nil {
-			log.Printf("Upload error: %v", uploadError)
+			slog.ErrorContext(c.Request.Context(), "Upload error", "image_id", fileName, "error", uploadError)
 			c.JSON(http.StatusOK, gin.H{"error": "Faile to upload image"})
 			return
 		}
 
-		imageURL := fmt.Sprintf("http://localhost:9000/%s/%s", bucketName, file.Filename)
-		log.Printf("Upload successful, URL: %s", imageURL)
+		observeUpload(file.Size, started)
+
+		imageURL := fmt.Sprintf("%s/%s/%s", cfg.Storage.Endpoint, bucketName, file.Filename)
+		slog.InfoContext(c.Request.Context(), "Upload successful", "image_id", fileName, "url", imageURL)
 
 		c.JSON(http.StatusOK, gin.H{"seccess": true, "url": imageURL})
 	})
 
 	router.GET("/images", func(c *gin.Context) {
-		listObjsResponse, err := client.ListObjectsV2(context.TODO(), &s3.ListObjectsV2Input{
+		listObjsResponse, err := client.ListObjectsV2(c.Request.Context(), &s3.ListObjectsV2Input{
 			Bucket: aws.String(bucketName),
 		})
 
 		if err != nil {
-			log.Printf("Error listing objects: %v", err)
+			slog.ErrorContext(c.Request.Context(), "Error listing objects", "error", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list images"})
 			return
 		}
 
 		var images []map[string]string
 		for _, obj := range listObjsResponse.Contents {
-			imageURL := fmt.Sprintf("http://localhost:9000/%s/%s", bucketName, *obj.Key)
+			imageURL := fmt.Sprintf("%s/%s/%s", cfg.Storage.Endpoint, bucketName, *obj.Key)
 			images = append(images, map[string]string{
 				"name": *obj.Key,
 				"url":  imageURL,
@@ -159,32 +210,16 @@ func main() {
 	router.GET("/view/:filename", func(c *gin.Context) {
 		filename := c.Param("filename")
 
-		imageUrl := fmt.Sprintf("http://localhost:9000/%s/%s", bucketName, filename)
+		imageUrl := fmt.Sprintf("%s/%s/%s", cfg.Storage.Endpoint, bucketName, filename)
 		c.JSON(http.StatusOK, gin.H{"imageUrl": imageUrl, "filename": filename})
 	})
 
 	router.GET("/api/images/:filename", func(c *gin.Context) {
-		filename := c.Param("filename")
-
-		result, err := client.GetObject(context.TODO(), &s3.GetObjectInput{
-			Bucket: aws.String(bucketName),
-			Key:    aws.String(filename),
-		})
-
-		if err != nil {
-			log.Printf("Error getting object: %v", err)
-			c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
-			return
-		}
-		defer result.Body.Close()
-
-		contentType := aws.ToString(result.ContentType)
-		if contentType == "" {
-			contentType = "application/octet-stream"
-		}
-
-		c.DataFromReader(http.StatusOK, *result.ContentLength, contentType, result.Body, nil)
+		streamObject(c, client, bucketName, c.Param("filename"))
 	})
 
-	router.Run()
+	if err := serveUntilShutdown(ctx, stop, newHTTPServer(router)); err != nil {
+		slog.Error("Server failed", "error", err)
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+	"github.com/aws/smithy-go/middleware"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const metricsNamespace = "suti"
+
+// bucketStatsTTL limits how often a scrape lists the whole bucket.
+const bucketStatsTTL = time.Minute
+
+var (
+	httpRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by route and status.",
+	}, []string{"method", "route", "status"})
+
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	uploadSizeBytes = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "upload_size_bytes",
+		Help:      "Size of uploaded images.",
+		Buckets:   prometheus.ExponentialBuckets(16<<10, 4, 8),
+	})
+
+	uploadDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "upload_duration_seconds",
+		Help:      "Time spent handling successful uploads.",
+		Buckets:   prometheus.ExponentialBuckets(0.05, 2, 12),
+	})
+
+	s3OperationDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "s3_operation_duration_seconds",
+		Help:      "S3 operation latency including retries.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation"})
+
+	s3OperationErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "s3_operation_errors_total",
+		Help:      "Failed S3 operations by error code.",
+	}, []string{"operation", "code"})
+)
+
+func metricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		// Use the route pattern so filenames do not become labels.
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(c.Writer.Status())
+		httpRequestsTotal.WithLabelValues(c.Request.Method, route, status).Inc()
+		httpRequestDuration.WithLabelValues(c.Request.Method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+func observeUpload(size int64, started time.Time) {
+	uploadSizeBytes.Observe(float64(size))
+	uploadDuration.Observe(time.Since(started).Seconds())
+}
+
+// withS3Metrics times every S3 call made through the client.
+func withS3Metrics(o *s3.Options) {
+	o.APIOptions = append(o.APIOptions, func(stack *middleware.Stack) error {
+		if isPresignStack(stack) {
+			return nil
+		}
+		operation := stack.ID()
+		return stack.Finalize.Add(middleware.FinalizeMiddlewareFunc("sutiMetrics",
+			func(ctx context.Context, in middleware.FinalizeInput, next middleware.FinalizeHandler) (middleware.FinalizeOutput, middleware.Metadata, error) {
+				start := time.Now()
+				out, metadata, err := next.HandleFinalize(ctx, in)
+				s3OperationDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+				if err != nil {
+					s3OperationErrors.WithLabelValues(operation, s3ErrorCode(err)).Inc()
+				}
+				return out, metadata, err
+			}), middleware.Before)
+	})
+}
+
+// isPresignStack reports whether the stack only presigns a URL. Presigning
+// empties the Deserialize step and never sends a request.
+func isPresignStack(stack *middleware.Stack) bool {
+	return len(stack.Deserialize.List()) == 0
+}
+
+func s3ErrorCode(err error) string {
+	var apiErr smithy.APIError
+	switch {
+	case errors.As(err, &apiErr):
+		return apiErr.ErrorCode()
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	default:
+		return "network"
+	}
+}
+
+// bucketStatsCollector reports the number and total size of stored images.
+// suti has no database, so the bucket is listed and the result cached.
+type bucketStatsCollector struct {
+	client *s3.Client
+	bucket string
+	count  *prometheus.Desc
+	bytes  *prometheus.Desc
+
+	mu        sync.Mutex
+	updated   time.Time
+	lastCount int64
+	lastBytes int64
+}
+
+func newBucketStatsCollector(client *s3.Client, bucket string) *bucketStatsCollector {
+	return &bucketStatsCollector{
+		client: client,
+		bucket: bucket,
+		count:  prometheus.NewDesc(metricsNamespace+"_images_stored", "Number of objects in the bucket.", nil, nil),
+		bytes:  prometheus.NewDesc(metricsNamespace+"_images_stored_bytes", "Total size of objects in the bucket.", nil, nil),
+	}
+}
+
+func (c *bucketStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.count
+	ch <- c.bytes
+}
+
+func (c *bucketStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if time.Since(c.updated) > bucketStatsTTL {
+		if err := c.refresh(); err != nil {
+			ch <- prometheus.NewInvalidMetric(c.count, err)
+			return
+		}
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.count, prometheus.GaugeValue, float64(c.lastCount))
+	ch <- prometheus.MustNewConstMetric(c.bytes, prometheus.GaugeValue, float64(c.lastBytes))
+}
+
+func (c *bucketStatsCollector) refresh() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var count, bytes int64
+	paginator := s3.NewListObjectsV2Paginator(c.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(c.bucket),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for _, obj := range page.Contents {
+			count++
+			bytes += aws.ToInt64(obj.Size)
+		}
+	}
+
+	c.lastCount, c.lastBytes, c.updated = count, bytes, time.Now()
+	return nil
+}
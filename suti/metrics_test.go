@@ -0,0 +1,107 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// metricValue returns the value of the series of name matching the label
+// pairs, the sample count for histograms, or 0 when there is no such series.
+func metricValue(t *testing.T, gatherer prometheus.Gatherer, name string, labels ...string) float64 {
+	t.Helper()
+	families, err := gatherer.Gather()
+	if err != nil {
+		t.Fatalf("gather: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, metric := range family.GetMetric() {
+			values := make(map[string]string)
+			for _, label := range metric.GetLabel() {
+				values[label.GetName()] = label.GetValue()
+			}
+			for i := 0; i+1 < len(labels); i += 2 {
+				if values[labels[i]] != labels[i+1] {
+					continue metrics
+				}
+			}
+			switch {
+			case metric.GetHistogram() != nil:
+				return float64(metric.GetHistogram().GetSampleCount())
+			case metric.GetCounter() != nil:
+				return metric.GetCounter().GetValue()
+			default:
+				return metric.GetGauge().GetValue()
+			}
+		}
+	}
+	return 0
+}
+
+func TestMetricsMiddlewareLabelsByRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(metricsMiddleware())
+	router.GET("/api/images/:filename", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	requests := func(route, status string) float64 {
+		return metricValue(t, prometheus.DefaultGatherer, "suti_http_requests_total", "method", "GET", "route", route, "status", status)
+	}
+	byRoute, unmatched := requests("/api/images/:filename", "200"), requests("unmatched", "404")
+
+	for _, target := range []string{"/api/images/a.png", "/api/images/b.png", "/nowhere"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+	}
+	if got := requests("/api/images/:filename", "200") - byRoute; got != 2 {
+		t.Errorf("/api/images/:filename: %v requests, want 2", got)
+	}
+	if got := requests("unmatched", "404") - unmatched; got != 1 {
+		t.Errorf("unmatched: %v requests, want 1", got)
+	}
+}
+
+func TestBucketStatsCollector(t *testing.T) {
+	var lists, fail atomic.Int32
+	storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lists.Add(1)
+		if fail.Load() != 0 {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte("<Error><Code>AccessDenied</Code></Error>"))
+			return
+		}
+		w.Write([]byte(`<ListBucketResult><Name>images</Name><IsTruncated>false</IsTruncated>` +
+			`<Contents><Key>a.png</Key><Size>100</Size></Contents>` +
+			`<Contents><Key>b.png</Key><Size>23</Size></Contents></ListBucketResult>`))
+	}))
+	defer storage.Close()
+
+	collector := newBucketStatsCollector(newTestS3Client(storage.URL), "images")
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collector)
+
+	if got := metricValue(t, registry, "suti_images_stored"); got != 2 {
+		t.Errorf("suti_images_stored = %v, want 2", got)
+	}
+	if got := metricValue(t, registry, "suti_images_stored_bytes"); got != 123 {
+		t.Errorf("suti_images_stored_bytes = %v, want 123", got)
+	}
+	// Both scrapes fall within bucketStatsTTL, so the bucket is listed once.
+	if got := lists.Load(); got != 1 {
+		t.Errorf("bucket listed %d times, want 1", got)
+	}
+
+	collector.updated = time.Now().Add(-2 * bucketStatsTTL)
+	fail.Store(1)
+	if _, err := registry.Gather(); err == nil {
+		t.Error("a failed listing should surface as a scrape error")
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/gin-gonic/gin"
+)
+
+const proxyCacheControl = "private, max-age=3600"
+
+// requireToken rejects requests without the API_TOKEN bearer token. The token
+// is optional in the config, so routes behind it fail closed when it is unset.
+func requireToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		expected := cfg.Auth.APIToken
+		if expected == "" {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "API token is not configured"})
+			return
+		}
+
+		var provided string
+		if value, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok {
+			provided = value
+		}
+
+		if provided == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(expected)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or missing token"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// streamObject proxies an object to the client, forwarding Range and
+// conditional headers to S3 so partial and 304 responses come for free.
+func streamObject(c *gin.Context, client *s3.Client, bucketName, key string) {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(key),
+	}
+	if rangeHeader := c.GetHeader("Range"); rangeHeader != "" {
+		input.Range = aws.String(rangeHeader)
+	}
+	if ifNoneMatch := c.GetHeader("If-None-Match"); ifNoneMatch != "" {
+		input.IfNoneMatch = aws.String(ifNoneMatch)
+	}
+	if ifModifiedSince := c.GetHeader("If-Modified-Since"); ifModifiedSince != "" {
+		if t, err := http.ParseTime(ifModifiedSince); err == nil {
+			input.IfModifiedSince = aws.Time(t)
+		}
+	}
+
+	result, err := client.GetObject(c.Request.Context(), input)
+	if err != nil {
+		var respErr *awshttp.ResponseError
+		if errors.As(err, &respErr) {
+			switch respErr.HTTPStatusCode() {
+			case http.StatusNotModified:
+				copyHeader(c, respErr.Response.Header, "ETag")
+				copyHeader(c, respErr.Response.Header, "Last-Modified")
+				c.Header("Cache-Control", proxyCacheControl)
+				c.Status(http.StatusNotModified)
+				return
+			case http.StatusRequestedRangeNotSatisfiable:
+				copyHeader(c, respErr.Response.Header, "Content-Range")
+				c.JSON(http.StatusRequestedRangeNotSatisfiable, gin.H{"error": "Invalid range"})
+				return
+			case http.StatusNotFound:
+				c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+				return
+			}
+		}
+
+		// Credentials, permissions and outages are our problem, not a missing image
+		slog.ErrorContext(c.Request.Context(), "Error getting object", "key", key, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get image"})
+		return
+	}
+	defer result.Body.Close()
+
+	disposition := "inline"
+	if c.Query("download") == "1" {
+		disposition = "attachment"
+	}
+	if value := mime.FormatMediaType(disposition, map[string]string{"filename": key}); value != "" {
+		disposition = value
+	}
+
+	headers := map[string]string{
+		"Accept-Ranges":       "bytes",
+		"Cache-Control":       proxyCacheControl,
+		"Content-Disposition": disposition,
+	}
+	if result.ETag != nil {
+		headers["ETag"] = *result.ETag
+	}
+	if result.LastModified != nil {
+		headers["Last-Modified"] = result.LastModified.UTC().Format(http.TimeFormat)
+	}
+
+	status := http.StatusOK
+	if result.ContentRange != nil {
+		headers["Content-Range"] = *result.ContentRange
+		status = http.StatusPartialContent
+	}
+
+	contentType := aws.ToString(result.ContentType)
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	c.DataFromReader(status, aws.ToInt64(result.ContentLength), contentType, result.Body, headers)
+}
+
+func copyHeader(c *gin.Context, header http.Header, name string) {
+	if value := header.Get(name); value != "" {
+		c.Header(name, value)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/gin-gonic/gin"
+)
+
+// newTestS3Client returns a path-style client for an httptest S3 server.
+func newTestS3Client(endpoint string) *s3.Client {
+	return s3.New(s3.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(endpoint),
+		UsePathStyle: true,
+		Credentials:  credentials.NewStaticCredentialsProvider("test", "test", ""),
+		Retryer:      aws.NopRetryer{},
+	})
+}
+
+func TestStreamObjectMapsS3Errors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name     string
+		s3Status int
+		s3Code   string
+		want     int
+	}{
+		{name: "missing object", s3Status: http.StatusNotFound, s3Code: "NoSuchKey", want: http.StatusNotFound},
+		{name: "bad credentials", s3Status: http.StatusForbidden, s3Code: "AccessDenied", want: http.StatusInternalServerError},
+		{name: "outage", s3Status: http.StatusServiceUnavailable, s3Code: "ServiceUnavailable", want: http.StatusInternalServerError},
+		{name: "not modified", s3Status: http.StatusNotModified, want: http.StatusNotModified},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("ETag", `"etag"`)
+				w.WriteHeader(test.s3Status)
+				if test.s3Code != "" {
+					w.Write([]byte("<Error><Code>" + test.s3Code + "</Code></Error>"))
+				}
+			}))
+			defer storage.Close()
+
+			client := newTestS3Client(storage.URL)
+
+			router := gin.New()
+			router.GET("/api/images/:filename", func(c *gin.Context) {
+				streamObject(c, client, "images", c.Param("filename"))
+			})
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/images/cat.png", nil))
+
+			if recorder.Code != test.want {
+				t.Fatalf("status %d, want %d: %s", recorder.Code, test.want, recorder.Body)
+			}
+		})
+	}
+}
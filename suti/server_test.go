@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// startShutdownServer runs serveUntilShutdown with a handler whose requests
+// block until release is closed. entered is closed when the first request
+// arrives.
+func startShutdownServer(t *testing.T, timeout time.Duration) (addr string, cancel context.CancelFunc, done chan error, entered, release chan struct{}) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr = listener.Addr().String()
+	listener.Close()
+
+	previous := cfg
+	t.Cleanup(func() { cfg = previous })
+	cfg.HTTP.Addr = addr
+	cfg.HTTP.ShutdownTimeout = timeout
+
+	entered, release = make(chan struct{}), make(chan struct{})
+	var once sync.Once
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		once.Do(func() { close(entered) })
+		<-release
+		io.WriteString(w, "done")
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	done = make(chan error, 1)
+	go func() { done <- serveUntilShutdown(ctx, func() {}, newHTTPServer(handler)) }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			conn.Close()
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("server did not start")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return addr, cancel, done, entered, release
+}
+
+// getAsync returns the response body, or the error, of a background GET.
+func getAsync(url string) chan string {
+	result := make(chan string, 1)
+	go func() {
+		response, err := http.Get(url)
+		if err != nil {
+			result <- "error: " + err.Error()
+			return
+		}
+		defer response.Body.Close()
+		body, _ := io.ReadAll(response.Body)
+		result <- string(body)
+	}()
+	return result
+}
+
+func TestServeUntilShutdownFinishesInFlightRequests(t *testing.T) {
+	addr, cancel, done, entered, release := startShutdownServer(t, 5*time.Second)
+	response := getAsync("http://" + addr + "/")
+	<-entered
+	cancel()
+
+	select {
+	case err := <-done:
+		t.Fatalf("returned before the in-flight request finished: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	if body := <-response; body != "done" {
+		t.Errorf("in-flight request: %s", body)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("serveUntilShutdown: %v", err)
+	}
+}
+
+func TestServeUntilShutdownGivesUpAfterTimeout(t *testing.T) {
+	addr, cancel, done, entered, release := startShutdownServer(t, 100*time.Millisecond)
+	t.Cleanup(func() { close(release) })
+	response := getAsync("http://" + addr + "/")
+	<-entered
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("serveUntilShutdown: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("shutdown did not honour http.shutdown_timeout")
+	}
+	select {
+	case body := <-response:
+		if body == "done" {
+			t.Error("request should have been cut off")
+		}
+	case <-time.After(time.Second):
+		t.Error("connection was not closed")
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go/middleware"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/aws/aws-sdk-go-v2/otelaws"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// initTracing configures OpenTelemetry from OTEL_TRACES_EXPORTER: "otlp"
+// (OTLP/HTTP, configured by the OTEL_EXPORTER_OTLP_* variables), "console"
+// or "none". It defaults to otlp when an OTLP endpoint is set and to none
+// otherwise. W3C traceparent headers are propagated either way.
+func initTracing(ctx context.Context) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	name := strings.ToLower(os.Getenv("OTEL_TRACES_EXPORTER"))
+	if name == "" {
+		name = "none"
+		if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != "" {
+			name = "otlp"
+		}
+	}
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch name {
+	case "none":
+		return func(context.Context) error { return nil }, nil
+	case "otlp":
+		exporter, err = otlptracehttp.New(ctx)
+	case "console", "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	default:
+		return nil, fmt.Errorf("unknown OTEL_TRACES_EXPORTER %q (want otlp, console or none)", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the default name.
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", "suti")),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	slog.Info("OpenTelemetry tracing enabled", "exporter", name)
+	return provider.Shutdown, nil
+}
+
+// withS3Tracing creates a span for every S3 call and forwards traceparent.
+func withS3Tracing(o *s3.Options) {
+	var tracing []func(*middleware.Stack) error
+	otelaws.AppendMiddlewares(&tracing)
+
+	o.APIOptions = append(o.APIOptions, func(stack *middleware.Stack) error {
+		if isPresignStack(stack) {
+			return nil
+		}
+		for _, add := range tracing {
+			if err := add(stack); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recordSpans installs a tracer provider that keeps ended spans in memory.
+func recordSpans(t *testing.T) (*tracetest.SpanRecorder, trace.Tracer) {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+	return recorder, provider.Tracer("test")
+}
+
+func TestS3TracingForwardsTraceparent(t *testing.T) {
+	recorder, tracer := recordSpans(t)
+
+	var traceparent string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+	}))
+	defer backend.Close()
+	client := s3.New(newTestS3Client(backend.URL).Options(), withS3Tracing)
+
+	ctx, parent := tracer.Start(context.Background(), "request")
+	if _, err := client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String("test"), Key: aws.String("a.png")}); err != nil {
+		t.Fatal(err)
+	}
+	// Presigning sends nothing and must not create a span.
+	if _, err := s3.NewPresignClient(client).PresignGetObject(ctx, &s3.GetObjectInput{Bucket: aws.String("test"), Key: aws.String("a.png")}); err != nil {
+		t.Fatal(err)
+	}
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want the parent and one S3 span", len(spans))
+	}
+	call := spans[0]
+	if call.SpanKind() != trace.SpanKindClient || call.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("S3 span %q (%s) is not a child of the request", call.Name(), call.SpanKind())
+	}
+	if !strings.Contains(traceparent, call.SpanContext().TraceID().String()) {
+		t.Errorf("traceparent %q does not carry trace %s", traceparent, call.SpanContext().TraceID())
+	}
+}
+
+func TestInitTracingExporterSelection(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	previousPropagator := otel.GetTextMapPropagator()
+	t.Cleanup(func() { otel.SetTextMapPropagator(previousPropagator) })
+
+	for exporter, ok := range map[string]bool{"": true, "none": true, "zipkin": false} {
+		t.Setenv("OTEL_TRACES_EXPORTER", exporter)
+		shutdown, err := initTracing(context.Background())
+		if (err == nil) != ok {
+			t.Errorf("OTEL_TRACES_EXPORTER=%q: err = %v", exporter, err)
+			continue
+		}
+		if ok {
+			shutdown(context.Background())
+		}
+	}
+}